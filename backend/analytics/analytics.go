@@ -0,0 +1,221 @@
+// Daily topic statistics for the creator analytics page
+package analytics
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	db "github.com/minrui13/backend/database"
+	"github.com/minrui13/backend/jobs"
+	"github.com/minrui13/backend/types"
+)
+
+const dateLayout = "2006-01-02"
+
+// refresh yesterday and today for every topic
+// yesterday is included so late activity around midnight is not lost
+var RollupJob = jobs.Job{
+	Name:     "topic analytics rollup",
+	Interval: time.Hour,
+	Run: func(ctx context.Context, pool *pgxpool.Pool) error {
+		today, err := Today(ctx, pool)
+		if err != nil {
+			return err
+		}
+		return Rollup(ctx, pool, nil, today.AddDate(0, 0, -1), today)
+	},
+}
+
+// the database date, activity is stored in database time
+func Today(ctx context.Context, q db.Querier) (time.Time, error) {
+	var today time.Time
+	err := q.QueryRow(ctx, `SELECT CURRENT_DATE`).Scan(&today)
+	return today, err
+}
+
+// recompute the daily rows from the activity tables
+// topicID nil rolls up every topic, follows and votes without a date (see the 005 migration) are not counted
+func Rollup(ctx context.Context, q db.Querier, topicID *int, from time.Time, to time.Time) error {
+	_, err := q.Exec(ctx,
+		`INSERT INTO topics_daily_stats (topic_id, day, new_followers, posts, comments, votes, active_users)
+		SELECT t.topic_id, d.day::date,
+			(SELECT COUNT(*) FROM topics_followers tf
+				WHERE tf.topic_id = t.topic_id AND tf.created_date >= d.day AND tf.created_date < d.day + INTERVAL '1 day'),
+			(SELECT COUNT(*) FROM posts p
+				WHERE p.topic_id = t.topic_id AND p.status = 'published' AND p.created_date >= d.day AND p.created_date < d.day + INTERVAL '1 day'),
+			(SELECT COUNT(*) FROM posts_comments pc
+				INNER JOIN posts p ON p.post_id = pc.post_id
+				WHERE p.topic_id = t.topic_id AND pc.created_date >= d.day AND pc.created_date < d.day + INTERVAL '1 day'),
+			(SELECT COUNT(*) FROM posts_votes pv
+				INNER JOIN posts p ON p.post_id = pv.post_id
+				WHERE p.topic_id = t.topic_id AND pv.created_date >= d.day AND pv.created_date < d.day + INTERVAL '1 day')
+			+ (SELECT COUNT(*) FROM comments_votes cv
+				INNER JOIN posts_comments pc ON pc.comment_id = cv.comment_id
+				INNER JOIN posts p ON p.post_id = pc.post_id
+				WHERE p.topic_id = t.topic_id AND cv.created_date >= d.day AND cv.created_date < d.day + INTERVAL '1 day'),
+			(SELECT COUNT(DISTINCT a.user_id) FROM (
+				SELECT p.author_id AS user_id FROM posts p
+				WHERE p.topic_id = t.topic_id AND p.status = 'published' AND p.created_date >= d.day AND p.created_date < d.day + INTERVAL '1 day'
+				UNION ALL
+				SELECT pc.user_id FROM posts_comments pc
+				INNER JOIN posts p ON p.post_id = pc.post_id
+				WHERE p.topic_id = t.topic_id AND pc.created_date >= d.day AND pc.created_date < d.day + INTERVAL '1 day'
+				UNION ALL
+				SELECT pv.user_id FROM posts_votes pv
+				INNER JOIN posts p ON p.post_id = pv.post_id
+				WHERE p.topic_id = t.topic_id AND pv.created_date >= d.day AND pv.created_date < d.day + INTERVAL '1 day'
+				UNION ALL
+				SELECT cv.user_id FROM comments_votes cv
+				INNER JOIN posts_comments pc ON pc.comment_id = cv.comment_id
+				INNER JOIN posts p ON p.post_id = pc.post_id
+				WHERE p.topic_id = t.topic_id AND cv.created_date >= d.day AND cv.created_date < d.day + INTERVAL '1 day'
+			) AS a)
+		FROM topics t
+		CROSS JOIN generate_series($2::date::timestamp, $3::date::timestamp, INTERVAL '1 day') AS d(day)
+		WHERE $1::int IS NULL OR t.topic_id = $1
+		ON CONFLICT (topic_id, day) DO UPDATE SET
+			new_followers = EXCLUDED.new_followers,
+			posts = EXCLUDED.posts,
+			comments = EXCLUDED.comments,
+			votes = EXCLUDED.votes,
+			active_users = EXCLUDED.active_users,
+			updated_date = CURRENT_TIMESTAMP`,
+		topicID, from, to)
+	return err
+}
+
+// fill any days of the range the job has not rolled up yet
+// today is always recomputed since it is still changing
+func ensureRollup(ctx context.Context, q db.Querier, topicID int, from time.Time, to time.Time) error {
+	today, err := Today(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	//only closed days are kept as they are
+	closedTo := to
+	if !closedTo.Before(today) {
+		closedTo = today.AddDate(0, 0, -1)
+	}
+	if !closedTo.Before(from) {
+		var stored int
+		err := q.QueryRow(ctx,
+			`SELECT COUNT(*) FROM topics_daily_stats WHERE topic_id = $1 AND day BETWEEN $2 AND $3`,
+			topicID, from, closedTo).Scan(&stored)
+		if err != nil {
+			return err
+		}
+		expected := int(closedTo.Sub(from).Hours()/24) + 1
+		if stored < expected {
+			if err := Rollup(ctx, q, &topicID, from, closedTo); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !to.Before(today) && !today.Before(from) {
+		return Rollup(ctx, q, &topicID, today, today)
+	}
+	return nil
+}
+
+// daily series with the top posts and contributors for the range
+func GetTopicAnalytics(ctx context.Context, q db.Querier, topicID int, from time.Time, to time.Time, limit int) (types.TopicAnalyticsResult, error) {
+	result := types.TopicAnalyticsResult{
+		Topic_ID:         topicID,
+		From:             from.Format(dateLayout),
+		To:               to.Format(dateLayout),
+		Days:             []types.TopicAnalyticsDay{},
+		Top_Posts:        []types.TopicAnalyticsPost{},
+		Top_Contributors: []types.TopicAnalyticsContributor{},
+	}
+
+	if err := ensureRollup(ctx, q, topicID, from, to); err != nil {
+		return result, err
+	}
+
+	rows, err := q.Query(ctx,
+		`SELECT day, new_followers, posts, comments, votes, active_users
+		FROM topics_daily_stats
+		WHERE topic_id = $1 AND day BETWEEN $2 AND $3
+		ORDER BY day`,
+		topicID, from, to)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day time.Time
+		var d types.TopicAnalyticsDay
+		if err := rows.Scan(&day, &d.New_Followers, &d.Posts, &d.Comments, &d.Votes, &d.Active_Users); err != nil {
+			return result, err
+		}
+		d.Day = day.Format(dateLayout)
+		result.Days = append(result.Days, d)
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	//posts created in the range ranked by vote score
+	postRows, err := q.Query(ctx,
+		`SELECT p.post_id, p.post_url, p.title,
+			p.upvote_count - p.downvote_count AS score,
+			p.comment_count AS comment_count,
+			p.created_date
+		FROM posts p
+		WHERE p.topic_id = $1 AND p.status = 'published' AND p.deleted_date IS NULL
+		AND p.created_date >= $2::date AND p.created_date < $3::date + 1
+		ORDER BY score DESC, comment_count DESC, p.post_id DESC
+		LIMIT $4`,
+		topicID, from, to, limit)
+	if err != nil {
+		return result, err
+	}
+	defer postRows.Close()
+	for postRows.Next() {
+		var created time.Time
+		var p types.TopicAnalyticsPost
+		if err := postRows.Scan(&p.Post_ID, &p.Post_URL, &p.Title, &p.Score, &p.Comment_Count, &created); err != nil {
+			return result, err
+		}
+		p.Created_Date = created.Format(time.RFC3339)
+		result.Top_Posts = append(result.Top_Posts, p)
+	}
+	if err := postRows.Err(); err != nil {
+		return result, err
+	}
+
+	//users with the most posts and comments in the range
+	userRows, err := q.Query(ctx,
+		`SELECT u.user_id, u.username, u.display_name, i.image_name,
+			COUNT(*) FILTER (WHERE a.kind = 'post') AS post_count,
+			COUNT(*) FILTER (WHERE a.kind = 'comment') AS comment_count
+		FROM (
+			SELECT p.author_id AS user_id, 'post' AS kind FROM posts p
+			WHERE p.topic_id = $1 AND p.status = 'published' AND p.created_date >= $2::date AND p.created_date < $3::date + 1
+			UNION ALL
+			SELECT pc.user_id, 'comment' AS kind FROM posts_comments pc
+			INNER JOIN posts p ON p.post_id = pc.post_id
+			WHERE p.topic_id = $1 AND pc.created_date >= $2::date AND pc.created_date < $3::date + 1
+		) AS a
+		INNER JOIN users u ON u.user_id = a.user_id
+		INNER JOIN profile_image i ON u.image_id = i.image_id
+		GROUP BY u.user_id, u.username, u.display_name, i.image_name
+		ORDER BY COUNT(*) DESC, u.user_id
+		LIMIT $4`,
+		topicID, from, to, limit)
+	if err != nil {
+		return result, err
+	}
+	defer userRows.Close()
+	for userRows.Next() {
+		var c types.TopicAnalyticsContributor
+		if err := userRows.Scan(&c.User_ID, &c.Username, &c.Display_Name, &c.Image_Name, &c.Post_Count, &c.Comment_Count); err != nil {
+			return result, err
+		}
+		result.Top_Contributors = append(result.Top_Contributors, c)
+	}
+	return result, userRows.Err()
+}
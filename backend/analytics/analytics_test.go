@@ -0,0 +1,67 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/minrui13/backend/database/dbtest"
+	"github.com/minrui13/backend/types"
+)
+
+func TestGetTopicAnalytics(t *testing.T) {
+	tx := dbtest.Tx(t)
+	ctx := context.Background()
+
+	var topicID, postID, authorID int
+	err := tx.QueryRow(ctx, `SELECT topic_id, post_id, author_id FROM posts WHERE status = 'published' AND deleted_date IS NULL ORDER BY post_id LIMIT 1`).
+		Scan(&topicID, &postID, &authorID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		t.Skip("the test database has no published posts")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//a closed day long before any other activity, with only this post and a draft on it
+	from := time.Date(2000, 1, 9, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 2)
+	if _, err := tx.Exec(ctx, `UPDATE posts SET created_date = '2000-01-10 12:00' WHERE post_id = $1`, postID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE posts SET created_date = '2000-01-10 13:00', status = 'draft'
+		WHERE post_id = (SELECT post_id FROM posts WHERE topic_id = $1 AND post_id <> $2 ORDER BY post_id LIMIT 1)`,
+		topicID, postID); err != nil {
+		t.Fatal(err)
+	}
+
+	//the second call reads the rows the first one rolled up
+	for range 2 {
+		result, err := GetTopicAnalytics(ctx, tx, topicID, from, to, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []types.TopicAnalyticsDay{
+			{Day: "2000-01-09"},
+			{Day: "2000-01-10", Posts: 1, Active_Users: 1},
+			{Day: "2000-01-11"},
+		}
+		if len(result.Days) != len(want) {
+			t.Fatalf("days = %+v, want %+v", result.Days, want)
+		}
+		for i, day := range result.Days {
+			if day != want[i] {
+				t.Errorf("day %d = %+v, want %+v", i, day, want[i])
+			}
+		}
+		if len(result.Top_Posts) != 1 || result.Top_Posts[0].Post_ID != postID {
+			t.Errorf("top posts = %+v, want only post %d", result.Top_Posts, postID)
+		}
+		if len(result.Top_Contributors) != 1 || result.Top_Contributors[0].User_ID != authorID || result.Top_Contributors[0].Post_Count != 1 {
+			t.Errorf("top contributors = %+v, want only user %d with one post", result.Top_Contributors, authorID)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+// Rebuild the search index from the database
+// run from the backend folder so .env and the index path match the server's:
+//
+//	go run ./cmd/reindex
+//
+// with SEARCH_BACKEND=bleve a running server holds the index lock, stop it first
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/minrui13/backend/config"
+	db "github.com/minrui13/backend/database"
+	"github.com/minrui13/backend/search"
+)
+
+func main() {
+
+	//connecting the database
+	dbPool, err := db.Connect()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dbPool.Close()
+
+	//opening the search backend
+	searchEngine, err := search.New(config.Envs.SearchBackend, config.Envs.SearchIndexPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer searchEngine.Close()
+
+	start := time.Now()
+	if err := searchEngine.Reindex(context.Background(), dbPool); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("search index rebuilt for", config.Envs.SearchBackend, "in", time.Since(start))
+}
@@ -21,6 +21,13 @@ func initConfig() types.Config {
 		DATABASE_URL:           getEnv("DATABASE_URL", ""),
 		JWTExpirationInSeconds: getEnvAsInt("JWT_EXP", 3600*24*7),
 		JWTSecret:              getEnv("SECRET", "NIL"),
+		FRONTEND_URL:           getEnv("FRONTEND_URL", ""),
+		PostEditGraceSeconds:   getEnvAsInt("POST_EDIT_GRACE", 300),
+		PostRetentionDays:      getEnvAsInt("POST_RETENTION_DAYS", 30),
+		SearchBackend:          getEnv("SEARCH_BACKEND", "postgres"),
+		SearchIndexPath:        getEnv("SEARCH_INDEX_PATH", "search.bleve"),
+		FeedWeights:            getEnv("FEED_WEIGHTS", ""),
+		FeedMaxPerTopic:        getEnvAsInt("FEED_MAX_PER_TOPIC", 2),
 	}
 }
 
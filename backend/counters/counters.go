@@ -0,0 +1,125 @@
+// Vote, comment, reply and crosspost counters stored on posts and comments
+// the triggers of the 012 and 018 migrations keep them up to date, this repairs any drift
+package counters
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minrui13/backend/jobs"
+)
+
+// recount every post and comment once a day
+var ReconcileJob = jobs.Job{
+	Name:     "counter reconciliation",
+	Interval: 24 * time.Hour,
+	Run: func(ctx context.Context, pool *pgxpool.Pool) error {
+		posts, comments, err := Reconcile(ctx, pool)
+		if posts > 0 || comments > 0 {
+			log.Println("repaired counters of", posts, "posts and", comments, "comments")
+		}
+		return err
+	},
+}
+
+// posts whose stored counters differ from a recount
+const driftedPosts = `SELECT p.post_id
+	FROM posts p
+	LEFT JOIN (
+		SELECT post_id,
+		COUNT(*) FILTER (WHERE vote_type = 1) AS upvotes,
+		COUNT(*) FILTER (WHERE vote_type = -1) AS downvotes
+		FROM posts_votes
+		GROUP BY post_id
+	) v ON v.post_id = p.post_id
+	LEFT JOIN (
+		SELECT post_id, COUNT(*) AS comments
+		FROM posts_comments
+		GROUP BY post_id
+	) c ON c.post_id = p.post_id
+	LEFT JOIN (
+		SELECT crosspost_of_id, COUNT(*) AS crossposts
+		FROM posts
+		WHERE crosspost_of_id IS NOT NULL AND deleted_date IS NULL
+		GROUP BY crosspost_of_id
+	) x ON x.crosspost_of_id = p.post_id
+	WHERE (p.upvote_count, p.downvote_count, p.comment_count, p.crosspost_count)
+		IS DISTINCT FROM (COALESCE(v.upvotes, 0), COALESCE(v.downvotes, 0), COALESCE(c.comments, 0), COALESCE(x.crossposts, 0))`
+
+const recountPosts = `UPDATE posts p SET
+	upvote_count = (SELECT COUNT(*) FROM posts_votes pv WHERE pv.post_id = p.post_id AND pv.vote_type = 1),
+	downvote_count = (SELECT COUNT(*) FROM posts_votes pv WHERE pv.post_id = p.post_id AND pv.vote_type = -1),
+	comment_count = (SELECT COUNT(*) FROM posts_comments pc WHERE pc.post_id = p.post_id),
+	crosspost_count = (SELECT COUNT(*) FROM posts x WHERE x.crosspost_of_id = p.post_id AND x.deleted_date IS NULL)
+	WHERE p.post_id = ANY($1)`
+
+// comments whose stored counters differ from a recount
+const driftedComments = `SELECT pc.comment_id
+	FROM posts_comments pc
+	LEFT JOIN (
+		SELECT comment_id,
+		COUNT(*) FILTER (WHERE vote_type = 1) AS upvotes,
+		COUNT(*) FILTER (WHERE vote_type = -1) AS downvotes
+		FROM comments_votes
+		GROUP BY comment_id
+	) v ON v.comment_id = pc.comment_id
+	LEFT JOIN (
+		SELECT parent_comment_id, COUNT(*) AS replies
+		FROM posts_comments
+		WHERE parent_comment_id IS NOT NULL
+		GROUP BY parent_comment_id
+	) r ON r.parent_comment_id = pc.comment_id
+	WHERE (pc.upvote_count, pc.downvote_count, pc.reply_count)
+		IS DISTINCT FROM (COALESCE(v.upvotes, 0), COALESCE(v.downvotes, 0), COALESCE(r.replies, 0))`
+
+const recountComments = `UPDATE posts_comments c SET
+	upvote_count = (SELECT COUNT(*) FROM comments_votes cv WHERE cv.comment_id = c.comment_id AND cv.vote_type = 1),
+	downvote_count = (SELECT COUNT(*) FROM comments_votes cv WHERE cv.comment_id = c.comment_id AND cv.vote_type = -1),
+	reply_count = (SELECT COUNT(*) FROM posts_comments r WHERE r.parent_comment_id = c.comment_id)
+	WHERE c.comment_id = ANY($1)`
+
+// recount the posts and comments whose counters drifted
+// returns how many of each were repaired
+func Reconcile(ctx context.Context, pool *pgxpool.Pool) (int, int, error) {
+	posts, err := repair(ctx, pool, driftedPosts, `SELECT post_id FROM posts WHERE post_id = ANY($1) FOR UPDATE`, recountPosts)
+	if err != nil {
+		return 0, 0, err
+	}
+	comments, err := repair(ctx, pool, driftedComments, `SELECT comment_id FROM posts_comments WHERE comment_id = ANY($1) FOR UPDATE`, recountComments)
+	return posts, comments, err
+}
+
+// find drifted rows, lock them and recount
+// the recount runs after the lock so its snapshot includes votes committed while waiting,
+// recounting straight from the drift query could undo an increment made in between
+func repair(ctx context.Context, pool *pgxpool.Pool, driftSQL string, lockSQL string, recountSQL string) (int, error) {
+	rows, err := pool.Query(ctx, driftSQL)
+	if err != nil {
+		return 0, err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, lockSQL, ids); err != nil {
+		return 0, err
+	}
+	tag, err := tx.Exec(ctx, recountSQL, ids)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), tx.Commit(ctx)
+}
@@ -0,0 +1,68 @@
+package counters
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/minrui13/backend/database/dbtest"
+)
+
+func TestRecountPosts(t *testing.T) {
+	tx := dbtest.Tx(t)
+	ctx := context.Background()
+
+	var postID int
+	err := tx.QueryRow(ctx, `SELECT post_id FROM posts ORDER BY post_id LIMIT 1`).Scan(&postID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		t.Skip("the test database has no posts")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recount := func() (int, int) {
+		t.Helper()
+		if _, err := tx.Exec(ctx, recountPosts, []int{postID}); err != nil {
+			t.Fatal(err)
+		}
+		var upvotes, comments int
+		if err := tx.QueryRow(ctx, `SELECT upvote_count, comment_count FROM posts WHERE post_id = $1`, postID).Scan(&upvotes, &comments); err != nil {
+			t.Fatal(err)
+		}
+		return upvotes, comments
+	}
+	drifted := func() []int {
+		t.Helper()
+		rows, err := tx.Query(ctx, driftedPosts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ids
+	}
+
+	//a recounted post does not drift until its counters are changed by hand
+	upvotes, comments := recount()
+	if slices.Contains(drifted(), postID) {
+		t.Fatalf("post %d drifted right after its recount", postID)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE posts SET upvote_count = upvote_count + 5, comment_count = comment_count + 2 WHERE post_id = $1`, postID); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(drifted(), postID) {
+		t.Fatalf("post %d with changed counters did not drift", postID)
+	}
+
+	if gotUpvotes, gotComments := recount(); gotUpvotes != upvotes || gotComments != comments {
+		t.Errorf("recounted counters = %d upvotes and %d comments, want %d and %d", gotUpvotes, gotComments, upvotes, comments)
+	}
+	if slices.Contains(drifted(), postID) {
+		t.Errorf("post %d still drifts after the recount", postID)
+	}
+}
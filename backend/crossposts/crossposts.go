@@ -0,0 +1,55 @@
+// Crossposts, posts in another topic that link to an original post
+package crossposts
+
+import (
+	"context"
+	"errors"
+
+	db "github.com/minrui13/backend/database"
+)
+
+var (
+	ErrSameTopic          = errors.New("a post cannot be crossposted into its own topic")
+	ErrAlreadyCrossposted = errors.New("this post has already been crossposted into the topic")
+)
+
+// unique index that stops a post being crossposted into a topic twice
+const UniqueIndex = "posts_crosspost_topic_idx"
+
+// crosspost columns of the post queries
+// crosspost_of is null when the post is not a crosspost or the original is no longer published
+// both are null for deleted posts like their title and content
+const Column = `CASE WHEN p.deleted_date IS NULL THEN p.crosspost_of_id END AS crosspost_of_id,
+		(SELECT jsonb_build_object('post_id', o.post_id, 'post_url', o.post_url, 'title', o.title, 'content', o.content,
+			'content_html', COALESCE(o.content_html, ''), 'created_date', to_char(o.created_date, 'YYYY-MM-DD"T"HH24:MI:SS"Z"'),
+			'user_id', ou.user_id, 'username', ou.username, 'topic_id', ot.topic_id, 'topic_name', ot.topic_name, 'topic_url', ot.topic_url)
+			FROM posts o
+			INNER JOIN users ou ON ou.user_id = o.author_id
+			INNER JOIN topics ot ON ot.topic_id = o.topic_id
+			WHERE o.post_id = p.crosspost_of_id AND p.deleted_date IS NULL AND o.status = 'published' AND o.deleted_date IS NULL) AS crosspost_of,
+		p.crosspost_count`
+
+// the post a new crosspost links to
+type Original struct {
+	Post_ID    int
+	Topic_ID   int
+	Title      string
+	Is_NSFW    bool
+	Is_Spoiler bool
+}
+
+// get the original of a published post
+// crossposting a crosspost links to its original
+// returns pgx.ErrNoRows if either is not published or has been deleted
+func GetOriginal(ctx context.Context, q db.Querier, postID int) (Original, error) {
+	var original Original
+	err := q.QueryRow(ctx,
+		`SELECT o.post_id, o.topic_id, o.title, o.is_nsfw, o.is_spoiler
+		FROM posts p
+		INNER JOIN posts o ON o.post_id = COALESCE(p.crosspost_of_id, p.post_id)
+		WHERE p.post_id = $1
+		AND p.status = 'published' AND p.deleted_date IS NULL
+		AND o.status = 'published' AND o.deleted_date IS NULL`,
+		postID).Scan(&original.Post_ID, &original.Topic_ID, &original.Title, &original.Is_NSFW, &original.Is_Spoiler)
+	return original, err
+}
@@ -0,0 +1,64 @@
+package crossposts
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/minrui13/backend/database/dbtest"
+)
+
+func TestGetOriginal(t *testing.T) {
+	tx := dbtest.Tx(t)
+	ctx := context.Background()
+
+	var originalID, crosspostID int
+	err := tx.QueryRow(ctx,
+		`SELECT o.post_id, c.post_id FROM posts o INNER JOIN posts c ON c.topic_id <> o.topic_id
+		ORDER BY o.post_id, c.post_id LIMIT 1`).Scan(&originalID, &crosspostID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		t.Skip("the test database needs posts in two topics")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	exec := func(sql string, args ...any) {
+		t.Helper()
+		if _, err := tx.Exec(ctx, sql, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exec(`UPDATE posts SET status = 'published', deleted_date = NULL, crosspost_of_id = NULL WHERE post_id = ANY($1)`, []int{originalID, crosspostID})
+	exec(`UPDATE posts SET crosspost_of_id = $1 WHERE post_id = $2`, originalID, crosspostID)
+
+	originalOf := func(postID int, want int) {
+		t.Helper()
+		original, err := GetOriginal(ctx, tx, postID)
+		if err != nil {
+			t.Fatalf("GetOriginal(%d) = %v, want post %d", postID, err, want)
+		}
+		if original.Post_ID != want {
+			t.Errorf("GetOriginal(%d) = post %d, want post %d", postID, original.Post_ID, want)
+		}
+	}
+	notFound := func(what string, postID int) {
+		t.Helper()
+		if _, err := GetOriginal(ctx, tx, postID); !errors.Is(err, pgx.ErrNoRows) {
+			t.Errorf("GetOriginal of %s = %v, want pgx.ErrNoRows", what, err)
+		}
+	}
+
+	//a post is its own original, a crosspost links to the post it was made from
+	originalOf(originalID, originalID)
+	originalOf(crosspostID, originalID)
+
+	exec(`UPDATE posts SET deleted_date = CURRENT_TIMESTAMP WHERE post_id = $1`, originalID)
+	notFound("a crosspost of a deleted post", crosspostID)
+	exec(`UPDATE posts SET deleted_date = NULL, status = 'draft' WHERE post_id = $1`, originalID)
+	notFound("a crosspost of a draft", crosspostID)
+	exec(`UPDATE posts SET status = 'published' WHERE post_id = $1`, originalID)
+	exec(`UPDATE posts SET deleted_date = CURRENT_TIMESTAMP WHERE post_id = $1`, crosspostID)
+	notFound("a deleted crosspost", crosspostID)
+	originalOf(originalID, originalID)
+}
@@ -5,17 +5,27 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/minrui13/backend/config"
 )
 
 func Connect() (*pgxpool.Pool, error) {
+	return ConnectTo(config.Envs.DATABASE_URL)
+}
+
+// connect to the database at databaseURL, tests use it for their own database
+func ConnectTo(databaseURL string) (*pgxpool.Pool, error) {
 
 	//configure connection pool
-	connection, err := pgxpool.ParseConfig(config.Envs.DATABASE_URL)
+	connection, err := pgxpool.ParseConfig(databaseURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse DATABASE_URL: %w", err)
 	}
+	//timestamp columns keep no time zone, so every session reads and writes them in UTC
+	//CURRENT_TIMESTAMP and LOCALTIMESTAMP then match the times the server stores
+	connection.ConnConfig.RuntimeParams["timezone"] = "UTC"
 
 	//connect database
 	pool, err := pgxpool.NewWithConfig(context.Background(), connection)
@@ -25,3 +35,11 @@ func Connect() (*pgxpool.Pool, error) {
 
 	return pool, nil
 }
+
+// Querier is satisfied by both *pgxpool.Pool and pgx.Tx
+// so helpers can run inside or outside a transaction
+type Querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
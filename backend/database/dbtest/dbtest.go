@@ -0,0 +1,40 @@
+// Database for the tests that need one
+// they are skipped unless TEST_DATABASE_URL points to a database with every migration applied
+package dbtest
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	db "github.com/minrui13/backend/database"
+)
+
+// pool of the test database, closed when the test ends
+func Pool(t testing.TB) *pgxpool.Pool {
+	t.Helper()
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	pool, err := db.ConnectTo(databaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+// transaction on the test database, rolled back when the test ends so nothing it writes is kept
+func Tx(t testing.TB) pgx.Tx {
+	t.Helper()
+	pool := Pool(t)
+	tx, err := pool.Begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tx.Rollback(context.Background()) })
+	return tx
+}
@@ -0,0 +1,51 @@
+-- topic moderators, bans, rules and moderation log
+-- run against the existing database after the base schema
+
+CREATE TABLE IF NOT EXISTS topics_moderators (
+	topic_id INT NOT NULL REFERENCES topics(topic_id) ON DELETE CASCADE,
+	user_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	appointed_by INT REFERENCES users(user_id) ON DELETE SET NULL,
+	created_date TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (topic_id, user_id)
+);
+
+-- expires_date NULL means the ban is permanent
+CREATE TABLE IF NOT EXISTS topics_bans (
+	topic_ban_id SERIAL PRIMARY KEY,
+	topic_id INT NOT NULL REFERENCES topics(topic_id) ON DELETE CASCADE,
+	user_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	banned_by INT REFERENCES users(user_id) ON DELETE SET NULL,
+	reason TEXT,
+	created_date TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	expires_date TIMESTAMP,
+	UNIQUE (topic_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS topics_rules (
+	rule_id SERIAL PRIMARY KEY,
+	topic_id INT NOT NULL REFERENCES topics(topic_id) ON DELETE CASCADE,
+	position INT NOT NULL DEFAULT 0,
+	title VARCHAR(100) NOT NULL,
+	body TEXT NOT NULL DEFAULT '',
+	created_date TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS topics_rules_topic_idx ON topics_rules (topic_id, position);
+
+-- targets are kept as plain ids so the log survives the removal of the post or comment
+CREATE TABLE IF NOT EXISTS topics_moderation_log (
+	log_id SERIAL PRIMARY KEY,
+	topic_id INT NOT NULL REFERENCES topics(topic_id) ON DELETE CASCADE,
+	moderator_id INT REFERENCES users(user_id) ON DELETE SET NULL,
+	action VARCHAR(50) NOT NULL,
+	target_user_id INT,
+	target_post_id INT,
+	target_comment_id INT,
+	details TEXT,
+	created_date TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS topics_moderation_log_topic_idx ON topics_moderation_log (topic_id, created_date DESC, log_id DESC);
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS is_locked BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS is_pinned BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,28 @@
+-- topic flair, sidebar and banner content
+
+-- kind is either 'user' or 'post'
+CREATE TABLE IF NOT EXISTS topics_flairs (
+	flair_id SERIAL PRIMARY KEY,
+	topic_id INT NOT NULL REFERENCES topics(topic_id) ON DELETE CASCADE,
+	kind VARCHAR(10) NOT NULL CHECK (kind IN ('user', 'post')),
+	text VARCHAR(64) NOT NULL,
+	background_color VARCHAR(9) NOT NULL DEFAULT '#e5e7eb',
+	text_color VARCHAR(9) NOT NULL DEFAULT '#111827',
+	position INT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS topics_flairs_topic_idx ON topics_flairs (topic_id, kind, position);
+
+-- flair a user picked for themselves in a topic
+CREATE TABLE IF NOT EXISTS topics_users_flairs (
+	topic_id INT NOT NULL REFERENCES topics(topic_id) ON DELETE CASCADE,
+	user_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	flair_id INT NOT NULL REFERENCES topics_flairs(flair_id) ON DELETE CASCADE,
+	PRIMARY KEY (topic_id, user_id)
+);
+
+ALTER TABLE topics ADD COLUMN IF NOT EXISTS sidebar_markdown TEXT;
+ALTER TABLE topics ADD COLUMN IF NOT EXISTS banner_markdown TEXT;
+ALTER TABLE topics ADD COLUMN IF NOT EXISTS require_post_flair BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS post_flair_id INT REFERENCES topics_flairs(flair_id) ON DELETE SET NULL;
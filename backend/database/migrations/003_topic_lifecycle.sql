@@ -0,0 +1,6 @@
+-- topic ownership transfer and archival
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- archived topics stay readable but take no new posts, comments or votes
+ALTER TABLE topics ADD COLUMN IF NOT EXISTS archived_date TIMESTAMP;
@@ -0,0 +1,17 @@
+-- old topic and post urls so shared links keep working after a rename
+
+CREATE TABLE IF NOT EXISTS topics_url_history (
+	old_url VARCHAR(255) PRIMARY KEY,
+	topic_id INT NOT NULL REFERENCES topics(topic_id) ON DELETE CASCADE,
+	created_date TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS topics_url_history_topic_idx ON topics_url_history (topic_id);
+
+CREATE TABLE IF NOT EXISTS posts_url_history (
+	old_url VARCHAR(255) PRIMARY KEY,
+	post_id INT NOT NULL REFERENCES posts(post_id) ON DELETE CASCADE,
+	created_date TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS posts_url_history_post_idx ON posts_url_history (post_id);
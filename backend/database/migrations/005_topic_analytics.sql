@@ -0,0 +1,24 @@
+-- topic analytics for creators
+-- activity tables need a date to be counted per day
+-- rows from before this migration have no known date and stay null, analytics and trending leave them out
+-- the default is set after the column is added so it only applies to new rows
+
+ALTER TABLE topics_followers ADD COLUMN IF NOT EXISTS created_date TIMESTAMP;
+ALTER TABLE topics_followers ALTER COLUMN created_date SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE posts_votes ADD COLUMN IF NOT EXISTS created_date TIMESTAMP;
+ALTER TABLE posts_votes ALTER COLUMN created_date SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE comments_votes ADD COLUMN IF NOT EXISTS created_date TIMESTAMP;
+ALTER TABLE comments_votes ALTER COLUMN created_date SET DEFAULT CURRENT_TIMESTAMP;
+
+-- one row per topic per day, filled by the rollup job and on demand by the analytics endpoint
+CREATE TABLE IF NOT EXISTS topics_daily_stats (
+	topic_id INT NOT NULL REFERENCES topics(topic_id) ON DELETE CASCADE,
+	day DATE NOT NULL,
+	new_followers INT NOT NULL DEFAULT 0,
+	posts INT NOT NULL DEFAULT 0,
+	comments INT NOT NULL DEFAULT 0,
+	votes INT NOT NULL DEFAULT 0,
+	active_users INT NOT NULL DEFAULT 0,
+	updated_date TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (topic_id, day)
+);
@@ -0,0 +1,9 @@
+-- post drafts and scheduled publishing
+-- only published posts show up in feeds, created_date is set to the publish time
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'published'
+	CHECK (status IN ('draft', 'scheduled', 'published'));
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS scheduled_date TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS posts_scheduled_idx ON posts (scheduled_date) WHERE status = 'scheduled';
+CREATE INDEX IF NOT EXISTS posts_author_unpublished_idx ON posts (author_id) WHERE status <> 'published';
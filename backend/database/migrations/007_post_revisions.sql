@@ -0,0 +1,17 @@
+-- post edit history
+-- every version of a post is kept, revision 1 is the original
+
+CREATE TABLE IF NOT EXISTS posts_revisions (
+	revision_id SERIAL PRIMARY KEY,
+	post_id INT NOT NULL REFERENCES posts(post_id) ON DELETE CASCADE,
+	revision_number INT NOT NULL,
+	tag_id INT,
+	title TEXT NOT NULL,
+	content TEXT NOT NULL,
+	created_date TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (post_id, revision_number)
+);
+
+-- edited_date stays NULL for edits inside the grace window
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS edited_date TIMESTAMP;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS edit_count INT NOT NULL DEFAULT 0;
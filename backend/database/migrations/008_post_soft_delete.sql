@@ -0,0 +1,10 @@
+-- soft delete for posts
+-- deleted posts keep their comment thread, title and content are hidden from readers
+-- the purge job removes them for good after the retention period
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS deleted_date TIMESTAMP;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS deleted_by VARCHAR(20)
+	CHECK (deleted_by IN ('author', 'moderator'));
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS deleted_by_user_id INT REFERENCES users(user_id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS posts_deleted_idx ON posts (deleted_date) WHERE deleted_date IS NOT NULL;
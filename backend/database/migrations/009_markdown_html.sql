@@ -0,0 +1,10 @@
+-- rendered markdown cached next to the source
+-- content_html_version is the renderer version, rows with an older or no version are rendered again by the background job
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS content_html TEXT;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS content_html_version INT;
+
+ALTER TABLE posts_comments ADD COLUMN IF NOT EXISTS content_html TEXT;
+ALTER TABLE posts_comments ADD COLUMN IF NOT EXISTS content_html_version INT;
+
+ALTER TABLE posts_revisions ADD COLUMN IF NOT EXISTS content_html TEXT;
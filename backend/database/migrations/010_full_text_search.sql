@@ -0,0 +1,17 @@
+-- full text search over posts and comments
+-- titles are weighted above content so title matches rank first
+-- the vectors are generated columns so they never go stale
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS search_vector tsvector
+	GENERATED ALWAYS AS (
+		setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+		setweight(to_tsvector('english', COALESCE(content, '')), 'B')
+	) STORED;
+
+ALTER TABLE posts_comments ADD COLUMN IF NOT EXISTS search_vector tsvector
+	GENERATED ALWAYS AS (
+		setweight(to_tsvector('english', COALESCE(content, '')), 'C')
+	) STORED;
+
+CREATE INDEX IF NOT EXISTS posts_search_idx ON posts USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS posts_comments_search_idx ON posts_comments USING GIN (search_vector);
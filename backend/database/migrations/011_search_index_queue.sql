@@ -0,0 +1,44 @@
+-- changes waiting to be applied to the embedded search index (SEARCH_BACKEND=bleve)
+-- filled by triggers so every writer is covered, the api as well as the background jobs
+-- comment changes queue their post since comments are indexed as part of the post
+-- topics are queued on rename and visibility changes, new topics have no posts yet and deleting one deletes its posts
+
+CREATE TABLE IF NOT EXISTS search_index_queue (
+	queue_id BIGSERIAL PRIMARY KEY,
+	entity VARCHAR(20) NOT NULL CHECK (entity IN ('post', 'topic')),
+	entity_id INT NOT NULL,
+	created_date TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE OR REPLACE FUNCTION queue_post_search() RETURNS TRIGGER AS $$
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		INSERT INTO search_index_queue (entity, entity_id) VALUES ('post', OLD.post_id);
+	ELSE
+		INSERT INTO search_index_queue (entity, entity_id) VALUES ('post', NEW.post_id);
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION queue_topic_search() RETURNS TRIGGER AS $$
+BEGIN
+	INSERT INTO search_index_queue (entity, entity_id) VALUES ('topic', NEW.topic_id);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_search_queue ON posts;
+CREATE TRIGGER posts_search_queue
+	AFTER INSERT OR DELETE OR UPDATE OF title, content, status, deleted_date, topic_id, tag_id ON posts
+	FOR EACH ROW EXECUTE FUNCTION queue_post_search();
+
+DROP TRIGGER IF EXISTS posts_comments_search_queue ON posts_comments;
+CREATE TRIGGER posts_comments_search_queue
+	AFTER INSERT OR DELETE OR UPDATE OF content ON posts_comments
+	FOR EACH ROW EXECUTE FUNCTION queue_post_search();
+
+DROP TRIGGER IF EXISTS topics_search_queue ON topics;
+CREATE TRIGGER topics_search_queue
+	AFTER UPDATE OF topic_name, visibility ON topics
+	FOR EACH ROW EXECUTE FUNCTION queue_topic_search();
@@ -0,0 +1,91 @@
+-- vote, comment and reply counters stored on the rows they count
+-- kept up to date by triggers in the same transaction as the vote or comment
+-- the counter reconciliation job repairs any drift, e.g. from rows changed with triggers disabled
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS upvote_count INT NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS downvote_count INT NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS comment_count INT NOT NULL DEFAULT 0;
+
+ALTER TABLE posts_comments ADD COLUMN IF NOT EXISTS upvote_count INT NOT NULL DEFAULT 0;
+ALTER TABLE posts_comments ADD COLUMN IF NOT EXISTS downvote_count INT NOT NULL DEFAULT 0;
+ALTER TABLE posts_comments ADD COLUMN IF NOT EXISTS reply_count INT NOT NULL DEFAULT 0;
+
+CREATE OR REPLACE FUNCTION count_post_votes() RETURNS TRIGGER AS $$
+BEGIN
+	IF TG_OP IN ('UPDATE', 'DELETE') THEN
+		UPDATE posts SET
+			upvote_count = upvote_count - (OLD.vote_type = 1)::int,
+			downvote_count = downvote_count - (OLD.vote_type = -1)::int
+		WHERE post_id = OLD.post_id;
+	END IF;
+	IF TG_OP IN ('INSERT', 'UPDATE') THEN
+		UPDATE posts SET
+			upvote_count = upvote_count + (NEW.vote_type = 1)::int,
+			downvote_count = downvote_count + (NEW.vote_type = -1)::int
+		WHERE post_id = NEW.post_id;
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION count_comment_votes() RETURNS TRIGGER AS $$
+BEGIN
+	IF TG_OP IN ('UPDATE', 'DELETE') THEN
+		UPDATE posts_comments SET
+			upvote_count = upvote_count - (OLD.vote_type = 1)::int,
+			downvote_count = downvote_count - (OLD.vote_type = -1)::int
+		WHERE comment_id = OLD.comment_id;
+	END IF;
+	IF TG_OP IN ('INSERT', 'UPDATE') THEN
+		UPDATE posts_comments SET
+			upvote_count = upvote_count + (NEW.vote_type = 1)::int,
+			downvote_count = downvote_count + (NEW.vote_type = -1)::int
+		WHERE comment_id = NEW.comment_id;
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- comment_count counts replies too, reply_count only direct replies
+CREATE OR REPLACE FUNCTION count_comments() RETURNS TRIGGER AS $$
+BEGIN
+	IF TG_OP = 'INSERT' THEN
+		UPDATE posts SET comment_count = comment_count + 1 WHERE post_id = NEW.post_id;
+		IF NEW.parent_comment_id IS NOT NULL THEN
+			UPDATE posts_comments SET reply_count = reply_count + 1 WHERE comment_id = NEW.parent_comment_id;
+		END IF;
+	ELSE
+		UPDATE posts SET comment_count = comment_count - 1 WHERE post_id = OLD.post_id;
+		IF OLD.parent_comment_id IS NOT NULL THEN
+			UPDATE posts_comments SET reply_count = reply_count - 1 WHERE comment_id = OLD.parent_comment_id;
+		END IF;
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_votes_count ON posts_votes;
+CREATE TRIGGER posts_votes_count
+	AFTER INSERT OR DELETE OR UPDATE OF vote_type, post_id ON posts_votes
+	FOR EACH ROW EXECUTE FUNCTION count_post_votes();
+
+DROP TRIGGER IF EXISTS comments_votes_count ON comments_votes;
+CREATE TRIGGER comments_votes_count
+	AFTER INSERT OR DELETE OR UPDATE OF vote_type, comment_id ON comments_votes
+	FOR EACH ROW EXECUTE FUNCTION count_comment_votes();
+
+DROP TRIGGER IF EXISTS posts_comments_count ON posts_comments;
+CREATE TRIGGER posts_comments_count
+	AFTER INSERT OR DELETE ON posts_comments
+	FOR EACH ROW EXECUTE FUNCTION count_comments();
+
+-- fill the counters for existing rows, the triggers keep them from here on
+UPDATE posts p SET
+	upvote_count = (SELECT COUNT(*) FROM posts_votes pv WHERE pv.post_id = p.post_id AND pv.vote_type = 1),
+	downvote_count = (SELECT COUNT(*) FROM posts_votes pv WHERE pv.post_id = p.post_id AND pv.vote_type = -1),
+	comment_count = (SELECT COUNT(*) FROM posts_comments pc WHERE pc.post_id = p.post_id);
+
+UPDATE posts_comments c SET
+	upvote_count = (SELECT COUNT(*) FROM comments_votes cv WHERE cv.comment_id = c.comment_id AND cv.vote_type = 1),
+	downvote_count = (SELECT COUNT(*) FROM comments_votes cv WHERE cv.comment_id = c.comment_id AND cv.vote_type = -1),
+	reply_count = (SELECT COUNT(*) FROM posts_comments r WHERE r.parent_comment_id = c.comment_id);
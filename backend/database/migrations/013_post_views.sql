@@ -0,0 +1,16 @@
+-- post views, counted at most once per viewer every 30 minutes
+-- a viewer is a user or a fingerprint of an anonymous visitor, see the views package
+-- view_count counts views, viewer_count distinct viewers
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS view_count INT NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS viewer_count INT NOT NULL DEFAULT 0;
+
+-- last counted view of every viewer of a post
+CREATE TABLE IF NOT EXISTS posts_viewers (
+	post_id INT NOT NULL REFERENCES posts(post_id) ON DELETE CASCADE,
+	viewer VARCHAR(80) NOT NULL,
+	last_counted_date TIMESTAMP NOT NULL,
+	PRIMARY KEY (post_id, viewer)
+);
+
+CREATE INDEX IF NOT EXISTS posts_view_count_idx ON posts (view_count DESC, post_id DESC);
@@ -0,0 +1,32 @@
+-- poll posts, a post with 2 to 10 options that users vote on once
+-- tallies are kept on the options and the poll in the same transaction as the vote
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS post_type VARCHAR(20) NOT NULL DEFAULT 'text';
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS posts_post_type_check;
+ALTER TABLE posts ADD CONSTRAINT posts_post_type_check CHECK (post_type IN ('text', 'poll'));
+
+CREATE TABLE IF NOT EXISTS posts_polls (
+	post_id INT PRIMARY KEY REFERENCES posts(post_id) ON DELETE CASCADE,
+	allow_multiple BOOLEAN NOT NULL DEFAULT FALSE,
+	-- null keeps the poll open
+	closes_date TIMESTAMP,
+	voter_count INT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS posts_polls_options (
+	option_id SERIAL PRIMARY KEY,
+	post_id INT NOT NULL REFERENCES posts_polls(post_id) ON DELETE CASCADE,
+	position INT NOT NULL,
+	text VARCHAR(200) NOT NULL,
+	vote_count INT NOT NULL DEFAULT 0,
+	UNIQUE (post_id, position)
+);
+
+-- one ballot per user and poll, holding every option they picked
+CREATE TABLE IF NOT EXISTS posts_polls_votes (
+	post_id INT NOT NULL REFERENCES posts_polls(post_id) ON DELETE CASCADE,
+	user_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	option_ids INT[] NOT NULL,
+	created_date TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (post_id, user_id)
+);
@@ -0,0 +1,12 @@
+-- pinned posts show above a topic's feed, announcements above the site-wide feeds
+-- both are left out of the paged results so pages do not repeat them
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS pinned_date TIMESTAMP;
+-- set by an admin, null when the post is not an announcement
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS announced_date TIMESTAMP;
+
+-- posts pinned before pins were dated keep their creation order
+UPDATE posts SET pinned_date = created_date WHERE is_pinned AND pinned_date IS NULL;
+
+CREATE INDEX IF NOT EXISTS posts_pinned_idx ON posts (topic_id, pinned_date DESC) WHERE is_pinned;
+CREATE INDEX IF NOT EXISTS posts_announced_idx ON posts (announced_date DESC) WHERE announced_date IS NOT NULL;
@@ -0,0 +1,5 @@
+-- a locked comment stops new replies and votes anywhere under it
+ALTER TABLE posts_comments ADD COLUMN IF NOT EXISTS is_locked BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- a read-only topic only takes posts, comments and votes from its moderators
+ALTER TABLE topics ADD COLUMN IF NOT EXISTS is_read_only BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,18 @@
+-- nsfw and spoiler flags on posts, set by the author or a moderator
+-- posts of a topic with is_nsfw are always nsfw
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS is_nsfw BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS is_spoiler BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE topics ADD COLUMN IF NOT EXISTS is_nsfw BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- hide leaves nsfw posts out of the feeds, blur shows them flagged to be blurred, show shows them as is
+ALTER TABLE users ADD COLUMN IF NOT EXISTS nsfw_preference VARCHAR(10) NOT NULL DEFAULT 'hide';
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_nsfw_preference_check;
+ALTER TABLE users ADD CONSTRAINT users_nsfw_preference_check CHECK (nsfw_preference IN ('hide', 'blur', 'show'));
+ALTER TABLE users ADD COLUMN IF NOT EXISTS blur_spoilers BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- search leaves nsfw posts out by the viewer's preference, so the embedded index keeps the flag (see 011)
+DROP TRIGGER IF EXISTS posts_search_queue ON posts;
+CREATE TRIGGER posts_search_queue
+	AFTER INSERT OR DELETE OR UPDATE OF title, content, status, deleted_date, topic_id, tag_id, is_nsfw ON posts
+	FOR EACH ROW EXECUTE FUNCTION queue_post_search();
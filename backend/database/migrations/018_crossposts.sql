@@ -0,0 +1,27 @@
+-- a crosspost is a post in another topic linking to the original post
+-- it has its own votes and comments, the original counts its crossposts
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS crosspost_of_id INT REFERENCES posts(post_id) ON DELETE SET NULL;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS crosspost_count INT NOT NULL DEFAULT 0;
+
+-- a post is crossposted into a topic once
+CREATE UNIQUE INDEX IF NOT EXISTS posts_crosspost_topic_idx ON posts (crosspost_of_id, topic_id)
+	WHERE crosspost_of_id IS NOT NULL AND deleted_date IS NULL;
+
+-- crosspost_count leaves out deleted crossposts
+CREATE OR REPLACE FUNCTION count_crossposts() RETURNS TRIGGER AS $$
+BEGIN
+	IF TG_OP IN ('UPDATE', 'DELETE') AND OLD.crosspost_of_id IS NOT NULL AND OLD.deleted_date IS NULL THEN
+		UPDATE posts SET crosspost_count = crosspost_count - 1 WHERE post_id = OLD.crosspost_of_id;
+	END IF;
+	IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.crosspost_of_id IS NOT NULL AND NEW.deleted_date IS NULL THEN
+		UPDATE posts SET crosspost_count = crosspost_count + 1 WHERE post_id = NEW.crosspost_of_id;
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_crossposts_count ON posts;
+CREATE TRIGGER posts_crossposts_count
+	AFTER INSERT OR DELETE OR UPDATE OF crosspost_of_id, deleted_date ON posts
+	FOR EACH ROW EXECUTE FUNCTION count_crossposts();
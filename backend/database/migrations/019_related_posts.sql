@@ -0,0 +1,6 @@
+-- related posts take the newest published posts of the same topic and tag as candidates
+
+CREATE INDEX IF NOT EXISTS posts_topic_created_idx ON posts (topic_id, created_date DESC)
+	WHERE status = 'published' AND deleted_date IS NULL;
+CREATE INDEX IF NOT EXISTS posts_tag_created_idx ON posts (tag_id, created_date DESC)
+	WHERE status = 'published' AND deleted_date IS NULL;
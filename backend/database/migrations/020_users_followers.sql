@@ -0,0 +1,13 @@
+-- users following other users, their posts are a source of the home feed
+
+CREATE TABLE IF NOT EXISTS users_followers (
+	user_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	followed_user_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	created_date TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (user_id, followed_user_id),
+	CHECK (user_id <> followed_user_id)
+);
+
+CREATE INDEX IF NOT EXISTS users_followers_followed_idx ON users_followers (followed_user_id);
+CREATE INDEX IF NOT EXISTS posts_author_created_idx ON posts (author_id, created_date DESC)
+	WHERE status = 'published' AND deleted_date IS NULL;
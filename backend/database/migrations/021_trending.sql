@@ -0,0 +1,33 @@
+-- trending topics and tags, recomputed by the trending job
+-- activity of the last day is compared with the daily rate of the week before it
+
+CREATE TABLE IF NOT EXISTS topics_trending (
+	topic_id INT PRIMARY KEY REFERENCES topics(topic_id) ON DELETE CASCADE,
+	follows INT NOT NULL DEFAULT 0,
+	posts INT NOT NULL DEFAULT 0,
+	votes INT NOT NULL DEFAULT 0,
+	baseline_follows INT NOT NULL DEFAULT 0,
+	baseline_posts INT NOT NULL DEFAULT 0,
+	baseline_votes INT NOT NULL DEFAULT 0,
+	score FLOAT8 NOT NULL,
+	updated_date TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS tags_trending (
+	tag_id INT PRIMARY KEY REFERENCES tags(tag_id) ON DELETE CASCADE,
+	posts INT NOT NULL DEFAULT 0,
+	votes INT NOT NULL DEFAULT 0,
+	baseline_posts INT NOT NULL DEFAULT 0,
+	baseline_votes INT NOT NULL DEFAULT 0,
+	score FLOAT8 NOT NULL,
+	updated_date TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS topics_trending_score_idx ON topics_trending (score DESC, topic_id DESC);
+CREATE INDEX IF NOT EXISTS tags_trending_score_idx ON tags_trending (score DESC, tag_id DESC);
+
+-- the job only reads the activity of the last eight days
+CREATE INDEX IF NOT EXISTS topics_followers_created_idx ON topics_followers (created_date);
+CREATE INDEX IF NOT EXISTS posts_votes_created_idx ON posts_votes (created_date);
+CREATE INDEX IF NOT EXISTS posts_created_idx ON posts (created_date)
+	WHERE status = 'published' AND deleted_date IS NULL;
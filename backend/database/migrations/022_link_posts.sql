@@ -0,0 +1,17 @@
+-- link posts, the preview of the page is read by the server when the post is added
+
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS posts_post_type_check;
+ALTER TABLE posts ADD CONSTRAINT posts_post_type_check CHECK (post_type IN ('text', 'poll', 'link'));
+
+CREATE TABLE IF NOT EXISTS posts_links (
+	post_id INT PRIMARY KEY REFERENCES posts(post_id) ON DELETE CASCADE,
+	url TEXT NOT NULL,
+	domain VARCHAR(255) NOT NULL,
+	-- null when the page could not be fetched
+	final_url TEXT,
+	title VARCHAR(300),
+	description VARCHAR(1000),
+	image_url TEXT,
+	site_name VARCHAR(300),
+	fetched_date TIMESTAMP
+);
@@ -0,0 +1,22 @@
+-- the ranked home feed of one scroll, so pages do not shift when votes and comments come in
+-- each page appends the next candidates in rank order, the snapshot cleanup job removes them after a day
+
+CREATE TABLE IF NOT EXISTS feed_snapshots (
+	snapshot_id BIGSERIAL PRIMARY KEY,
+	user_id INT NOT NULL,
+	-- scores and candidates are taken at this time
+	as_of TIMESTAMP NOT NULL,
+	created_date TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS feed_snapshots_created_idx ON feed_snapshots (created_date);
+
+CREATE TABLE IF NOT EXISTS feed_snapshots_posts (
+	snapshot_id BIGINT NOT NULL REFERENCES feed_snapshots(snapshot_id) ON DELETE CASCADE,
+	position INT NOT NULL,
+	post_id INT NOT NULL,
+	topic_id INT NOT NULL,
+	score FLOAT8 NOT NULL,
+	PRIMARY KEY (snapshot_id, position),
+	UNIQUE (snapshot_id, post_id)
+);
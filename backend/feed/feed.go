@@ -0,0 +1,365 @@
+// Home feed, candidate posts from several sources scored, mixed and paged
+// each source suggests posts with a strength between 0 and 1, a post's score is the weighted sum of
+// its strengths plus its popularity minus its age, so posts suggested by several sources rank higher
+package feed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minrui13/backend/cursor"
+	db "github.com/minrui13/backend/database"
+	"github.com/minrui13/backend/jobs"
+	"github.com/minrui13/backend/nsfw"
+	"github.com/minrui13/backend/pins"
+	"github.com/minrui13/backend/ranking"
+	"github.com/minrui13/backend/types"
+)
+
+// candidate sources
+const (
+	SourceFollowedTopics = "followed_topics"
+	SourceFollowedUsers  = "followed_users"
+	SourceSameCategory   = "same_category"
+	SourceTrending       = "trending"
+)
+
+// scoring terms that are not sources
+const (
+	WeightPopularity = "popularity"
+	WeightAge        = "age"
+)
+
+// how far back trending looks and how many posts it suggests
+const (
+	trendingWindow = "2 days"
+	trendingPosts  = 200
+)
+
+// candidates ranked for a page, of which limit are shown
+const poolFactor = 3
+
+// fewest candidates ranked into a snapshot at a time, and how long a snapshot is kept
+const (
+	snapshotChunk    = 100
+	snapshotLifetime = "1 day"
+)
+
+var (
+	ErrInvalidWeights = errors.New("feed weights must look like followed_topics=3,trending=1.5")
+	ErrCursorExpired  = errors.New("feed cursor has expired, load the feed again")
+)
+
+// a source of candidate posts
+// SQL selects post_id and strength, the viewer's user id is $1 and the time the scores are taken at $2
+type Source struct {
+	Name string
+	SQL  string
+}
+
+// the sources of the home feed
+var Sources = []Source{
+	{
+		Name: SourceFollowedTopics,
+		SQL: `SELECT p.post_id, 1.0 AS strength
+			FROM posts p
+			INNER JOIN topics_followers tf ON tf.topic_id = p.topic_id
+			WHERE tf.user_id = $1`,
+	},
+	{
+		//only in public topics or topics the viewer follows, a followed user's private topics stay private
+		Name: SourceFollowedUsers,
+		SQL: `SELECT p.post_id, 1.0 AS strength
+			FROM posts p
+			INNER JOIN users_followers uf ON uf.followed_user_id = p.author_id
+			INNER JOIN topics t ON t.topic_id = p.topic_id
+			WHERE uf.user_id = $1
+			AND (t.visibility = 'public' OR EXISTS (SELECT 1 FROM topics_followers tf WHERE tf.topic_id = p.topic_id AND tf.user_id = $1))`,
+	},
+	{
+		//public topics in the categories of the followed topics, the followed topics themselves are their own source
+		Name: SourceSameCategory,
+		SQL: `SELECT p.post_id, 1.0 AS strength
+			FROM posts p
+			INNER JOIN topics t ON t.topic_id = p.topic_id
+			WHERE t.visibility = 'public'
+			AND t.category_id IN (
+				SELECT ft.category_id FROM topics ft
+				INNER JOIN topics_followers tf ON tf.topic_id = ft.topic_id
+				WHERE tf.user_id = $1
+			)
+			AND NOT EXISTS (SELECT 1 FROM topics_followers tf WHERE tf.topic_id = p.topic_id AND tf.user_id = $1)`,
+	},
+	{
+		//the most engaging recent public posts, stronger the more engaging
+		Name: SourceTrending,
+		SQL: `SELECT post_id, PERCENT_RANK() OVER (ORDER BY engagement) AS strength
+			FROM (
+				SELECT p.post_id, GREATEST(p.upvote_count - p.downvote_count, 0) + p.comment_count AS engagement
+				FROM posts p
+				INNER JOIN topics t ON t.topic_id = p.topic_id
+				WHERE t.visibility = 'public' AND p.status = 'published' AND p.deleted_date IS NULL
+				AND p.created_date <= $2 AND p.created_date > $2 - INTERVAL '` + trendingWindow + `'
+				AND GREATEST(p.upvote_count - p.downvote_count, 0) + p.comment_count > 0
+				ORDER BY engagement DESC, p.post_id DESC
+				LIMIT ` + strconv.Itoa(trendingPosts) + `
+			) top`,
+	},
+}
+
+// weights of the sources and of popularity and age
+// popularity is per order of magnitude of votes and comments, age per day
+type Weights map[string]float64
+
+var DefaultWeights = Weights{
+	SourceFollowedTopics: 3,
+	SourceFollowedUsers:  2.5,
+	SourceSameCategory:   1,
+	SourceTrending:       1.5,
+	WeightPopularity:     1,
+	WeightAge:            1,
+}
+
+// read weights like "followed_topics=3,trending=1.5" over the defaults
+func ParseWeights(s string) (Weights, error) {
+	weights := Weights{}
+	for name, weight := range DefaultWeights {
+		weights[name] = weight
+	}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, ErrInvalidWeights
+		}
+		name = strings.TrimSpace(name)
+		if _, known := DefaultWeights[name]; !known {
+			return nil, fmt.Errorf("%w: unknown weight %q", ErrInvalidWeights, name)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, ErrInvalidWeights
+		}
+		//the weights are written into the feed query, where NaN and Inf are not numbers
+		//0 turns a source or term off, a negative age weight would favour old posts
+		if math.IsNaN(weight) || math.IsInf(weight, 0) || weight < 0 {
+			return nil, fmt.Errorf("%w: %s must be a number of 0 or more", ErrInvalidWeights, name)
+		}
+		weights[name] = weight
+	}
+	return weights, nil
+}
+
+// a candidate post ranked for the feed
+type Candidate struct {
+	Post_ID  int
+	Topic_ID int
+	Score    float64
+	//place in the snapshot, the rank order of the scroll
+	Position int
+}
+
+type Pipeline struct {
+	sources     []Source
+	weights     Weights
+	maxPerTopic int
+}
+
+// pipeline over the given sources, a page shows at most maxPerTopic posts of a topic
+func New(sources []Source, weights Weights, maxPerTopic int) *Pipeline {
+	return &Pipeline{sources: sources, weights: weights, maxPerTopic: max(maxPerTopic, 1)}
+}
+
+// the candidates of every source not yet in the snapshot, scored and appended to it in rank order
+// $3 is the snapshot, $4 its last position, $5 how many candidates to append
+// $6 and $7 the created date range
+func (p *Pipeline) query() string {
+	sourceSQL := make([]string, 0, len(p.sources))
+	for _, source := range p.sources {
+		sourceSQL = append(sourceSQL, fmt.Sprintf(`SELECT s.post_id, s.strength::float8 * %s AS relevance FROM (%s) s`,
+			strconv.FormatFloat(p.weights[source.Name], 'g', -1, 64), source.SQL))
+	}
+
+	score := fmt.Sprintf(`(c.relevance
+		+ %s * LOG(1 + GREATEST(p.upvote_count - p.downvote_count, 0) + p.comment_count)
+		- %s * EXTRACT(EPOCH FROM ($2::timestamp - p.created_date))::float8 / 86400)::float8`,
+		strconv.FormatFloat(p.weights[WeightPopularity], 'g', -1, 64), strconv.FormatFloat(p.weights[WeightAge], 'g', -1, 64))
+
+	return `WITH candidates AS (
+			SELECT post_id, SUM(relevance) AS relevance
+			FROM (` + strings.Join(sourceSQL, "\n\t\t\tUNION ALL\n\t\t\t") + `) sources
+			GROUP BY post_id
+		),
+		scored AS (
+			SELECT p.post_id, p.topic_id, ` + score + ` AS score
+			FROM candidates c
+			INNER JOIN posts p ON p.post_id = c.post_id
+			WHERE p.status = 'published' AND p.deleted_date IS NULL AND p.created_date <= $2::timestamp` + pins.NotAnnouncement + nsfw.Visible + ranking.DateRangeWhere(6) + `
+			AND NOT EXISTS (SELECT 1 FROM feed_snapshots_posts fs WHERE fs.snapshot_id = $3 AND fs.post_id = p.post_id)
+		)
+		INSERT INTO feed_snapshots_posts (snapshot_id, position, post_id, topic_id, score)
+		SELECT $3, $4::int + ROW_NUMBER() OVER (ORDER BY score DESC, post_id DESC), post_id, topic_id, score
+		FROM (SELECT post_id, topic_id, score FROM scored ORDER BY score DESC, post_id DESC LIMIT $5) top`
+}
+
+// the next page of the user's feed within the date range, decodedCursor is nil for the first page
+// run it in a transaction, the snapshot is locked while a page is taken from it
+// returns the posts in feed order and the cursor of the next page, nil on the last page
+// returns ErrCursorExpired when the snapshot of the cursor is gone
+func (p *Pipeline) Page(ctx context.Context, q db.Querier, userID int, limit int, dateRange types.PostByFollowPayload, decodedCursor *types.FeedCursor) ([]Candidate, *string, error) {
+	after := types.FeedCursor{Deferred: []int{}}
+	var asOf time.Time
+	if decodedCursor == nil {
+		err := q.QueryRow(ctx,
+			`INSERT INTO feed_snapshots (user_id, as_of) VALUES ($1, LOCALTIMESTAMP) RETURNING snapshot_id, as_of`,
+			userID).Scan(&after.Snapshot_ID, &asOf)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		after = *decodedCursor
+		if after.Deferred == nil {
+			after.Deferred = []int{}
+		}
+		err := q.QueryRow(ctx,
+			`SELECT as_of FROM feed_snapshots WHERE snapshot_id = $1 AND user_id = $2 FOR UPDATE`,
+			after.Snapshot_ID, userID).Scan(&asOf)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, ErrCursorExpired
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	//the pool is the deferred posts topped up with new ones, one extra to know if there are more
+	//short pages defer more than they take, so no new ones are added once poolSize are deferred
+	poolSize := limit * poolFactor
+	newCount := max(poolSize-len(after.Deferred), 0)
+
+	//rank more candidates into the snapshot when it runs short
+	//posts already in it keep their place, so a page never repeats or skips one
+	var lastPosition int
+	if err := q.QueryRow(ctx,
+		`SELECT COALESCE(MAX(position), 0) FROM feed_snapshots_posts WHERE snapshot_id = $1`,
+		after.Snapshot_ID).Scan(&lastPosition); err != nil {
+		return nil, nil, err
+	}
+	if missing := newCount + 1 - (lastPosition - after.Position); missing > 0 {
+		_, err := q.Exec(ctx, p.query(), userID, asOf, after.Snapshot_ID, lastPosition, max(missing, snapshotChunk),
+			dateRange.From_Date, dateRange.To_Date)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	//posts deleted since they were ranked are left out when the page is loaded
+	rows, err := q.Query(ctx,
+		`(SELECT post_id, topic_id, score, position FROM feed_snapshots_posts
+			WHERE snapshot_id = $1 AND post_id = ANY($2::int[]))
+		UNION ALL
+		(SELECT post_id, topic_id, score, position FROM feed_snapshots_posts
+			WHERE snapshot_id = $1 AND position > $3 AND NOT (post_id = ANY($2::int[]))
+			ORDER BY position
+			LIMIT $4)`,
+		after.Snapshot_ID, after.Deferred, after.Position, newCount+1)
+	if err != nil {
+		return nil, nil, err
+	}
+	candidates, err := pgx.CollectRows(rows, pgx.RowToStructByPos[Candidate])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	//split the deferred posts from the new ones, they come first in the query
+	deferred := map[int]bool{}
+	for _, id := range after.Deferred {
+		deferred[id] = true
+	}
+	var pool, fresh []Candidate
+	for _, candidate := range candidates {
+		if deferred[candidate.Post_ID] {
+			pool = append(pool, candidate)
+		} else {
+			fresh = append(fresh, candidate)
+		}
+	}
+	hasMore := len(fresh) > newCount
+	if hasMore {
+		fresh = fresh[:newCount]
+	}
+	pool = append(pool, fresh...)
+	slices.SortFunc(pool, func(a, b Candidate) int {
+		return a.Position - b.Position
+	})
+
+	page, rest := p.diversify(pool, limit)
+
+	//the cursor moves past every new candidate, the ones not shown are carried in it
+	next := types.FeedCursor{Snapshot_ID: after.Snapshot_ID, Position: after.Position, Deferred: []int{}}
+	if len(fresh) > 0 {
+		next.Position = fresh[len(fresh)-1].Position
+	}
+	for _, candidate := range rest {
+		next.Deferred = append(next.Deferred, candidate.Post_ID)
+	}
+	if !hasMore && len(next.Deferred) == 0 {
+		return page, nil, nil
+	}
+	c, err := cursor.EncodeFeedCursor(next)
+	if err != nil {
+		return nil, nil, err
+	}
+	return page, &c, nil
+}
+
+// remove the snapshots of scrolls older than snapshotLifetime, their cursors expire with them
+var CleanupJob = jobs.Job{
+	Name:     "feed snapshot cleanup",
+	Interval: time.Hour,
+	Run: func(ctx context.Context, pool *pgxpool.Pool) error {
+		_, err := pool.Exec(ctx, `DELETE FROM feed_snapshots WHERE created_date < LOCALTIMESTAMP - INTERVAL '`+snapshotLifetime+`'`)
+		return err
+	},
+}
+
+// take limit posts in score order with at most maxPerTopic of a topic
+// the page is short when the pool has too few other topics, the rest wait for a later page
+// returns the page in score order and the posts left over
+func (p *Pipeline) diversify(pool []Candidate, limit int) ([]Candidate, []Candidate) {
+	taken := make([]bool, len(pool))
+	perTopic := map[int]int{}
+	count := 0
+	for i, candidate := range pool {
+		if count == limit {
+			break
+		}
+		if perTopic[candidate.Topic_ID] < p.maxPerTopic {
+			taken[i] = true
+			perTopic[candidate.Topic_ID]++
+			count++
+		}
+	}
+
+	page := make([]Candidate, 0, count)
+	rest := []Candidate{}
+	for i, candidate := range pool {
+		if taken[i] {
+			page = append(page, candidate)
+		} else {
+			rest = append(rest, candidate)
+		}
+	}
+	return page, rest
+}
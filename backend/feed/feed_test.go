@@ -0,0 +1,93 @@
+package feed
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestParseWeights(t *testing.T) {
+	overridden := maps.Clone(DefaultWeights)
+	overridden[SourceFollowedTopics] = 5
+	overridden[SourceTrending] = 0
+
+	tests := []struct {
+		input string
+		want  Weights
+	}{
+		{"", DefaultWeights},
+		{" , ", DefaultWeights},
+		{"followed_topics=5, trending = 0", overridden},
+		{"age=1", DefaultWeights},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseWeights(tt.input)
+		if err != nil {
+			t.Errorf("ParseWeights(%q) returned %v", tt.input, err)
+			continue
+		}
+		if !maps.Equal(got, tt.want) {
+			t.Errorf("ParseWeights(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{
+		"followed_topics", "followed_topics=lots", "votes=2", "followed_topics=1,=2",
+		"trending=NaN", "age=Inf", "popularity=-Inf", "same_category=-1", "followed_users=1e400"} {
+		if _, err := ParseWeights(input); !errors.Is(err, ErrInvalidWeights) {
+			t.Errorf("ParseWeights(%q) returned %v, want ErrInvalidWeights", input, err)
+		}
+	}
+}
+
+func TestParseWeightsKeepsTheDefaults(t *testing.T) {
+	weights, err := ParseWeights("trending=9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	weights[SourceFollowedTopics] = 0
+	if DefaultWeights[SourceTrending] == 9 || DefaultWeights[SourceFollowedTopics] == 0 {
+		t.Errorf("ParseWeights changed the defaults to %v", DefaultWeights)
+	}
+}
+
+func TestDiversify(t *testing.T) {
+	var (
+		go1  = Candidate{Post_ID: 1, Topic_ID: 1, Position: 1}
+		go2  = Candidate{Post_ID: 2, Topic_ID: 1, Position: 2}
+		go3  = Candidate{Post_ID: 3, Topic_ID: 1, Position: 3}
+		rust = Candidate{Post_ID: 4, Topic_ID: 2, Position: 4}
+		java = Candidate{Post_ID: 5, Topic_ID: 3, Position: 5}
+		go4  = Candidate{Post_ID: 6, Topic_ID: 1, Position: 6}
+	)
+
+	tests := []struct {
+		name     string
+		pool     []Candidate
+		limit    int
+		wantPage []Candidate
+		wantRest []Candidate
+	}{
+		{"at most two of a topic", []Candidate{go1, go2, go3, rust, java, go4}, 4, []Candidate{go1, go2, rust, java}, []Candidate{go3, go4}},
+		{"short page rather than a third of a topic", []Candidate{go1, go2, go3, go4, rust}, 4, []Candidate{go1, go2, rust}, []Candidate{go3, go4}},
+		{"one topic only", []Candidate{go1, go2, go3}, 2, []Candidate{go1, go2}, []Candidate{go3}},
+		{"full page", []Candidate{go1, rust, java, go2}, 2, []Candidate{go1, rust}, []Candidate{java, go2}},
+		{"empty pool", nil, 3, nil, nil},
+	}
+
+	pipeline := New(nil, DefaultWeights, 2)
+	for _, tt := range tests {
+		page, rest := pipeline.diversify(tt.pool, tt.limit)
+		if !slices.Equal(page, tt.wantPage) || !slices.Equal(rest, tt.wantRest) {
+			t.Errorf("%s: diversify = %v and %v, want %v and %v", tt.name, page, rest, tt.wantPage, tt.wantRest)
+		}
+	}
+
+	//a cap below one still shows a post of each topic
+	pool := []Candidate{go1, go2, rust}
+	if page, _ := New(nil, DefaultWeights, 0).diversify(pool, 2); !slices.Equal(page, []Candidate{go1, rust}) {
+		t.Errorf("diversify with a cap of 0 = %v, want %v", page, []Candidate{go1, rust})
+	}
+}
@@ -0,0 +1,44 @@
+// Background jobs that run on a fixed interval next to the api server
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context, db *pgxpool.Pool) error
+}
+
+// run every job once straight away and then on its interval until ctx is done
+// errors are logged and the job tries again on the next tick
+func Start(ctx context.Context, db *pgxpool.Pool, jobs ...Job) {
+	for _, job := range jobs {
+		go run(ctx, db, job)
+	}
+}
+
+func run(ctx context.Context, db *pgxpool.Pool, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		if err := job.Run(ctx, db); err != nil {
+			log.Println("job", job.Name, "failed:", err)
+		} else {
+			log.Println("job", job.Name, "finished in", time.Since(start))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
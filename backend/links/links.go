@@ -0,0 +1,35 @@
+// Link posts and the previews of the pages they link to
+package links
+
+import (
+	"context"
+
+	db "github.com/minrui13/backend/database"
+	"github.com/minrui13/backend/types"
+)
+
+// post_type of link posts, the other types are in polls
+const PostTypeLink = "link"
+
+// link column of the post queries, null for deleted posts like their title and content
+const Column = `(SELECT jsonb_build_object(
+			'url', lk.url,
+			'domain', lk.domain,
+			'final_url', lk.final_url,
+			'title', lk.title,
+			'description', lk.description,
+			'image_url', lk.image_url,
+			'site_name', lk.site_name,
+			'fetched_date', to_char(lk.fetched_date, 'YYYY-MM-DD"T"HH24:MI:SS"Z"'))
+			FROM posts_links lk
+			WHERE lk.post_id = p.post_id AND p.deleted_date IS NULL) AS link`
+
+// store the preview of a new link post
+// a preview without a final url was not fetched and gets no fetched date
+func Create(ctx context.Context, q db.Querier, postID int, preview types.LinkPreview) error {
+	_, err := q.Exec(ctx,
+		`INSERT INTO posts_links (post_id, url, domain, final_url, title, description, image_url, site_name, fetched_date)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, CASE WHEN $4::text IS NULL THEN NULL ELSE LOCALTIMESTAMP END)`,
+		postID, preview.URL, preview.Domain, preview.Final_URL, preview.Title, preview.Description, preview.Image_URL, preview.Site_Name)
+	return err
+}
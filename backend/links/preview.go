@@ -0,0 +1,272 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/minrui13/backend/types"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	maxURLLength   = 2000
+	maxRedirects   = 5
+	maxBodyBytes   = 1 << 20
+	maxTitle       = 300
+	maxDescription = 1000
+	userAgent      = "Mozilla/5.0 (compatible; BuzzBeeBot/1.0; link preview)"
+)
+
+var (
+	ErrInvalidURL       = errors.New("link must be an http or https url")
+	ErrBlockedAddress   = errors.New("link points to a private or reserved address")
+	ErrTooManyRedirects = errors.New("link redirects too many times")
+	ErrFetchFailed      = errors.New("link could not be fetched")
+)
+
+// sends the preview requests, *http.Client satisfies it
+// NewClient refuses private addresses, so tests swap in the client of a local stub server
+type Fetcher interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// ranges netip does not already report as private, loopback, link local, multicast or unspecified
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// true for addresses a preview must not reach, the server's own network and cloud metadata included
+func IsBlocked(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return true
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// checked on every connection after the host name is resolved, redirects included
+// so ip literals, localhost and names pointing or rebinding to a private address are all refused
+func guard(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || IsBlocked(addr) {
+		return ErrBlockedAddress
+	}
+	return nil
+}
+
+// client for the previews, it only connects to public addresses
+func NewClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: guard}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			//no proxy, the guard has to see the address of the site itself
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: 5 * time.Second,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			//via holds every request so far, the first one and the redirects followed
+			if len(via) > maxRedirects {
+				return ErrTooManyRedirects
+			}
+			_, err := CheckURL(req.URL.String())
+			return err
+		},
+	}
+}
+
+// parse a link and refuse anything but http and https urls
+// the address is not checked here, NewClient's guard checks it when connecting
+func CheckURL(rawURL string) (*url.URL, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" || len(rawURL) > maxURLLength {
+		return nil, ErrInvalidURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.User != nil {
+		return nil, ErrInvalidURL
+	}
+	u.Fragment = ""
+	return u, nil
+}
+
+// fetch the page and read its title, description and open graph image
+// the preview always has the url and domain, the rest only once the page was read
+// returns ErrInvalidURL, or ErrBlockedAddress when the client refused the address,
+// for links that must not be posted, any other error leaves the preview without the page fields
+func Fetch(ctx context.Context, client Fetcher, rawURL string) (types.LinkPreview, error) {
+	u, err := CheckURL(rawURL)
+	if err != nil {
+		return types.LinkPreview{}, err
+	}
+	preview := types.LinkPreview{
+		URL:    u.String(),
+		Domain: strings.TrimPrefix(strings.ToLower(u.Hostname()), "www."),
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, preview.URL, nil)
+	if err != nil {
+		return preview, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.1")
+
+	resp, err := client.Do(req)
+	if errors.Is(err, ErrBlockedAddress) {
+		return preview, ErrBlockedAddress
+	}
+	if err != nil {
+		return preview, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return preview, ErrFetchFailed
+	}
+
+	finalURL := resp.Request.URL
+	finalString := finalURL.String()
+	preview.Final_URL = &finalString
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml" || mediaType == "":
+	case strings.HasPrefix(mediaType, "image/"):
+		//a link straight to an image is its own preview image
+		preview.Image_URL = &finalString
+		return preview, nil
+	default:
+		return preview, nil
+	}
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, maxBodyBytes), resp.Header.Get("Content-Type"))
+	if err != nil {
+		return types.LinkPreview{URL: preview.URL, Domain: preview.Domain}, err
+	}
+	meta := extract(body)
+
+	preview.Title = clean(first(meta["og:title"], meta["twitter:title"], meta["title"]), maxTitle)
+	preview.Description = clean(first(meta["og:description"], meta["twitter:description"], meta["description"]), maxDescription)
+	preview.Site_Name = clean(meta["og:site_name"], maxTitle)
+	image := first(meta["og:image:secure_url"], meta["og:image:url"], meta["og:image"], meta["twitter:image"], meta["twitter:image:src"])
+	if image != "" {
+		//relative images are resolved against the page the client ended on
+		if imageURL, err := finalURL.Parse(strings.TrimSpace(image)); err == nil && (imageURL.Scheme == "http" || imageURL.Scheme == "https") {
+			imageString := imageURL.String()
+			if len(imageString) <= maxURLLength {
+				preview.Image_URL = &imageString
+			}
+		}
+	}
+	return preview, nil
+}
+
+// the title and the meta tags of the head, by property or name
+// the first of each wins, reading stops at the body
+func extract(body io.Reader) map[string]string {
+	meta := map[string]string{}
+	tokenizer := html.NewTokenizer(body)
+	inTitle := false
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return meta
+		case html.TextToken:
+			if inTitle {
+				meta["title"] += string(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				return meta
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			switch string(name) {
+			case "body":
+				return meta
+			case "title":
+				_, seen := meta["title"]
+				inTitle = !seen
+				if inTitle {
+					meta["title"] = ""
+				}
+			case "meta":
+				var key, content string
+				for hasAttr {
+					var attrName, attrValue []byte
+					attrName, attrValue, hasAttr = tokenizer.TagAttr()
+					switch string(attrName) {
+					case "property", "name":
+						if key == "" {
+							key = strings.ToLower(strings.TrimSpace(string(attrValue)))
+						}
+					case "content":
+						content = string(attrValue)
+					}
+				}
+				if _, seen := meta[key]; key != "" && key != "title" && !seen {
+					meta[key] = content
+				}
+			}
+		}
+	}
+}
+
+// the first value that is not blank
+func first(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// collapse white space and cut to limit characters, nil when nothing is left
+func clean(s string, limit int) *string {
+	s = strings.Join(strings.Fields(s), " ")
+	if s == "" {
+		return nil
+	}
+	if utf8.RuneCountInString(s) > limit {
+		s = strings.TrimSpace(string([]rune(s)[:limit]))
+	}
+	return &s
+}
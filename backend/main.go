@@ -4,7 +4,9 @@ import (
 	"log"
 	"os"
 
+	"github.com/minrui13/backend/config"
 	db "github.com/minrui13/backend/database"
+	"github.com/minrui13/backend/search"
 	"github.com/minrui13/backend/server"
 )
 
@@ -16,9 +18,16 @@ func main() {
 		log.Fatal(err)
 	}
 
+	//opening the search backend
+	searchEngine, err := search.New(config.Envs.SearchBackend, config.Envs.SearchIndexPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer searchEngine.Close()
+
 	port := os.Getenv("PORT")
 
-	newServer := server.NewServer(port, dbPool)
+	newServer := server.NewServer(port, dbPool, searchEngine)
 
 	if err := newServer.Run(); err != nil {
 		log.Fatal(err)
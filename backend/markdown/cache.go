@@ -0,0 +1,72 @@
+package markdown
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	db "github.com/minrui13/backend/database"
+	"github.com/minrui13/backend/jobs"
+)
+
+const batchSize = 200
+
+// render content saved before markdown support or by an older renderer
+var RenderJob = jobs.Job{
+	Name:     "markdown cache",
+	Interval: 10 * time.Minute,
+	Run: func(ctx context.Context, pool *pgxpool.Pool) error {
+		if err := renderStale(ctx, pool, "posts", "post_id"); err != nil {
+			return err
+		}
+		return renderStale(ctx, pool, "posts_comments", "comment_id")
+	},
+}
+
+// table and idColumn are constants from RenderJob, never user input
+func renderStale(ctx context.Context, q db.Querier, table string, idColumn string) error {
+	for {
+		rows, err := q.Query(ctx,
+			`SELECT `+idColumn+`, content FROM `+table+`
+			WHERE content_html_version IS DISTINCT FROM $1
+			ORDER BY `+idColumn+`
+			LIMIT $2`,
+			Version, batchSize)
+		if err != nil {
+			return err
+		}
+
+		type stale struct {
+			id      int
+			content string
+		}
+		var batch []stale
+		for rows.Next() {
+			var s stale
+			if err := rows.Scan(&s.id, &s.content); err != nil {
+				rows.Close()
+				return err
+			}
+			batch = append(batch, s)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, s := range batch {
+			//skip rows edited since they were read, the edit rendered them already
+			_, err := q.Exec(ctx,
+				`UPDATE `+table+` SET content_html = $1, content_html_version = $2
+				WHERE `+idColumn+` = $3 AND content = $4`,
+				Render(s.content), Version, s.id, s.content)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(batch) < batchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Markdown rendering for posts and comments
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// bump when the output changes so cached html gets rendered again
+const Version = 1
+
+var (
+	//raw html in the source is dropped by goldmark since unsafe rendering is off
+	renderer = goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+	)
+
+	//allowlist for user content, no scripts, styles or event handlers
+	policy = newPolicy()
+)
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.RequireNoFollowOnLinks(true)
+	p.RequireNoReferrerOnLinks(true)
+	//task list checkboxes
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	return p
+}
+
+// render markdown to sanitized html
+// sanitizing after rendering also catches anything goldmark lets through
+func Render(source string) string {
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(source), &buf); err != nil {
+		//fall back to the escaped source rather than failing the request
+		return policy.Sanitize("<p>" + bluemonday.StrictPolicy().Sanitize(source) + "</p>")
+	}
+	return policy.Sanitize(buf.String())
+}
@@ -0,0 +1,64 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"emphasis", "**bold** _it_", "<p><strong>bold</strong> <em>it</em></p>\n"},
+		{"strikethrough", "~~gone~~", "<p><del>gone</del></p>\n"},
+		{"links do not pass rank or referrer", "[x](https://example.com)", "<p><a href=\"https://example.com\" rel=\"nofollow noreferrer\">x</a></p>\n"},
+		{"bare urls are linked", "https://example.com", "<p><a href=\"https://example.com\" rel=\"nofollow noreferrer\">https://example.com</a></p>\n"},
+		{"javascript links lose their href", "[x](javascript:alert(1))", "<p>x</p>\n"},
+		{"plain text is escaped", "1 < 2 & 3 > 2", "<p>1 &lt; 2 &amp; 3 &gt; 2</p>\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.source); got != tt.want {
+				t.Errorf("Render(%q) = %q, want %q", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderDropsUnsafeHTML(t *testing.T) {
+	sources := []string{
+		"<script>alert(1)</script>",
+		"<img src=x onerror=alert(1)>",
+		"text <span onclick=\"alert(1)\">inline</span>",
+		"<iframe src=\"https://example.com\"></iframe>",
+		"<style>body { display: none }</style>",
+	}
+
+	for _, source := range sources {
+		got := strings.ToLower(Render(source))
+		for _, unsafe := range []string{"<script", "onerror", "onclick", "<iframe", "<style", "alert"} {
+			if strings.Contains(got, unsafe) {
+				t.Errorf("Render(%q) = %q keeps %q", source, got, unsafe)
+			}
+		}
+	}
+}
+
+func TestRenderGFM(t *testing.T) {
+	got := Render("- [x] done\n- [ ] todo")
+	for _, want := range []string{`<input checked="" disabled="" type="checkbox"> done`, `<input disabled="" type="checkbox"> todo`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("task list rendered as %q, want it to contain %q", got, want)
+		}
+	}
+
+	got = Render("| a | b |\n|---|---|\n| 1 | 2 |")
+	for _, want := range []string{"<table>", "<th>a</th>", "<td>2</td>"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("table rendered as %q, want it to contain %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+// Topic moderation checks shared by the routes
+package moderation
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	db "github.com/minrui13/backend/database"
+	"github.com/minrui13/backend/types"
+)
+
+// actions recorded in topics_moderation_log
+const (
+	ActionAddModerator    = "add_moderator"
+	ActionRemoveModerator = "remove_moderator"
+	ActionRemovePost      = "remove_post"
+	ActionRemoveComment   = "remove_comment"
+	ActionLockPost        = "lock_post"
+	ActionUnlockPost      = "unlock_post"
+	ActionPinPost         = "pin_post"
+	ActionUnpinPost       = "unpin_post"
+	ActionBanUser         = "ban_user"
+	ActionUnbanUser       = "unban_user"
+	ActionAddRule         = "add_rule"
+	ActionUpdateRule      = "update_rule"
+	ActionDeleteRule      = "delete_rule"
+	ActionReorderRules    = "reorder_rules"
+	ActionAddFlair        = "add_flair"
+	ActionUpdateFlair     = "update_flair"
+	ActionDeleteFlair     = "delete_flair"
+	ActionUpdateSidebar   = "update_sidebar"
+	ActionTransferOwner   = "transfer_ownership"
+	ActionReassignOwner   = "reassign_ownership"
+	ActionArchiveTopic    = "archive_topic"
+	ActionUnarchiveTopic  = "unarchive_topic"
+	ActionRenameTopic     = "rename_topic"
+	ActionRestorePost     = "restore_post"
+	ActionAnnouncePost    = "announce_post"
+	ActionUnannouncePost  = "unannounce_post"
+	ActionLockComment     = "lock_comment"
+	ActionUnlockComment   = "unlock_comment"
+	ActionReadOnlyTopic   = "read_only_topic"
+	ActionWritableTopic   = "writable_topic"
+	ActionMarkNSFW        = "mark_nsfw"
+	ActionUnmarkNSFW      = "unmark_nsfw"
+	ActionMarkSpoiler     = "mark_spoiler"
+	ActionUnmarkSpoiler   = "unmark_spoiler"
+	ActionNSFWTopic       = "nsfw_topic"
+	ActionSFWTopic        = "sfw_topic"
+)
+
+// flair kinds
+const (
+	FlairKindUser = "user"
+	FlairKindPost = "post"
+)
+
+// longest temporary ban, ten years, anything longer is a permanent ban
+const MaxBanHours = 24 * 365 * 10
+
+var (
+	ErrNotOwner     = errors.New("only the topic creator can perform this action")
+	ErrNotModerator = errors.New("only topic moderators can perform this action")
+	ErrBanned       = errors.New("you are banned from this topic")
+	ErrPostLocked   = errors.New("post is locked")
+	ErrPostDeleted  = errors.New("post has been deleted")
+	ErrInvalidFlair = errors.New("invalid flair for this topic")
+	ErrFlairNeeded  = errors.New("this topic requires a post flair")
+	ErrNotAdmin     = errors.New("only admins can perform this action")
+	ErrArchived     = errors.New("topic is archived")
+	ErrReadOnly     = errors.New("topic is read-only")
+	ErrThreadLocked = errors.New("comment thread is locked")
+)
+
+// check if user created the topic
+func IsTopicOwner(ctx context.Context, q db.Querier, topicID int, userID int) (bool, error) {
+	var isOwner bool
+	err := q.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM topics WHERE topic_id = $1 AND creator_id = $2)`,
+		topicID, userID).Scan(&isOwner)
+	return isOwner, err
+}
+
+// the topic creator is always a moderator of their own topic
+func IsTopicModerator(ctx context.Context, q db.Querier, topicID int, userID int) (bool, error) {
+	var isModerator bool
+	err := q.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM topics WHERE topic_id = $1 AND creator_id = $2)
+		OR EXISTS (SELECT 1 FROM topics_moderators WHERE topic_id = $1 AND user_id = $2)`,
+		topicID, userID).Scan(&isModerator)
+	return isModerator, err
+}
+
+// expired bans are ignored so they do not need to be cleaned up
+func IsBannedFromTopic(ctx context.Context, q db.Querier, topicID int, userID int) (bool, error) {
+	var isBanned bool
+	err := q.QueryRow(ctx,
+		`SELECT EXISTS (
+			SELECT 1 FROM topics_bans
+			WHERE topic_id = $1 AND user_id = $2
+			AND (expires_date IS NULL OR expires_date > current_timestamp)
+		)`,
+		topicID, userID).Scan(&isBanned)
+	return isBanned, err
+}
+
+// check if user is a site admin
+func IsAdmin(ctx context.Context, q db.Querier, userID int) (bool, error) {
+	var isAdmin bool
+	err := q.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE user_id = $1 AND is_admin)`, userID).
+		Scan(&isAdmin)
+	return isAdmin, err
+}
+
+// check that the user can post, comment or vote in the topic
+// moderators keep posting in read-only topics
+// returns ErrArchived, ErrBanned or ErrReadOnly, pgx.ErrNoRows if topic does not exist
+func CheckCanParticipate(ctx context.Context, q db.Querier, topicID int, userID int) error {
+	var isArchived, isReadOnly bool
+	err := q.QueryRow(ctx, `SELECT archived_date IS NOT NULL, is_read_only FROM topics WHERE topic_id = $1`, topicID).
+		Scan(&isArchived, &isReadOnly)
+	if err != nil {
+		return err
+	}
+	if isArchived {
+		return ErrArchived
+	}
+
+	isBanned, err := IsBannedFromTopic(ctx, q, topicID, userID)
+	if err != nil {
+		return err
+	}
+	if isBanned {
+		return ErrBanned
+	}
+
+	if isReadOnly {
+		isModerator, err := IsTopicModerator(ctx, q, topicID, userID)
+		if err != nil {
+			return err
+		}
+		if !isModerator {
+			return ErrReadOnly
+		}
+	}
+	return nil
+}
+
+// get the topic a post belongs to
+func TopicIDByPost(ctx context.Context, q db.Querier, postID int) (int, error) {
+	var topicID int
+	err := q.QueryRow(ctx, `SELECT topic_id FROM posts WHERE post_id = $1`, postID).Scan(&topicID)
+	return topicID, err
+}
+
+// same as TopicIDByPost but drafts, scheduled and deleted posts are not found
+func TopicIDByPublishedPost(ctx context.Context, q db.Querier, postID int) (int, error) {
+	var topicID int
+	err := q.QueryRow(ctx, `SELECT topic_id FROM posts WHERE post_id = $1 AND status = 'published' AND deleted_date IS NULL`, postID).
+		Scan(&topicID)
+	return topicID, err
+}
+
+// get the topic a comment belongs to
+func TopicIDByComment(ctx context.Context, q db.Querier, commentID int) (int, error) {
+	var topicID int
+	err := q.QueryRow(ctx,
+		`SELECT p.topic_id FROM posts_comments pc
+		INNER JOIN posts p ON p.post_id = pc.post_id
+		WHERE pc.comment_id = $1`, commentID).Scan(&topicID)
+	return topicID, err
+}
+
+// check that the user can still comment or vote on the post
+// returns an error of CheckCanParticipate, ErrPostDeleted or ErrPostLocked, pgx.ErrNoRows if post does not exist or is not published
+func CheckCanContribute(ctx context.Context, q db.Querier, postID int, userID int) error {
+	var topicID int
+	var isLocked, isDeleted bool
+	err := q.QueryRow(ctx,
+		`SELECT topic_id, is_locked, deleted_date IS NOT NULL FROM posts WHERE post_id = $1 AND status = 'published'`,
+		postID).Scan(&topicID, &isLocked, &isDeleted)
+	if err != nil {
+		return err
+	}
+
+	if err := CheckCanParticipate(ctx, q, topicID, userID); err != nil {
+		return err
+	}
+	if isDeleted {
+		return ErrPostDeleted
+	}
+	if isLocked {
+		return ErrPostLocked
+	}
+	return nil
+}
+
+// check that the user can reply to the comment or vote on it
+// as CheckCanContribute for its post, and ErrThreadLocked when the comment or one above it is locked
+func CheckCanContributeToComment(ctx context.Context, q db.Querier, commentID int, userID int) error {
+	var postID int
+	var isLocked bool
+	err := q.QueryRow(ctx,
+		`SELECT pc.post_id, `+ThreadLockedSQL("pc.comment_id")+` FROM posts_comments pc WHERE pc.comment_id = $1`,
+		commentID).Scan(&postID, &isLocked)
+	if err != nil {
+		return err
+	}
+
+	if err := CheckCanContribute(ctx, q, postID, userID); err != nil {
+		return err
+	}
+	if isLocked {
+		return ErrThreadLocked
+	}
+	return nil
+}
+
+// check that the voter can still change a post vote
+// returns what CheckCanContribute returns for the voter, pgx.ErrNoRows if the vote does not exist
+func CheckCanChangePostVote(ctx context.Context, q db.Querier, postVoteID int) error {
+	var postID, userID int
+	err := q.QueryRow(ctx, `SELECT post_id, user_id FROM posts_votes WHERE post_vote_id = $1`, postVoteID).
+		Scan(&postID, &userID)
+	if err != nil {
+		return err
+	}
+	return CheckCanContribute(ctx, q, postID, userID)
+}
+
+// check that the voter can still change a comment vote
+// returns what CheckCanContributeToComment returns for the voter, pgx.ErrNoRows if the vote does not exist
+func CheckCanChangeCommentVote(ctx context.Context, q db.Querier, commentVoteID int) error {
+	var commentID, userID int
+	err := q.QueryRow(ctx, `SELECT comment_id, user_id FROM comments_votes WHERE comment_vote_id = $1`, commentVoteID).
+		Scan(&commentID, &userID)
+	if err != nil {
+		return err
+	}
+	return CheckCanContributeToComment(ctx, q, commentID, userID)
+}
+
+// true when the comment or one of the comments above it is locked
+// comment is the sql expression of the comment id
+func ThreadLockedSQL(comment string) string {
+	return `EXISTS (
+		WITH RECURSIVE thread AS (
+			SELECT tc.comment_id, tc.parent_comment_id, tc.is_locked FROM posts_comments tc WHERE tc.comment_id = ` + comment + `
+			UNION ALL
+			SELECT tc.comment_id, tc.parent_comment_id, tc.is_locked FROM posts_comments tc
+			INNER JOIN thread ON tc.comment_id = thread.parent_comment_id
+		)
+		SELECT 1 FROM thread WHERE thread.is_locked
+	)`
+}
+
+// record a moderator action
+func Log(ctx context.Context, q db.Querier, entry types.ModerationLogEntry) error {
+	_, err := q.Exec(ctx,
+		`INSERT INTO topics_moderation_log
+		(topic_id, moderator_id, action, target_user_id, target_post_id, target_comment_id, details)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		entry.Topic_ID, entry.Moderator_ID, entry.Action, entry.Target_User_ID, entry.Target_Post_ID, entry.Target_Comment_ID, entry.Details)
+	return err
+}
+
+// errors caused by the topic state rather than the request or the database
+func IsForbidden(err error) bool {
+	return errors.Is(err, ErrArchived) || errors.Is(err, ErrBanned) || errors.Is(err, ErrPostDeleted)
+}
+
+// errors caused by a lock a moderator can lift, answered with 423
+func IsLocked(err error) bool {
+	return errors.Is(err, ErrPostLocked) || errors.Is(err, ErrThreadLocked) || errors.Is(err, ErrReadOnly)
+}
+
+// helper so callers can tell a missing row apart from a database error
+func IsNotFound(err error) bool {
+	return errors.Is(err, pgx.ErrNoRows)
+}
@@ -0,0 +1,270 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/minrui13/backend/database/dbtest"
+)
+
+// a topic that is open to everyone, its creator and another user who neither moderates nor is banned from it
+func topicWithUser(t *testing.T, tx pgx.Tx) (int, int, int) {
+	t.Helper()
+	ctx := context.Background()
+
+	var topicID, ownerID, userID int
+	err := tx.QueryRow(ctx,
+		`SELECT t.topic_id, t.creator_id, u.user_id
+		FROM topics t INNER JOIN users u ON u.user_id <> t.creator_id
+		ORDER BY t.topic_id, u.user_id LIMIT 1`).Scan(&topicID, &ownerID, &userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		t.Skip("the test database needs a topic and a user who did not create it")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exec(t, tx, `UPDATE topics SET archived_date = NULL, is_read_only = FALSE WHERE topic_id = $1`, topicID)
+	exec(t, tx, `DELETE FROM topics_moderators WHERE topic_id = $1 AND user_id = $2`, topicID, userID)
+	exec(t, tx, `DELETE FROM topics_bans WHERE topic_id = $1 AND user_id = $2`, topicID, userID)
+	return topicID, ownerID, userID
+}
+
+func exec(t *testing.T, tx pgx.Tx, sql string, args ...any) {
+	t.Helper()
+	if _, err := tx.Exec(context.Background(), sql, args...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestModeratorsAndBans(t *testing.T) {
+	tx := dbtest.Tx(t)
+	ctx := context.Background()
+	topicID, ownerID, userID := topicWithUser(t, tx)
+
+	check := func(what string, userID int, wantOwner bool, wantModerator bool, wantErr error) {
+		t.Helper()
+		isOwner, err := IsTopicOwner(ctx, tx, topicID, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isModerator, err := IsTopicModerator(ctx, tx, topicID, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if isOwner != wantOwner || isModerator != wantModerator {
+			t.Errorf("%s: owner %v and moderator %v, want %v and %v", what, isOwner, isModerator, wantOwner, wantModerator)
+		}
+		if err := CheckCanParticipate(ctx, tx, topicID, userID); !errors.Is(err, wantErr) {
+			t.Errorf("%s: CheckCanParticipate = %v, want %v", what, err, wantErr)
+		}
+	}
+
+	check("creator", ownerID, true, true, nil)
+	check("user", userID, false, false, nil)
+
+	exec(t, tx, `INSERT INTO topics_moderators (topic_id, user_id, appointed_by) VALUES ($1, $2, $3)`, topicID, userID, ownerID)
+	check("appointed moderator", userID, false, true, nil)
+	exec(t, tx, `DELETE FROM topics_moderators WHERE topic_id = $1 AND user_id = $2`, topicID, userID)
+
+	//expired bans are ignored, permanent and running ones are not
+	exec(t, tx, `INSERT INTO topics_bans (topic_id, user_id, banned_by, expires_date) VALUES ($1, $2, $3, CURRENT_TIMESTAMP - INTERVAL '1 hour')`, topicID, userID, ownerID)
+	check("user with an expired ban", userID, false, false, nil)
+	exec(t, tx, `UPDATE topics_bans SET expires_date = CURRENT_TIMESTAMP + INTERVAL '1 hour' WHERE topic_id = $1 AND user_id = $2`, topicID, userID)
+	check("user with a running ban", userID, false, false, ErrBanned)
+	exec(t, tx, `UPDATE topics_bans SET expires_date = NULL WHERE topic_id = $1 AND user_id = $2`, topicID, userID)
+	check("user with a permanent ban", userID, false, false, ErrBanned)
+	check("creator", ownerID, true, true, nil)
+
+	if err := CheckCanParticipate(ctx, tx, -1, userID); !IsNotFound(err) {
+		t.Errorf("CheckCanParticipate of a missing topic = %v, want pgx.ErrNoRows", err)
+	}
+}
+
+func TestIsTopicFlair(t *testing.T) {
+	tx := dbtest.Tx(t)
+	ctx := context.Background()
+	topicID, _, _ := topicWithUser(t, tx)
+
+	var flairID int
+	err := tx.QueryRow(ctx, `INSERT INTO topics_flairs (topic_id, kind, text) VALUES ($1, $2, 'Question') RETURNING flair_id`, topicID, FlairKindPost).
+		Scan(&flairID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		topicID int
+		kind    string
+		want    bool
+	}{
+		{topicID, FlairKindPost, true},
+		{topicID, FlairKindUser, false},
+		{-1, FlairKindPost, false},
+	} {
+		got, err := IsTopicFlair(ctx, tx, c.topicID, flairID, c.kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("IsTopicFlair(topic %d, %s) = %v, want %v", c.topicID, c.kind, got, c.want)
+		}
+	}
+}
+
+func TestArchivedTopics(t *testing.T) {
+	tx := dbtest.Tx(t)
+	ctx := context.Background()
+	topicID, ownerID, userID := topicWithUser(t, tx)
+
+	//nobody takes part in an archived topic, its creator neither
+	exec(t, tx, `UPDATE topics SET archived_date = CURRENT_TIMESTAMP WHERE topic_id = $1`, topicID)
+	for _, id := range []int{ownerID, userID} {
+		if err := CheckCanParticipate(ctx, tx, topicID, id); !errors.Is(err, ErrArchived) || !IsForbidden(err) {
+			t.Errorf("CheckCanParticipate of user %d in an archived topic = %v, want ErrArchived", id, err)
+		}
+	}
+
+	exec(t, tx, `UPDATE topics SET archived_date = NULL WHERE topic_id = $1`, topicID)
+	if err := CheckCanParticipate(ctx, tx, topicID, userID); err != nil {
+		t.Errorf("CheckCanParticipate in an unarchived topic = %v, want nil", err)
+	}
+}
+
+func TestIsAdmin(t *testing.T) {
+	tx := dbtest.Tx(t)
+	ctx := context.Background()
+	_, _, userID := topicWithUser(t, tx)
+
+	for _, admin := range []bool{true, false} {
+		exec(t, tx, `UPDATE users SET is_admin = $1 WHERE user_id = $2`, admin, userID)
+		got, err := IsAdmin(ctx, tx, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != admin {
+			t.Errorf("IsAdmin = %v, want %v", got, admin)
+		}
+	}
+}
+
+// a published post that anyone can comment on and vote on, and a user who did not write it
+func openPost(t *testing.T, tx pgx.Tx) (int, int) {
+	t.Helper()
+	var postID, topicID, userID int
+	err := tx.QueryRow(context.Background(),
+		`SELECT p.post_id, p.topic_id, u.user_id
+		FROM posts p INNER JOIN users u ON u.user_id <> p.author_id
+		WHERE p.status = 'published'
+		ORDER BY p.post_id, u.user_id LIMIT 1`).Scan(&postID, &topicID, &userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		t.Skip("the test database needs a published post and a user who did not write it")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exec(t, tx, `UPDATE posts SET deleted_date = NULL, is_locked = FALSE WHERE post_id = $1`, postID)
+	exec(t, tx, `UPDATE topics SET archived_date = NULL, is_read_only = FALSE WHERE topic_id = $1`, topicID)
+	exec(t, tx, `DELETE FROM topics_bans WHERE topic_id = $1 AND user_id = $2`, topicID, userID)
+	return postID, userID
+}
+
+func TestCheckCanContributeToDeletedPosts(t *testing.T) {
+	tx := dbtest.Tx(t)
+	ctx := context.Background()
+	postID, userID := openPost(t, tx)
+
+	if err := CheckCanContribute(ctx, tx, postID, userID); err != nil {
+		t.Fatalf("CheckCanContribute of an open post = %v, want nil", err)
+	}
+
+	exec(t, tx, `UPDATE posts SET deleted_date = CURRENT_TIMESTAMP WHERE post_id = $1`, postID)
+	if err := CheckCanContribute(ctx, tx, postID, userID); !errors.Is(err, ErrPostDeleted) || !IsForbidden(err) {
+		t.Errorf("CheckCanContribute of a deleted post = %v, want ErrPostDeleted", err)
+	}
+
+	//restored posts are open again, drafts are not found
+	exec(t, tx, `UPDATE posts SET deleted_date = NULL WHERE post_id = $1`, postID)
+	if err := CheckCanContribute(ctx, tx, postID, userID); err != nil {
+		t.Errorf("CheckCanContribute of a restored post = %v, want nil", err)
+	}
+	exec(t, tx, `UPDATE posts SET status = 'draft' WHERE post_id = $1`, postID)
+	if err := CheckCanContribute(ctx, tx, postID, userID); !IsNotFound(err) {
+		t.Errorf("CheckCanContribute of a draft = %v, want pgx.ErrNoRows", err)
+	}
+}
+
+func TestLocks(t *testing.T) {
+	tx := dbtest.Tx(t)
+	ctx := context.Background()
+	postID, userID := openPost(t, tx)
+	topicID, err := TopicIDByPost(ctx, tx, postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exec(t, tx, `DELETE FROM topics_moderators WHERE topic_id = $1 AND user_id = $2`, topicID, userID)
+
+	exec(t, tx, `UPDATE posts SET is_locked = TRUE WHERE post_id = $1`, postID)
+	if err := CheckCanContribute(ctx, tx, postID, userID); !errors.Is(err, ErrPostLocked) || !IsLocked(err) {
+		t.Errorf("CheckCanContribute of a locked post = %v, want ErrPostLocked", err)
+	}
+	exec(t, tx, `UPDATE posts SET is_locked = FALSE WHERE post_id = $1`, postID)
+
+	//read-only topics stay open to their moderators
+	exec(t, tx, `UPDATE topics SET is_read_only = TRUE WHERE topic_id = $1`, topicID)
+	if err := CheckCanContribute(ctx, tx, postID, userID); !errors.Is(err, ErrReadOnly) || !IsLocked(err) {
+		t.Errorf("CheckCanContribute in a read-only topic = %v, want ErrReadOnly", err)
+	}
+	exec(t, tx, `INSERT INTO topics_moderators (topic_id, user_id) VALUES ($1, $2)`, topicID, userID)
+	if err := CheckCanContribute(ctx, tx, postID, userID); err != nil {
+		t.Errorf("CheckCanContribute of a moderator in a read-only topic = %v, want nil", err)
+	}
+}
+
+func TestThreadLocks(t *testing.T) {
+	tx := dbtest.Tx(t)
+	ctx := context.Background()
+	postID, userID := openPost(t, tx)
+
+	//a comment, a reply to it and a reply to the reply
+	comment := func(parentID *int) int {
+		t.Helper()
+		var commentID int
+		err := tx.QueryRow(ctx,
+			`INSERT INTO posts_comments (user_id, post_id, parent_comment_id, content) VALUES ($1, $2, $3, 'thread lock test')
+			RETURNING comment_id`, userID, postID, parentID).Scan(&commentID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return commentID
+	}
+	top := comment(nil)
+	reply := comment(&top)
+	nested := comment(&reply)
+
+	//a lock stops its comment and everything under it, not the comments above
+	exec(t, tx, `UPDATE posts_comments SET is_locked = TRUE WHERE comment_id = $1`, reply)
+	for _, c := range []struct {
+		commentID int
+		wantErr   error
+	}{
+		{top, nil},
+		{reply, ErrThreadLocked},
+		{nested, ErrThreadLocked},
+	} {
+		err := CheckCanContributeToComment(ctx, tx, c.commentID, userID)
+		if !errors.Is(err, c.wantErr) || (c.wantErr != nil && !IsLocked(err)) {
+			t.Errorf("CheckCanContributeToComment of comment %d = %v, want %v", c.commentID, err, c.wantErr)
+		}
+	}
+
+	//a locked post stops all its comments
+	exec(t, tx, `UPDATE posts_comments SET is_locked = FALSE WHERE comment_id = $1`, reply)
+	exec(t, tx, `UPDATE posts SET is_locked = TRUE WHERE post_id = $1`, postID)
+	if err := CheckCanContributeToComment(ctx, tx, top, userID); !errors.Is(err, ErrPostLocked) {
+		t.Errorf("CheckCanContributeToComment on a locked post = %v, want ErrPostLocked", err)
+	}
+}
@@ -0,0 +1,72 @@
+package moderation
+
+import (
+	"context"
+
+	db "github.com/minrui13/backend/database"
+	"github.com/minrui13/backend/types"
+)
+
+// get topic rules in display order
+func GetTopicRules(ctx context.Context, q db.Querier, topicID int) ([]types.TopicRule, error) {
+	rows, err := q.Query(ctx, `
+		SELECT rule_id, topic_id, position, title, body
+		FROM topics_rules
+		WHERE topic_id = $1
+		ORDER BY position ASC, rule_id ASC`, topicID)
+	if err != nil {
+		return nil, err
+	}
+
+	rulesArr := make([]types.TopicRule, 0)
+	for rows.Next() {
+		var rule types.TopicRule
+		if err := rows.Scan(&rule.Rule_ID, &rule.Topic_ID, &rule.Position, &rule.Title, &rule.Body); err != nil {
+			return nil, err
+		}
+		rulesArr = append(rulesArr, rule)
+	}
+
+	return rulesArr, rows.Err()
+}
+
+// flair columns of the post queries, the post's flair and its author's flair in the topic
+const FlairColumns = `(SELECT jsonb_build_object('flair_id', f.flair_id, 'topic_id', f.topic_id, 'kind', f.kind, 'text', f.text,
+			'background_color', f.background_color, 'text_color', f.text_color, 'position', f.position)
+			FROM topics_flairs f WHERE f.flair_id = p.post_flair_id) AS post_flair,
+		(SELECT jsonb_build_object('flair_id', f.flair_id, 'topic_id', f.topic_id, 'kind', f.kind, 'text', f.text,
+			'background_color', f.background_color, 'text_color', f.text_color, 'position', f.position)
+			FROM topics_users_flairs tuf INNER JOIN topics_flairs f ON f.flair_id = tuf.flair_id WHERE tuf.topic_id = p.topic_id AND tuf.user_id = p.author_id) AS user_flair`
+
+// get user or post flairs of a topic in display order
+func GetTopicFlairs(ctx context.Context, q db.Querier, topicID int, kind string) ([]types.TopicFlair, error) {
+	rows, err := q.Query(ctx, `
+		SELECT flair_id, topic_id, kind, text, background_color, text_color, position
+		FROM topics_flairs
+		WHERE topic_id = $1 AND kind = $2
+		ORDER BY position ASC, flair_id ASC`, topicID, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	flairsArr := make([]types.TopicFlair, 0)
+	for rows.Next() {
+		var flair types.TopicFlair
+		if err := rows.Scan(&flair.Flair_ID, &flair.Topic_ID, &flair.Kind, &flair.Text,
+			&flair.Background_Color, &flair.Text_Color, &flair.Position); err != nil {
+			return nil, err
+		}
+		flairsArr = append(flairsArr, flair)
+	}
+
+	return flairsArr, rows.Err()
+}
+
+// check that a flair exists in the topic and is of the given kind
+func IsTopicFlair(ctx context.Context, q db.Querier, topicID int, flairID int, kind string) (bool, error) {
+	var exists bool
+	err := q.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM topics_flairs WHERE flair_id = $1 AND topic_id = $2 AND kind = $3)`,
+		flairID, topicID, kind).Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,72 @@
+// NSFW and spoiler flags on posts and the viewer's preferences for them
+package nsfw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	db "github.com/minrui13/backend/database"
+	"github.com/minrui13/backend/types"
+)
+
+// nsfw_preference values
+const (
+	PreferenceHide = "hide"
+	PreferenceBlur = "blur"
+	PreferenceShow = "show"
+)
+
+var ErrInvalidPreference = errors.New("nsfw preference must be hide, blur or show")
+
+// feed condition, the viewer's user id must be $1
+// anonymous viewers have no preferences, so feeds leave nsfw posts out for them
+var Visible = VisibleWhere(1)
+
+// feed condition with the viewer's user id at $userParam, for queries where $1 is taken
+func VisibleWhere(userParam int) string {
+	return fmt.Sprintf(` AND (NOT p.is_nsfw OR EXISTS (SELECT 1 FROM users nu WHERE nu.user_id = $%d AND nu.nsfw_preference <> 'hide'))`, userParam)
+}
+
+// whether nsfw posts are shown to the viewer at all, as Visible decides
+// anonymous viewers are passed as nil
+func Shown(ctx context.Context, q db.Querier, userID *int) (bool, error) {
+	if userID == nil {
+		return false, nil
+	}
+	var shown bool
+	err := q.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE user_id = $1 AND nsfw_preference <> 'hide')`, *userID).Scan(&shown)
+	return shown, err
+}
+
+// flag columns of the post queries, the viewer's user id must be $1
+// blur_nsfw and blur_spoiler tell the client to blur the post for this viewer
+const Columns = `p.is_nsfw,
+		p.is_spoiler,
+		p.is_nsfw AND NOT EXISTS (SELECT 1 FROM users nu WHERE nu.user_id = $1 AND nu.nsfw_preference = 'show') AS blur_nsfw,
+		p.is_spoiler AND NOT EXISTS (SELECT 1 FROM users nu WHERE nu.user_id = $1 AND NOT nu.blur_spoilers) AS blur_spoiler`
+
+// get the user's preferences
+func GetPreferences(ctx context.Context, q db.Querier, userID int) (types.UserPreferences, error) {
+	var prefs types.UserPreferences
+	err := q.QueryRow(ctx, `SELECT nsfw_preference, blur_spoilers FROM users WHERE user_id = $1`, userID).
+		Scan(&prefs.NSFW_Preference, &prefs.Blur_Spoilers)
+	return prefs, err
+}
+
+// save the user's preferences
+// returns ErrInvalidPreference, pgx.ErrNoRows if the user does not exist
+func SetPreferences(ctx context.Context, q db.Querier, userID int, prefs types.UserPreferences) (types.UserPreferences, error) {
+	switch prefs.NSFW_Preference {
+	case PreferenceHide, PreferenceBlur, PreferenceShow:
+	default:
+		return types.UserPreferences{}, ErrInvalidPreference
+	}
+
+	var result types.UserPreferences
+	err := q.QueryRow(ctx,
+		`UPDATE users SET nsfw_preference = $1, blur_spoilers = $2 WHERE user_id = $3
+		RETURNING nsfw_preference, blur_spoilers`,
+		prefs.NSFW_Preference, prefs.Blur_Spoilers, userID).Scan(&result.NSFW_Preference, &result.Blur_Spoilers)
+	return result, err
+}
@@ -0,0 +1,91 @@
+package nsfw
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/minrui13/backend/database/dbtest"
+	"github.com/minrui13/backend/types"
+)
+
+// unknown preferences are refused before the database is used
+func TestSetPreferencesRejectsUnknown(t *testing.T) {
+	for _, preference := range []string{"", "Hide", "SHOW", "none", "hide "} {
+		_, err := SetPreferences(context.Background(), nil, 1, types.UserPreferences{NSFW_Preference: preference})
+		if !errors.Is(err, ErrInvalidPreference) {
+			t.Errorf("SetPreferences(%q) = %v, want ErrInvalidPreference", preference, err)
+		}
+	}
+}
+
+func TestPreferences(t *testing.T) {
+	tx := dbtest.Tx(t)
+	ctx := context.Background()
+
+	var postID, userID int
+	err := tx.QueryRow(ctx, `SELECT p.post_id, u.user_id FROM posts p CROSS JOIN users u ORDER BY p.post_id, u.user_id LIMIT 1`).
+		Scan(&postID, &userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		t.Skip("the test database needs a post and a user")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE posts SET is_nsfw = TRUE, is_spoiler = TRUE WHERE post_id = $1`, postID); err != nil {
+		t.Fatal(err)
+	}
+
+	//what the feeds and the post results make of an nsfw spoiler for the viewer
+	view := func(viewerID int) (shown bool, visible bool, blurNSFW bool, blurSpoiler bool) {
+		t.Helper()
+		shown, err := Shown(ctx, tx, &viewerID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var isNSFW, isSpoiler bool
+		err = tx.QueryRow(ctx,
+			`SELECT `+Columns+`, EXISTS (SELECT 1 FROM posts p WHERE p.post_id = $2`+Visible+`)
+			FROM posts p WHERE p.post_id = $2`, viewerID, postID).Scan(&isNSFW, &isSpoiler, &blurNSFW, &blurSpoiler, &visible)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return shown, visible, blurNSFW, blurSpoiler
+	}
+
+	for _, c := range []struct {
+		prefs                        types.UserPreferences
+		shown, blurNSFW, blurSpoiler bool
+	}{
+		{types.UserPreferences{NSFW_Preference: PreferenceHide, Blur_Spoilers: true}, false, true, true},
+		{types.UserPreferences{NSFW_Preference: PreferenceBlur, Blur_Spoilers: true}, true, true, true},
+		{types.UserPreferences{NSFW_Preference: PreferenceShow, Blur_Spoilers: false}, true, false, false},
+	} {
+		saved, err := SetPreferences(ctx, tx, userID, c.prefs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := GetPreferences(ctx, tx, userID); err != nil || got != c.prefs || saved != c.prefs {
+			t.Errorf("saved %+v and read %+v, %v, want %+v", saved, got, err, c.prefs)
+		}
+		shown, visible, blurNSFW, blurSpoiler := view(userID)
+		if shown != c.shown || visible != c.shown || blurNSFW != c.blurNSFW || blurSpoiler != c.blurSpoiler {
+			t.Errorf("%+v: shown %v, visible %v, blur nsfw %v, blur spoiler %v, want %v, %v, %v, %v",
+				c.prefs, shown, visible, blurNSFW, blurSpoiler, c.shown, c.shown, c.blurNSFW, c.blurSpoiler)
+		}
+	}
+
+	//anonymous viewers get the defaults
+	if shown, err := Shown(ctx, tx, nil); err != nil || shown {
+		t.Errorf("Shown to anonymous viewers = %v, %v, want false", shown, err)
+	}
+	if shown, visible, blurNSFW, blurSpoiler := view(-1); shown || visible || !blurNSFW || !blurSpoiler {
+		t.Errorf("anonymous viewer: shown %v, visible %v, blur nsfw %v, blur spoiler %v, want false, false, true, true",
+			shown, visible, blurNSFW, blurSpoiler)
+	}
+
+	if _, err := SetPreferences(ctx, tx, -1, types.UserPreferences{NSFW_Preference: PreferenceShow}); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("SetPreferences of a missing user = %v, want pgx.ErrNoRows", err)
+	}
+}
@@ -0,0 +1,56 @@
+// Posts pinned to the top of a topic and site-wide announcements
+package pins
+
+import (
+	"context"
+	"errors"
+
+	db "github.com/minrui13/backend/database"
+)
+
+// pinned posts a topic can have at once
+const MaxPerTopic = 3
+
+var ErrTooManyPins = errors.New("a topic can have at most 3 pinned posts")
+
+// feed conditions, the paged feeds leave out what is shown above them
+const (
+	NotPinned       = ` AND NOT p.is_pinned`
+	NotAnnouncement = ` AND p.announced_date IS NULL`
+)
+
+// pin or unpin a post, run in the transaction that sets is_pinned
+// the topic row is locked so two pins at once cannot pass the limit
+func Pin(ctx context.Context, q db.Querier, topicID int, postID int, pinned bool) error {
+	if !pinned {
+		_, err := q.Exec(ctx, `UPDATE posts SET pinned_date = NULL WHERE post_id = $1`, postID)
+		return err
+	}
+
+	if _, err := q.Exec(ctx, `SELECT 1 FROM topics WHERE topic_id = $1 FOR UPDATE`, topicID); err != nil {
+		return err
+	}
+	var count int
+	err := q.QueryRow(ctx,
+		`SELECT COUNT(*) FROM posts
+		WHERE topic_id = $1 AND is_pinned AND status = 'published' AND deleted_date IS NULL`,
+		topicID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > MaxPerTopic {
+		return ErrTooManyPins
+	}
+
+	//pinning again moves the post to the top of the pins
+	_, err = q.Exec(ctx, `UPDATE posts SET pinned_date = CURRENT_TIMESTAMP WHERE post_id = $1`, postID)
+	return err
+}
+
+// make a post an announcement or stop it being one
+func Announce(ctx context.Context, q db.Querier, postID int, announced bool) error {
+	_, err := q.Exec(ctx,
+		`UPDATE posts SET announced_date = CASE WHEN $2 THEN CURRENT_TIMESTAMP END WHERE post_id = $1`,
+		postID, announced)
+	return err
+}
@@ -0,0 +1,79 @@
+package pins
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/minrui13/backend/database/dbtest"
+)
+
+func TestPinCap(t *testing.T) {
+	tx := dbtest.Tx(t)
+	ctx := context.Background()
+
+	var topicID int
+	err := tx.QueryRow(ctx,
+		`SELECT topic_id FROM posts WHERE status = 'published' AND deleted_date IS NULL
+		GROUP BY topic_id HAVING COUNT(*) > $1 ORDER BY topic_id LIMIT 1`, MaxPerTopic).Scan(&topicID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		t.Skip("the test database needs a topic with more published posts than the pin limit")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := tx.Query(ctx,
+		`SELECT post_id FROM posts WHERE topic_id = $1 AND status = 'published' AND deleted_date IS NULL
+		ORDER BY post_id LIMIT $2`, topicID, MaxPerTopic+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	postIDs, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE posts SET is_pinned = FALSE, pinned_date = NULL WHERE topic_id = $1`, topicID); err != nil {
+		t.Fatal(err)
+	}
+
+	//pin as the route does, setting is_pinned in the same transaction first
+	pin := func(postID int, pinned bool) error {
+		t.Helper()
+		if _, err := tx.Exec(ctx, `UPDATE posts SET is_pinned = $2 WHERE post_id = $1`, postID, pinned); err != nil {
+			t.Fatal(err)
+		}
+		return Pin(ctx, tx, topicID, postID, pinned)
+	}
+
+	for _, postID := range postIDs[:MaxPerTopic] {
+		if err := pin(postID, true); err != nil {
+			t.Fatalf("pinning post %d = %v, want nil", postID, err)
+		}
+	}
+	extra := postIDs[MaxPerTopic]
+	if err := pin(extra, true); !errors.Is(err, ErrTooManyPins) {
+		t.Errorf("pinning past the limit = %v, want ErrTooManyPins", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE posts SET is_pinned = FALSE WHERE post_id = $1`, extra); err != nil {
+		t.Fatal(err)
+	}
+
+	//pinning a pinned post again stays within the limit, unpinning makes room
+	if err := pin(postIDs[0], true); err != nil {
+		t.Errorf("pinning a pinned post again = %v, want nil", err)
+	}
+	if err := pin(postIDs[1], false); err != nil {
+		t.Fatal(err)
+	}
+	var pinnedDate *string
+	if err := tx.QueryRow(ctx, `SELECT pinned_date::text FROM posts WHERE post_id = $1`, postIDs[1]).Scan(&pinnedDate); err != nil {
+		t.Fatal(err)
+	}
+	if pinnedDate != nil {
+		t.Errorf("unpinned post kept pinned date %s", *pinnedDate)
+	}
+	if err := pin(extra, true); err != nil {
+		t.Errorf("pinning after an unpin = %v, want nil", err)
+	}
+}
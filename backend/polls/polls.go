@@ -0,0 +1,182 @@
+// Poll posts, options, ballots and tallies
+package polls
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	db "github.com/minrui13/backend/database"
+	"github.com/minrui13/backend/types"
+)
+
+// post_type values
+const (
+	PostTypeText = "text"
+	PostTypePoll = "poll"
+)
+
+const (
+	MinOptions    = 2
+	MaxOptions    = 10
+	maxOptionText = 200
+)
+
+var (
+	ErrOptionCount     = errors.New("a poll needs 2 to 10 options")
+	ErrInvalidOption   = errors.New("poll options must be unique, not empty and at most 200 characters")
+	ErrCloseInPast     = errors.New("closing date must be in the future")
+	ErrNotPoll         = errors.New("post is not a poll")
+	ErrPollClosed      = errors.New("poll is closed")
+	ErrAlreadyVoted    = errors.New("you have already voted in this poll")
+	ErrInvalidChoice   = errors.New("choose options of this poll")
+	ErrSingleChoice    = errors.New("this poll allows only one option")
+	ErrNoOptionsChosen = errors.New("choose at least one option")
+)
+
+// poll column of the post queries, the viewer's user id must be $1
+// tallies are left out until the viewer has voted or the poll has closed, deleted posts show no poll
+// closes_date is stored in UTC, so it is returned with a Z
+const Column = `(SELECT jsonb_build_object(
+			'allow_multiple', pl.allow_multiple,
+			'closes_date', to_char(pl.closes_date, 'YYYY-MM-DD"T"HH24:MI:SS"Z"'),
+			'is_closed', closed.is_closed,
+			'has_voted', ppv.user_id IS NOT NULL,
+			'voted_option_ids', COALESCE(to_jsonb(ppv.option_ids), '[]'::jsonb),
+			'voter_count', CASE WHEN state.show_results THEN pl.voter_count END,
+			'options', (SELECT jsonb_agg(jsonb_build_object('option_id', o.option_id, 'text', o.text,
+				'vote_count', CASE WHEN state.show_results THEN o.vote_count END) ORDER BY o.position)
+				FROM posts_polls_options o WHERE o.post_id = pl.post_id))
+			FROM posts_polls pl
+			LEFT JOIN posts_polls_votes ppv ON ppv.post_id = pl.post_id AND ppv.user_id = $1
+			CROSS JOIN LATERAL (SELECT COALESCE(pl.closes_date <= LOCALTIMESTAMP, FALSE) AS is_closed) closed
+			CROSS JOIN LATERAL (SELECT closed.is_closed OR ppv.user_id IS NOT NULL AS show_results) state
+			WHERE pl.post_id = p.post_id AND p.deleted_date IS NULL) AS poll`
+
+// trim and check the options of a new poll, the closing date is converted to UTC
+func Check(poll *types.PollPayload) error {
+	if len(poll.Options) < MinOptions || len(poll.Options) > MaxOptions {
+		return ErrOptionCount
+	}
+	seen := map[string]bool{}
+	for i, option := range poll.Options {
+		option = strings.TrimSpace(option)
+		key := strings.ToLower(option)
+		if option == "" || utf8.RuneCountInString(option) > maxOptionText || seen[key] {
+			return ErrInvalidOption
+		}
+		seen[key] = true
+		poll.Options[i] = option
+	}
+	if poll.Closes_Date != nil {
+		if !poll.Closes_Date.After(time.Now()) {
+			return ErrCloseInPast
+		}
+		//the column keeps only the wall clock, compared with LOCALTIMESTAMP of a UTC session
+		utc := poll.Closes_Date.UTC()
+		poll.Closes_Date = &utc
+	}
+	return nil
+}
+
+// store the poll of a new post, run in the transaction that inserts the post
+func Create(ctx context.Context, q db.Querier, postID int, poll types.PollPayload) error {
+	_, err := q.Exec(ctx,
+		`INSERT INTO posts_polls (post_id, allow_multiple, closes_date) VALUES ($1, $2, $3)`,
+		postID, poll.Allow_Multiple, poll.Closes_Date)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(ctx,
+		`INSERT INTO posts_polls_options (post_id, position, text)
+		SELECT $1, o.position, o.text FROM unnest($2::text[]) WITH ORDINALITY AS o(text, position)`,
+		postID, poll.Options)
+	return err
+}
+
+// cast the user's one ballot and count it
+// returns pgx.ErrNoRows if the post is not a published poll
+func Vote(ctx context.Context, q db.Querier, postID int, userID int, optionIDs []int) error {
+	if len(optionIDs) == 0 {
+		return ErrNoOptionsChosen
+	}
+	slices.Sort(optionIDs)
+	optionIDs = slices.Compact(optionIDs)
+
+	//lock the poll so a closing time or tally is not read while another ballot is counted
+	var allowMultiple, isClosed bool
+	err := q.QueryRow(ctx,
+		`SELECT pl.allow_multiple, COALESCE(pl.closes_date <= LOCALTIMESTAMP, FALSE)
+		FROM posts_polls pl
+		INNER JOIN posts p ON p.post_id = pl.post_id
+		WHERE pl.post_id = $1 AND p.status = 'published' AND p.deleted_date IS NULL
+		FOR UPDATE OF pl`,
+		postID).Scan(&allowMultiple, &isClosed)
+	if err != nil {
+		return err
+	}
+	if isClosed {
+		return ErrPollClosed
+	}
+	if !allowMultiple && len(optionIDs) > 1 {
+		return ErrSingleChoice
+	}
+
+	var validCount int
+	err = q.QueryRow(ctx,
+		`SELECT COUNT(*) FROM posts_polls_options WHERE post_id = $1 AND option_id = ANY($2)`,
+		postID, optionIDs).Scan(&validCount)
+	if err != nil {
+		return err
+	}
+	if validCount != len(optionIDs) {
+		return ErrInvalidChoice
+	}
+
+	_, err = q.Exec(ctx,
+		`INSERT INTO posts_polls_votes (post_id, user_id, option_ids) VALUES ($1, $2, $3)`,
+		postID, userID, optionIDs)
+	if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
+		return ErrAlreadyVoted
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec(ctx,
+		`UPDATE posts_polls_options SET vote_count = vote_count + 1 WHERE post_id = $1 AND option_id = ANY($2)`,
+		postID, optionIDs)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(ctx, `UPDATE posts_polls SET voter_count = voter_count + 1 WHERE post_id = $1`, postID)
+	return err
+}
+
+// poll of a post as seen by the user
+func Get(ctx context.Context, q db.Querier, postID int, userID int) (*types.Poll, error) {
+	var poll *types.Poll
+	err := q.QueryRow(ctx, `SELECT `+Column+` FROM posts p WHERE p.post_id = $2`, userID, postID).Scan(&poll)
+	if err != nil {
+		return nil, err
+	}
+	if poll == nil {
+		return nil, ErrNotPoll
+	}
+	return poll, nil
+}
+
+// errors caused by the ballot rather than the database
+func IsInvalidVote(err error) bool {
+	return errors.Is(err, ErrInvalidChoice) || errors.Is(err, ErrSingleChoice) || errors.Is(err, ErrNoOptionsChosen)
+}
+
+// helper so callers can tell a missing poll apart from a database error
+func IsNotFound(err error) bool {
+	return errors.Is(err, pgx.ErrNoRows) || errors.Is(err, ErrNotPoll)
+}
@@ -0,0 +1,62 @@
+package polls
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minrui13/backend/types"
+)
+
+func TestCheck(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		poll    types.PollPayload
+		wantErr error
+	}{
+		{name: "two options", poll: types.PollPayload{Options: []string{"yes", "no"}}},
+		{name: "ten options", poll: types.PollPayload{Options: strings.Split("a b c d e f g h i j", " ")}},
+		{name: "closes in the future", poll: types.PollPayload{Options: []string{"yes", "no"}, Closes_Date: &future}},
+		{name: "one option", poll: types.PollPayload{Options: []string{"yes"}}, wantErr: ErrOptionCount},
+		{name: "no options", poll: types.PollPayload{}, wantErr: ErrOptionCount},
+		{name: "eleven options", poll: types.PollPayload{Options: strings.Split("a b c d e f g h i j k", " ")}, wantErr: ErrOptionCount},
+		{name: "blank option", poll: types.PollPayload{Options: []string{"yes", "   "}}, wantErr: ErrInvalidOption},
+		{name: "duplicate option", poll: types.PollPayload{Options: []string{"Yes", " yes "}}, wantErr: ErrInvalidOption},
+		{name: "long option", poll: types.PollPayload{Options: []string{"yes", strings.Repeat("é", maxOptionText+1)}}, wantErr: ErrInvalidOption},
+		{name: "closes in the past", poll: types.PollPayload{Options: []string{"yes", "no"}, Closes_Date: &past}, wantErr: ErrCloseInPast},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Check(&tt.poll); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Check returned %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckTrimsOptions(t *testing.T) {
+	poll := types.PollPayload{Options: []string{"  yes ", "no\n", strings.Repeat("é", maxOptionText)}}
+	if err := Check(&poll); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"yes", "no", strings.Repeat("é", maxOptionText)}; !slices.Equal(poll.Options, want) {
+		t.Errorf("Check left options %q, want %q", poll.Options, want)
+	}
+}
+
+func TestCheckClosesInUTC(t *testing.T) {
+	closes := time.Now().Add(time.Hour).In(time.FixedZone("+08", 8*60*60))
+	poll := types.PollPayload{Options: []string{"yes", "no"}, Closes_Date: &closes}
+	if err := Check(&poll); err != nil {
+		t.Fatal(err)
+	}
+	if poll.Closes_Date.Location() != time.UTC || !poll.Closes_Date.Equal(closes) {
+		t.Errorf("closing date = %v, want %v in UTC", poll.Closes_Date, closes)
+	}
+}
@@ -0,0 +1,81 @@
+// Post drafts and the publisher for scheduled posts
+package publishing
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	db "github.com/minrui13/backend/database"
+	"github.com/minrui13/backend/jobs"
+)
+
+// post status values
+const (
+	StatusDraft     = "draft"
+	StatusScheduled = "scheduled"
+	StatusPublished = "published"
+)
+
+var (
+	ErrInvalidStatus    = errors.New("status must be draft, scheduled or published")
+	ErrScheduleInPast   = errors.New("scheduled date must be in the future")
+	ErrAlreadyPublished = errors.New("post is already published")
+)
+
+// check the requested status, empty means publish straight away
+// a scheduled date is converted to UTC, the column keeps only the wall clock
+func CheckStatus(status string, scheduledDate *time.Time) (string, error) {
+	switch status {
+	case "":
+		return StatusPublished, nil
+	case StatusDraft, StatusPublished:
+		return status, nil
+	case StatusScheduled:
+		if scheduledDate == nil || !scheduledDate.After(time.Now()) {
+			return "", ErrScheduleInPast
+		}
+		*scheduledDate = scheduledDate.UTC()
+		return status, nil
+	default:
+		return "", ErrInvalidStatus
+	}
+}
+
+// check every minute for scheduled posts that are due
+var PublishJob = jobs.Job{
+	Name:     "scheduled post publisher",
+	Interval: time.Minute,
+	Run: func(ctx context.Context, pool *pgxpool.Pool) error {
+		_, err := PublishDue(ctx, pool)
+		return err
+	},
+}
+
+// publish scheduled posts whose time has come
+// created_date becomes the scheduled time so feeds order by publish time
+// posts whose topic was archived or whose author was banned meanwhile go back to drafts
+func PublishDue(ctx context.Context, q db.Querier) (int64, error) {
+	tag, err := q.Exec(ctx,
+		`UPDATE posts p SET
+			status = CASE WHEN blocked.is_blocked THEN 'draft' ELSE 'published' END,
+			created_date = CASE WHEN blocked.is_blocked THEN p.created_date ELSE p.scheduled_date END,
+			scheduled_date = NULL
+		FROM (
+			SELECT p2.post_id,
+				t.archived_date IS NOT NULL OR EXISTS (
+					SELECT 1 FROM topics_bans b
+					WHERE b.topic_id = p2.topic_id AND b.user_id = p2.author_id
+					AND (b.expires_date IS NULL OR b.expires_date > CURRENT_TIMESTAMP)
+				) AS is_blocked
+			FROM posts p2
+			INNER JOIN topics t ON t.topic_id = p2.topic_id
+			WHERE p2.status = 'scheduled' AND p2.scheduled_date <= CURRENT_TIMESTAMP
+		) AS blocked
+		WHERE p.post_id = blocked.post_id`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
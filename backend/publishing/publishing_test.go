@@ -0,0 +1,114 @@
+package publishing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/minrui13/backend/database/dbtest"
+)
+
+func TestCheckStatus(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		status        string
+		scheduledDate *time.Time
+		want          string
+		wantErr       error
+	}{
+		{"", nil, StatusPublished, nil},
+		{StatusPublished, &future, StatusPublished, nil},
+		{StatusDraft, nil, StatusDraft, nil},
+		{StatusScheduled, &future, StatusScheduled, nil},
+		{StatusScheduled, nil, "", ErrScheduleInPast},
+		{StatusScheduled, &past, "", ErrScheduleInPast},
+		{"archived", nil, "", ErrInvalidStatus},
+	}
+
+	for _, tt := range tests {
+		got, err := CheckStatus(tt.status, tt.scheduledDate)
+		if got != tt.want || !errors.Is(err, tt.wantErr) {
+			t.Errorf("CheckStatus(%q, %v) = %q, %v, want %q, %v", tt.status, tt.scheduledDate, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckStatusStoresUTC(t *testing.T) {
+	singapore := time.FixedZone("+08", 8*60*60)
+	scheduled := time.Now().Add(time.Hour).In(singapore)
+	instant := scheduled
+
+	if _, err := CheckStatus(StatusScheduled, &scheduled); err != nil {
+		t.Fatal(err)
+	}
+	if scheduled.Location() != time.UTC || !scheduled.Equal(instant) {
+		t.Errorf("scheduled date = %v, want %v in UTC", scheduled, instant)
+	}
+}
+
+func TestPublishDue(t *testing.T) {
+	tx := dbtest.Tx(t)
+	ctx := context.Background()
+
+	var postID, topicID int
+	err := tx.QueryRow(ctx, `SELECT post_id, topic_id FROM posts ORDER BY post_id LIMIT 1`).Scan(&postID, &topicID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		t.Skip("the test database has no posts")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE topics SET archived_date = NULL WHERE topic_id = $1`, topicID); err != nil {
+		t.Fatal(err)
+	}
+
+	schedule := func(in string) time.Time {
+		t.Helper()
+		var scheduled time.Time
+		err := tx.QueryRow(ctx,
+			`UPDATE posts SET status = 'scheduled', scheduled_date = date_trunc('second', CURRENT_TIMESTAMP + $2::interval)
+			WHERE post_id = $1 RETURNING scheduled_date`, postID, in).Scan(&scheduled)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := PublishDue(ctx, tx); err != nil {
+			t.Fatal(err)
+		}
+		return scheduled
+	}
+	check := func(what string, wantStatus string, wantCreated *time.Time) {
+		t.Helper()
+		var status string
+		var created time.Time
+		var scheduled *time.Time
+		if err := tx.QueryRow(ctx, `SELECT status, created_date, scheduled_date FROM posts WHERE post_id = $1`, postID).Scan(&status, &created, &scheduled); err != nil {
+			t.Fatal(err)
+		}
+		if status != wantStatus {
+			t.Errorf("%s: status %s, want %s", what, status, wantStatus)
+		}
+		if wantStatus != StatusScheduled && scheduled != nil {
+			t.Errorf("%s: scheduled date %v kept, want it cleared", what, scheduled)
+		}
+		if wantCreated != nil && !created.Equal(*wantCreated) {
+			t.Errorf("%s: created %v, want the scheduled time %v", what, created, wantCreated)
+		}
+	}
+
+	schedule("1 hour")
+	check("post scheduled for later", StatusScheduled, nil)
+
+	due := schedule("-1 minute")
+	check("due post", StatusPublished, &due)
+
+	//an archived topic sends the post back to the drafts
+	if _, err := tx.Exec(ctx, `UPDATE topics SET archived_date = CURRENT_TIMESTAMP WHERE topic_id = $1`, topicID); err != nil {
+		t.Fatal(err)
+	}
+	schedule("-1 minute")
+	check("due post of an archived topic", StatusDraft, &due)
+}
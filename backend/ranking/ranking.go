@@ -0,0 +1,269 @@
+// Scored sort orders for the post feeds
+// the expressions run over the feed query aliases p (posts), pv (vote sums) and pc (comment counts)
+package ranking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/minrui13/backend/cursor"
+	db "github.com/minrui13/backend/database"
+	"github.com/minrui13/backend/types"
+)
+
+// sortBy values of the feeds
+const (
+	SortHot           = "buzz"
+	SortNew           = "new"
+	SortAlpha         = "alpha"
+	SortTop           = "top"
+	SortControversial = "controversial"
+	SortRising        = "rising"
+	SortViews         = "views"
+)
+
+// windows of the top sort
+const (
+	WindowDay   = "day"
+	WindowWeek  = "week"
+	WindowMonth = "month"
+	WindowYear  = "year"
+	WindowAll   = "all"
+)
+
+// how far back rising looks
+const risingWindow = "1 day"
+
+var (
+	ErrInvalidWindow    = errors.New("window must be day, week, month, year or all")
+	ErrInvalidDateRange = errors.New("to date must not be before from date")
+)
+
+const (
+	voteSum      = `(p.upvote_count - p.downvote_count)`
+	commentCount = `p.comment_count`
+
+	// reddit's hot score, the order of magnitude of the votes plus the age
+	// every 12.5 hours newer counts as much as ten times the votes
+	// it only changes with votes so pages stay stable
+	hotScore = `(SIGN(` + voteSum + `) * LOG(GREATEST(ABS(` + voteSum + `), 1)::float8)
+		+ (EXTRACT(EPOCH FROM p.created_date)::float8 - 1134028003) / 45000)`
+
+	// many votes split evenly, the number of votes to the power of how balanced they are
+	controversy = `(CASE WHEN p.upvote_count = 0 OR p.downvote_count = 0 THEN 0
+		ELSE POWER((p.upvote_count + p.downvote_count)::float8,
+			LEAST(p.upvote_count, p.downvote_count)::float8 / GREATEST(p.upvote_count, p.downvote_count))
+		END)::float8`
+
+	// votes and comments per hour, with gravity so a post has to keep gaining to stay up
+	// %[1]s is the placeholder of the time the scores are taken at
+	risingScore = `((` + voteSum + ` + ` + commentCount + `)::float8
+		/ POWER(EXTRACT(EPOCH FROM (%[1]s::timestamp - p.created_date))::float8 / 3600 + 2, 1.5))`
+)
+
+// sorts ordered by a score from this package, new and alpha are plain columns the feeds order by themselves
+// anything unknown is the default hot sort
+func IsRanked(sortBy string) bool {
+	return sortBy != SortNew && sortBy != SortAlpha
+}
+
+// the database time, posts are stored in database time
+func Now(ctx context.Context, q db.Querier) (time.Time, error) {
+	var now time.Time
+	err := q.QueryRow(ctx, `SELECT LOCALTIMESTAMP`).Scan(&now)
+	return now, err
+}
+
+// start of a top window, nil for all time
+func WindowStart(now time.Time, window string) (*time.Time, error) {
+	var start time.Time
+	switch window {
+	case "", WindowDay:
+		start = now.AddDate(0, 0, -1)
+	case WindowWeek:
+		start = now.AddDate(0, 0, -7)
+	case WindowMonth:
+		start = now.AddDate(0, -1, 0)
+	case WindowYear:
+		start = now.AddDate(-1, 0, 0)
+	case WindowAll:
+		return nil, nil
+	default:
+		return nil, ErrInvalidWindow
+	}
+	return &start, nil
+}
+
+// read the from and to query parameters of a feed, YYYY-MM-DD
+func ParseDateRange(query url.Values) (types.PostByFollowPayload, error) {
+	var dateRange types.PostByFollowPayload
+	for name, target := range map[string]**time.Time{
+		"from": &dateRange.From_Date,
+		"to":   &dateRange.To_Date,
+	} {
+		if value := query.Get(name); value != "" {
+			date, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				return dateRange, errors.New("invalid " + name + " date")
+			}
+			*target = &date
+		}
+	}
+	if dateRange.From_Date != nil && dateRange.To_Date != nil && dateRange.To_Date.Before(*dateRange.From_Date) {
+		return dateRange, ErrInvalidDateRange
+	}
+	return dateRange, nil
+}
+
+// filter of a feed's WHERE on the created date range, starts with AND
+// the placeholders of from and to are fromParam and the one after, both may be null
+// it is applied to every page, the cursors only move within it
+func DateRangeWhere(fromParam int) string {
+	return fmt.Sprintf(` AND ($%[1]d::date IS NULL OR p.created_date >= $%[1]d::date) AND ($%[2]d::date IS NULL OR p.created_date < $%[2]d::date + 1)`,
+		fromParam, fromParam+1)
+}
+
+// parts of a feed query for a ranked sort
+type Query struct {
+	sortBy string
+	//select as sort_score so the next cursor can carry it
+	Score string
+	//appended to the feed's WHERE, starts with AND
+	Where string
+	Order string
+	//values for the placeholders of Score and Where in order
+	Args  []any
+	since *time.Time
+	asOf  time.Time
+}
+
+// build the score, filter and order of a ranked sort
+// placeholders are numbered from firstParam, the feed's own parameters come before
+// now is the database time, only used for the first page
+func Build(sortBy string, window string, cursorParam string, now time.Time, firstParam int) (Query, error) {
+	query := Query{sortBy: sortBy}
+	param := func(value any) string {
+		query.Args = append(query.Args, value)
+		return fmt.Sprintf("$%d", firstParam+len(query.Args)-1)
+	}
+
+	switch sortBy {
+	case SortTop:
+		query.Score = voteSum + `::float8`
+		var after *types.TopCursor
+		if cursorParam != "" {
+			c, err := cursor.DecodeTopCursor(cursorParam)
+			if err != nil {
+				return query, err
+			}
+			after = c
+			query.since = c.Since
+		} else {
+			since, err := WindowStart(now, window)
+			if err != nil {
+				return query, err
+			}
+			query.since = since
+		}
+		if query.since != nil {
+			query.Where += ` AND p.created_date >= ` + param(*query.since) + `::timestamp`
+		}
+		if after != nil {
+			query.Where += fmt.Sprintf(` AND (%s, %s, p.post_id) < (%s::bigint, %s::bigint, %s::int)`,
+				voteSum, commentCount, param(after.Sum_Votes_Count), param(after.Comment_Count), param(after.Post_ID))
+		}
+		query.Order = ` ORDER BY ` + voteSum + ` DESC, ` + commentCount + ` DESC, p.post_id DESC`
+
+	case SortControversial:
+		query.Score = controversy
+		if cursorParam != "" {
+			c, err := cursor.DecodeControversialCursor(cursorParam)
+			if err != nil {
+				return query, err
+			}
+			query.Where = fmt.Sprintf(` AND (%s, p.post_id) < (%s::float8, %s::int)`, controversy, param(c.Controversy), param(c.Post_ID))
+		}
+		query.Order = ` ORDER BY sort_score DESC, p.post_id DESC`
+
+	case SortViews:
+		query.Score = `p.view_count::float8`
+		if cursorParam != "" {
+			c, err := cursor.DecodeViewsCursor(cursorParam)
+			if err != nil {
+				return query, err
+			}
+			query.Where = fmt.Sprintf(` AND (p.view_count, p.post_id) < (%s::int, %s::int)`, param(c.View_Count), param(c.Post_ID))
+		}
+		query.Order = ` ORDER BY p.view_count DESC, p.post_id DESC`
+
+	case SortRising:
+		query.asOf = now
+		var after *types.RisingCursor
+		if cursorParam != "" {
+			c, err := cursor.DecodeRisingCursor(cursorParam)
+			if err != nil {
+				return query, err
+			}
+			after = c
+			query.asOf = c.As_Of
+		}
+		asOf := param(query.asOf)
+		score := fmt.Sprintf(risingScore, asOf)
+		query.Score = score
+		query.Where = fmt.Sprintf(` AND p.created_date <= %[1]s::timestamp AND p.created_date > %[1]s::timestamp - INTERVAL '%[2]s'`, asOf, risingWindow)
+		if after != nil {
+			query.Where += fmt.Sprintf(` AND (%s, p.post_id) < (%s::float8, %s::int)`, score, param(after.Rising_Score), param(after.Post_ID))
+		}
+		query.Order = ` ORDER BY sort_score DESC, p.post_id DESC`
+
+	default:
+		query.sortBy = SortHot
+		query.Score = hotScore
+		if cursorParam != "" {
+			c, err := cursor.DecodeHotCursor(cursorParam)
+			if err != nil {
+				return query, err
+			}
+			query.Where = fmt.Sprintf(` AND (%s, p.post_id) < (%s::float8, %s::int)`, hotScore, param(c.Hot_Score), param(c.Post_ID))
+		}
+		query.Order = ` ORDER BY sort_score DESC, p.post_id DESC`
+	}
+	return query, nil
+}
+
+// cursor for the page after the given last post
+func (q Query) NextCursor(score float64, sumVotes int, commentCount int, postID int) (string, error) {
+	switch q.sortBy {
+	case SortTop:
+		return cursor.EncodeTopCursor(types.TopCursor{
+			Sum_Votes_Count: sumVotes,
+			Comment_Count:   commentCount,
+			Post_ID:         postID,
+			Since:           q.since,
+		})
+	case SortControversial:
+		return cursor.EncodeControversialCursor(types.ControversialCursor{
+			Controversy: score,
+			Post_ID:     postID,
+		})
+	case SortViews:
+		return cursor.EncodeViewsCursor(types.ViewsCursor{
+			View_Count: int(score),
+			Post_ID:    postID,
+		})
+	case SortRising:
+		return cursor.EncodeRisingCursor(types.RisingCursor{
+			Rising_Score: score,
+			Post_ID:      postID,
+			As_Of:        q.asOf,
+		})
+	default:
+		return cursor.EncodeHotCursor(types.HotCursor{
+			Hot_Score: score,
+			Post_ID:   postID,
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package ranking
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+
+func TestIsRanked(t *testing.T) {
+	for sortBy, want := range map[string]bool{
+		SortHot: true, SortTop: true, SortControversial: true, SortRising: true, SortViews: true, "": true,
+		SortNew: false, SortAlpha: false,
+	} {
+		if got := IsRanked(sortBy); got != want {
+			t.Errorf("IsRanked(%q) = %v, want %v", sortBy, got, want)
+		}
+	}
+}
+
+func TestWindowStart(t *testing.T) {
+	tests := []struct {
+		window string
+		want   *time.Time
+	}{
+		{"", ptr(now.AddDate(0, 0, -1))},
+		{WindowDay, ptr(now.AddDate(0, 0, -1))},
+		{WindowWeek, ptr(now.AddDate(0, 0, -7))},
+		{WindowMonth, ptr(now.AddDate(0, -1, 0))},
+		{WindowYear, ptr(now.AddDate(-1, 0, 0))},
+		{WindowAll, nil},
+	}
+
+	for _, tt := range tests {
+		got, err := WindowStart(now, tt.window)
+		if err != nil {
+			t.Fatalf("WindowStart(%q) returned %v", tt.window, err)
+		}
+		if (got == nil) != (tt.want == nil) || (got != nil && !got.Equal(*tt.want)) {
+			t.Errorf("WindowStart(%q) = %v, want %v", tt.window, got, tt.want)
+		}
+	}
+
+	if _, err := WindowStart(now, "fortnight"); !errors.Is(err, ErrInvalidWindow) {
+		t.Errorf("WindowStart(fortnight) returned %v, want ErrInvalidWindow", err)
+	}
+}
+
+// the first page of every sort has no cursor filter, the next page filters after the last post
+// placeholders continue from firstParam and match the args
+func TestBuildPages(t *testing.T) {
+	sorts := []string{SortHot, SortTop, SortControversial, SortRising, SortViews}
+	for _, sortBy := range sorts {
+		t.Run(sortBy, func(t *testing.T) {
+			first, err := Build(sortBy, WindowWeek, "", now, 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first.Score == "" || !strings.HasPrefix(first.Order, " ORDER BY") {
+				t.Fatalf("Build(%q) = %+v, want a score and an order", sortBy, first)
+			}
+			checkParams(t, first, 5)
+
+			c, err := first.NextCursor(12.5, 7, 3, 42)
+			if err != nil {
+				t.Fatal(err)
+			}
+			next, err := Build(sortBy, WindowWeek, c, now.Add(time.Hour), 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkParams(t, next, 5)
+			if len(next.Args) <= len(first.Args) || next.Args[len(next.Args)-1] != 42 {
+				t.Errorf("next page of %q has args %v, want them to end with the last post id", sortBy, next.Args)
+			}
+		})
+	}
+}
+
+// Where and Score use exactly the placeholders firstParam to firstParam + len(Args) - 1
+func checkParams(t *testing.T, query Query, firstParam int) {
+	t.Helper()
+	sql := query.Score + query.Where
+	for i := range query.Args {
+		if placeholder := fmt.Sprintf("$%d", firstParam+i); !strings.Contains(sql, placeholder) {
+			t.Errorf("%s is not used in %q", placeholder, sql)
+		}
+	}
+	if placeholder := fmt.Sprintf("$%d", firstParam+len(query.Args)); strings.Contains(sql, placeholder) {
+		t.Errorf("%s has no arg in %q", placeholder, sql)
+	}
+}
+
+func TestBuildKeepsTheFirstPageTime(t *testing.T) {
+	//top keeps the window of the first page
+	first, err := Build(SortTop, WindowDay, "", now, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := first.NextCursor(0, 7, 3, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := Build(SortTop, WindowYear, c, now.AddDate(0, 0, 3), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if since, ok := next.Args[0].(time.Time); !ok || !since.Equal(now.AddDate(0, 0, -1)) {
+		t.Errorf("next top page starts at %v, want the first page's window start %v", next.Args[0], now.AddDate(0, 0, -1))
+	}
+
+	//rising keeps the time its scores were taken at
+	first, err = Build(SortRising, "", "", now, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = first.NextCursor(1.5, 0, 0, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err = Build(SortRising, "", c, now.Add(time.Hour), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asOf, ok := next.Args[0].(time.Time); !ok || !asOf.Equal(now) {
+		t.Errorf("next rising page is scored at %v, want %v", next.Args[0], now)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	if _, err := Build(SortTop, "fortnight", "", now, 1); !errors.Is(err, ErrInvalidWindow) {
+		t.Errorf("Build with an unknown window returned %v, want ErrInvalidWindow", err)
+	}
+	for _, sortBy := range []string{SortHot, SortTop, SortControversial, SortRising, SortViews} {
+		if _, err := Build(sortBy, "", "not a cursor", now, 1); err == nil {
+			t.Errorf("Build(%q) with a broken cursor returned no error", sortBy)
+		}
+	}
+}
+
+func TestBuildDefaultsToHot(t *testing.T) {
+	query, err := Build("unknown", "", "", now, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hot, _ := Build(SortHot, "", "", now, 1)
+	if query.Score != hot.Score || query.Order != hot.Order {
+		t.Errorf("Build(unknown) = %+v, want the hot sort", query)
+	}
+}
+
+func TestParseDateRange(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		query    string
+		wantFrom *time.Time
+		wantTo   *time.Time
+		wantErr  bool
+	}{
+		{query: ""},
+		{query: "from=2026-01-01", wantFrom: &from},
+		{query: "to=2026-01-31", wantTo: &to},
+		{query: "from=2026-01-01&to=2026-01-31", wantFrom: &from, wantTo: &to},
+		{query: "from=2026-01-31&to=2026-01-31", wantFrom: &to, wantTo: &to},
+		{query: "from=01-01-2026", wantErr: true},
+		{query: "to=tomorrow", wantErr: true},
+		{query: "from=2026-02-30", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		values, _ := url.ParseQuery(tt.query)
+		got, err := ParseDateRange(values)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseDateRange(%q) returned %v, want error %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if !sameDate(got.From_Date, tt.wantFrom) || !sameDate(got.To_Date, tt.wantTo) {
+			t.Errorf("ParseDateRange(%q) = %v to %v, want %v to %v", tt.query, got.From_Date, got.To_Date, tt.wantFrom, tt.wantTo)
+		}
+	}
+
+	values, _ := url.ParseQuery("from=2026-01-31&to=2026-01-01")
+	if _, err := ParseDateRange(values); !errors.Is(err, ErrInvalidDateRange) {
+		t.Errorf("ParseDateRange with to before from returned %v, want ErrInvalidDateRange", err)
+	}
+}
+
+func sameDate(got *time.Time, want *time.Time) bool {
+	if got == nil || want == nil {
+		return got == want
+	}
+	return got.Equal(*want)
+}
+
+func TestDateRangeWhere(t *testing.T) {
+	//to is inclusive, the whole day is in the range
+	want := ` AND ($3::date IS NULL OR p.created_date >= $3::date) AND ($4::date IS NULL OR p.created_date < $4::date + 1)`
+	if got := DateRangeWhere(3); got != want {
+		t.Errorf("DateRangeWhere(3) = %q, want %q", got, want)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
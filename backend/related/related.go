@@ -0,0 +1,115 @@
+// Related posts shown under a post
+package related
+
+import (
+	"context"
+	"time"
+
+	db "github.com/minrui13/backend/database"
+	"github.com/minrui13/backend/nsfw"
+	"github.com/minrui13/backend/types"
+)
+
+const (
+	DefaultLimit = 5
+	MaxLimit     = 20
+	// candidates taken from each signal before they are scored
+	poolSize = 100
+)
+
+// score of a candidate, text similarity is a ts_rank between 0 and 1
+// so a close title match outweighs sharing only a tag or a topic
+const score = `(CASE WHEN p.tag_id = s.tag_id THEN 1.0 ELSE 0 END
+	+ CASE WHEN p.topic_id = s.topic_id THEN 0.5 ELSE 0 END
+	+ COALESCE(ts_rank_cd(p.search_vector, s.q, 1), 0) * 2.0)::float8`
+
+// posts of private topics are only candidates for viewers following the topic, as in the home feed
+const visible = ` AND (EXISTS (SELECT 1 FROM topics vt WHERE vt.topic_id = p.topic_id AND vt.visibility = 'public')
+	OR EXISTS (SELECT 1 FROM topics_followers tf WHERE tf.topic_id = p.topic_id AND tf.user_id = $1))`
+
+// posts related to the post by tag, topic and title words, best first
+// the post itself, its original and other crossposts of it, posts the viewer voted on
+// and posts of private topics the viewer does not follow are left out
+// returns pgx.ErrNoRows if the post is not published
+func Posts(ctx context.Context, q db.Querier, postID int, userID int) ([]types.RelatedPost, error) {
+	var exists bool
+	err := q.QueryRow(ctx, `SELECT TRUE FROM posts WHERE post_id = $1 AND status = 'published' AND deleted_date IS NULL`, postID).
+		Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+
+	//the source's title lexemes are or'd together, already stemmed so they are parsed with the simple config
+	rows, err := q.Query(ctx,
+		`WITH s AS (
+			SELECT sp.post_id, sp.topic_id, sp.tag_id, COALESCE(sp.crosspost_of_id, sp.post_id) AS original_id,
+				(SELECT to_tsquery('simple', string_agg(quote_literal(l.lexeme), ' | '))
+					FROM unnest(ts_filter(sp.search_vector, '{a}')) l) AS q
+			FROM posts sp
+			WHERE sp.post_id = $2
+		),
+		candidates AS (
+			(SELECT p.post_id FROM posts p, s
+				WHERE p.topic_id = s.topic_id AND p.status = 'published' AND p.deleted_date IS NULL`+visible+`
+				ORDER BY p.created_date DESC LIMIT $3)
+			UNION
+			(SELECT p.post_id FROM posts p, s
+				WHERE p.tag_id = s.tag_id AND p.status = 'published' AND p.deleted_date IS NULL`+visible+`
+				ORDER BY p.created_date DESC LIMIT $3)
+			UNION
+			(SELECT p.post_id FROM posts p, s
+				WHERE p.search_vector @@ s.q AND p.status = 'published' AND p.deleted_date IS NULL`+visible+`
+				ORDER BY ts_rank_cd(p.search_vector, s.q, 1) DESC LIMIT $3)
+		)
+		SELECT p.post_id, p.post_url, u.user_id, u.username, u.display_name, t.topic_id, t.topic_name, t.topic_url,
+			tags.tag_name, p.title, p.created_date,
+			p.upvote_count - p.downvote_count AS sum_of_votes,
+			p.comment_count AS num_of_comments,
+			p.topic_id = s.topic_id AS is_same_topic,
+			`+nsfw.Columns+`,
+			`+score+` AS score
+		FROM candidates
+		INNER JOIN posts p ON p.post_id = candidates.post_id
+		CROSS JOIN s
+		LEFT JOIN tags ON tags.tag_id = p.tag_id
+		INNER JOIN users u ON u.user_id = p.author_id
+		INNER JOIN topics t ON t.topic_id = p.topic_id
+		WHERE p.post_id <> s.post_id AND p.post_id <> s.original_id AND p.crosspost_of_id IS DISTINCT FROM s.original_id
+		AND NOT EXISTS (SELECT 1 FROM posts_votes pv WHERE pv.post_id = p.post_id AND pv.user_id = $1)`+nsfw.Visible+`
+		ORDER BY score DESC, p.created_date DESC, p.post_id DESC`,
+		userID, postID, poolSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resultArr := []types.RelatedPost{}
+	for rows.Next() {
+		var result types.RelatedPost
+		var created time.Time
+		if err := rows.Scan(&result.Post_ID, &result.Post_URL, &result.User_ID, &result.Username, &result.DisplayName,
+			&result.Topic_ID, &result.Topic_Name, &result.Topic_URL, &result.Tag_Name, &result.Title, &created,
+			&result.Sum_Votes, &result.Comment_Count, &result.Is_Same_Topic,
+			&result.Is_NSFW, &result.Is_Spoiler, &result.Blur_NSFW, &result.Blur_Spoiler, &result.Score); err != nil {
+			return nil, err
+		}
+		result.Created_Date = created.Format(time.RFC3339)
+		resultArr = append(resultArr, result)
+	}
+	return resultArr, rows.Err()
+}
+
+// split scored posts into the best limit overall and the best limit of the rest from the same topic
+func Split(posts []types.RelatedPost, limit int) ([]types.RelatedPost, []types.RelatedPost) {
+	related := posts[:min(limit, len(posts))]
+	moreFromTopic := []types.RelatedPost{}
+	for _, post := range posts[len(related):] {
+		if len(moreFromTopic) == limit {
+			break
+		}
+		if post.Is_Same_Topic {
+			moreFromTopic = append(moreFromTopic, post)
+		}
+	}
+	return related, moreFromTopic
+}
@@ -0,0 +1,94 @@
+package related
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/minrui13/backend/database/dbtest"
+	"github.com/minrui13/backend/types"
+)
+
+func TestSplit(t *testing.T) {
+	same1 := types.RelatedPost{Post_ID: 1, Is_Same_Topic: true}
+	other2 := types.RelatedPost{Post_ID: 2}
+	same3 := types.RelatedPost{Post_ID: 3, Is_Same_Topic: true}
+	other4 := types.RelatedPost{Post_ID: 4}
+	same5 := types.RelatedPost{Post_ID: 5, Is_Same_Topic: true}
+	same6 := types.RelatedPost{Post_ID: 6, Is_Same_Topic: true}
+
+	//more from the topic starts after the related posts and stops at the limit
+	related, moreFromTopic := Split([]types.RelatedPost{same1, other2, same3, other4, same5, same6}, 2)
+	if !slices.Equal(related, []types.RelatedPost{same1, other2}) {
+		t.Errorf("related = %v, want posts 1 and 2", related)
+	}
+	if !slices.Equal(moreFromTopic, []types.RelatedPost{same3, same5}) {
+		t.Errorf("more from topic = %v, want posts 3 and 5", moreFromTopic)
+	}
+
+	related, moreFromTopic = Split([]types.RelatedPost{same1}, 5)
+	if !slices.Equal(related, []types.RelatedPost{same1}) || len(moreFromTopic) != 0 {
+		t.Errorf("Split of one post = %v and %v, want it related and nothing more", related, moreFromTopic)
+	}
+
+	related, moreFromTopic = Split(nil, 5)
+	if len(related) != 0 || moreFromTopic == nil || len(moreFromTopic) != 0 {
+		t.Errorf("Split of no posts = %v and %v, want both empty", related, moreFromTopic)
+	}
+}
+
+func TestPostsLeavesOutPrivateTopics(t *testing.T) {
+	tx := dbtest.Tx(t)
+	ctx := context.Background()
+
+	var hidden string
+	err := tx.QueryRow(ctx, `SELECT visibility FROM topics WHERE visibility <> 'public' LIMIT 1`).Scan(&hidden)
+	if errors.Is(err, pgx.ErrNoRows) {
+		t.Skip("the test database has no topics that are not public")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the published post with the most related posts for a viewer who follows nothing
+	const viewerID = -1
+	var postID int
+	var candidates []types.RelatedPost
+	rows, err := tx.Query(ctx, `SELECT post_id FROM posts WHERE status = 'published' AND deleted_date IS NULL ORDER BY post_id LIMIT 20`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	postIDs, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range postIDs {
+		posts, err := Posts(ctx, tx, id, viewerID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(posts) > len(candidates) {
+			postID, candidates = id, posts
+		}
+	}
+	if len(candidates) == 0 {
+		t.Skip("the test database has no related posts")
+	}
+
+	//hide the topic of the best related post from the viewer
+	privateTopic := candidates[0].Topic_ID
+	if _, err := tx.Exec(ctx, `UPDATE topics SET visibility = $1 WHERE topic_id = $2`, hidden, privateTopic); err != nil {
+		t.Fatal(err)
+	}
+	posts, err := Posts(ctx, tx, postID, viewerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, post := range posts {
+		if post.Topic_ID == privateTopic {
+			t.Errorf("post %d of a %s topic is related for a viewer not following it", post.Post_ID, hidden)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// Permanent removal of soft deleted content
+package retention
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minrui13/backend/config"
+	db "github.com/minrui13/backend/database"
+	"github.com/minrui13/backend/jobs"
+)
+
+// purge deleted posts once a day
+var PurgeJob = jobs.Job{
+	Name:     "deleted post purge",
+	Interval: 24 * time.Hour,
+	Run: func(ctx context.Context, pool *pgxpool.Pool) error {
+		_, err := PurgeDeletedPosts(ctx, pool, int(config.Envs.PostRetentionDays))
+		return err
+	},
+}
+
+// hard delete posts deleted more than retentionDays ago
+// comments, votes and bookmarks go with them through the foreign keys
+func PurgeDeletedPosts(ctx context.Context, q db.Querier, retentionDays int) (int64, error) {
+	tag, err := q.Exec(ctx,
+		`DELETE FROM posts WHERE deleted_date < CURRENT_TIMESTAMP - make_interval(days => $1)`,
+		retentionDays)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,63 @@
+package retention
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/minrui13/backend/database/dbtest"
+)
+
+func TestPurgeDeletedPosts(t *testing.T) {
+	tx := dbtest.Tx(t)
+	ctx := context.Background()
+
+	rows, err := tx.Query(ctx, `SELECT post_id FROM posts ORDER BY post_id LIMIT 3`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) < 3 {
+		t.Skip("the test database needs three posts")
+	}
+
+	//only posts deleted longer ago than the retention period go
+	oldID, recentID, liveID := ids[0], ids[1], ids[2]
+	for id, deleted := range map[int]string{
+		oldID:    `CURRENT_TIMESTAMP - INTERVAL '31 days'`,
+		recentID: `CURRENT_TIMESTAMP - INTERVAL '29 days'`,
+		liveID:   `NULL`,
+	} {
+		if _, err := tx.Exec(ctx, `UPDATE posts SET deleted_date = `+deleted+` WHERE post_id = $1`, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	purged, err := PurgeDeletedPosts(ctx, tx, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged < 1 {
+		t.Errorf("purged %d posts, want at least the one deleted 31 days ago", purged)
+	}
+
+	for _, c := range []struct {
+		postID int
+		kept   bool
+	}{
+		{oldID, false},
+		{recentID, true},
+		{liveID, true},
+	} {
+		var kept bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM posts WHERE post_id = $1)`, c.postID).Scan(&kept); err != nil {
+			t.Fatal(err)
+		}
+		if kept != c.kept {
+			t.Errorf("post %d kept = %v, want %v", c.postID, kept, c.kept)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package revisions
+
+import (
+	"strings"
+
+	"github.com/minrui13/backend/types"
+)
+
+// diff operations
+const (
+	OpEqual  = "equal"
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+// line based diff from the longest common subsequence
+// the common start and end are trimmed first so small edits to long posts stay cheap
+func LineDiff(oldText string, newText string) []types.DiffLine {
+	oldLines := strings.Split(oldText, "\n")
+	newLines := strings.Split(newText, "\n")
+
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+
+	diff := []types.DiffLine{}
+	for _, line := range oldLines[:prefix] {
+		diff = append(diff, types.DiffLine{Op: OpEqual, Text: line})
+	}
+
+	a := oldLines[prefix : len(oldLines)-suffix]
+	b := newLines[prefix : len(newLines)-suffix]
+
+	//lcs[i][j] is the common subsequence length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			diff = append(diff, types.DiffLine{Op: OpEqual, Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, types.DiffLine{Op: OpDelete, Text: a[i]})
+			i++
+		default:
+			diff = append(diff, types.DiffLine{Op: OpInsert, Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, types.DiffLine{Op: OpDelete, Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, types.DiffLine{Op: OpInsert, Text: b[j]})
+	}
+
+	for _, line := range oldLines[len(oldLines)-suffix:] {
+		diff = append(diff, types.DiffLine{Op: OpEqual, Text: line})
+	}
+	return diff
+}
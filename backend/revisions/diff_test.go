@@ -0,0 +1,96 @@
+package revisions
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/minrui13/backend/types"
+)
+
+func TestLineDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldText string
+		newText string
+		want    []types.DiffLine
+	}{
+		{
+			name:    "unchanged",
+			oldText: "a\nb",
+			newText: "a\nb",
+			want:    []types.DiffLine{{Op: OpEqual, Text: "a"}, {Op: OpEqual, Text: "b"}},
+		},
+		{
+			name:    "inserted line",
+			oldText: "a\nc",
+			newText: "a\nb\nc",
+			want:    []types.DiffLine{{Op: OpEqual, Text: "a"}, {Op: OpInsert, Text: "b"}, {Op: OpEqual, Text: "c"}},
+		},
+		{
+			name:    "deleted line",
+			oldText: "a\nb\nc",
+			newText: "a\nc",
+			want:    []types.DiffLine{{Op: OpEqual, Text: "a"}, {Op: OpDelete, Text: "b"}, {Op: OpEqual, Text: "c"}},
+		},
+		{
+			name:    "changed line is deleted before it is inserted",
+			oldText: "a\nb\nc",
+			newText: "a\nx\nc",
+			want: []types.DiffLine{
+				{Op: OpEqual, Text: "a"}, {Op: OpDelete, Text: "b"}, {Op: OpInsert, Text: "x"}, {Op: OpEqual, Text: "c"},
+			},
+		},
+		{
+			name:    "moved line",
+			oldText: "a\nb\nc",
+			newText: "b\nc\na",
+			want: []types.DiffLine{
+				{Op: OpDelete, Text: "a"}, {Op: OpEqual, Text: "b"}, {Op: OpEqual, Text: "c"}, {Op: OpInsert, Text: "a"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := LineDiff(tt.oldText, tt.newText)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("LineDiff(%q, %q) = %v, want %v", tt.oldText, tt.newText, got, tt.want)
+			}
+		})
+	}
+}
+
+// the equal and deleted lines are the old text, the equal and inserted lines the new one
+func TestLineDiffRebuildsBothTexts(t *testing.T) {
+	pairs := [][2]string{
+		{"", "a"},
+		{"a", ""},
+		{"one\ntwo\nthree\nfour", "zero\none\nthree\nfour\nfive"},
+		{"x\nx\ny\nx", "x\ny\nx\nx"},
+		{"same start\nold middle\nsame end", "same start\nnew\nmiddle\nsame end"},
+	}
+
+	for _, pair := range pairs {
+		var oldLines, newLines []string
+		for _, line := range LineDiff(pair[0], pair[1]) {
+			switch line.Op {
+			case OpEqual:
+				oldLines = append(oldLines, line.Text)
+				newLines = append(newLines, line.Text)
+			case OpDelete:
+				oldLines = append(oldLines, line.Text)
+			case OpInsert:
+				newLines = append(newLines, line.Text)
+			default:
+				t.Fatalf("unknown op %q", line.Op)
+			}
+		}
+		if got := strings.Join(oldLines, "\n"); got != pair[0] {
+			t.Errorf("old text of LineDiff(%q, %q) = %q", pair[0], pair[1], got)
+		}
+		if got := strings.Join(newLines, "\n"); got != pair[1] {
+			t.Errorf("new text of LineDiff(%q, %q) = %q", pair[0], pair[1], got)
+		}
+	}
+}
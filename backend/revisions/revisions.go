@@ -0,0 +1,65 @@
+// Post edit history
+package revisions
+
+import (
+	"context"
+	"time"
+
+	db "github.com/minrui13/backend/database"
+	"github.com/minrui13/backend/types"
+)
+
+// store the current post as the next revision if it changed since the latest one
+// called before an edit so posts from before revisions existed get their original saved,
+// and after the edit to save the new version
+func Snapshot(ctx context.Context, q db.Querier, postID int) error {
+	_, err := q.Exec(ctx,
+		`INSERT INTO posts_revisions (post_id, revision_number, tag_id, title, content, content_html)
+		SELECT p.post_id, COALESCE(last.revision_number, 0) + 1, p.tag_id, p.title, p.content, p.content_html
+		FROM posts p
+		LEFT JOIN LATERAL (
+			SELECT revision_number, tag_id, title, content FROM posts_revisions
+			WHERE post_id = p.post_id
+			ORDER BY revision_number DESC
+			LIMIT 1
+		) last ON TRUE
+		WHERE p.post_id = $1
+		AND (last.revision_number IS NULL OR (last.tag_id, last.title, last.content) IS DISTINCT FROM (p.tag_id, p.title, p.content))`,
+		postID)
+	return err
+}
+
+// all revisions of a post, oldest first, each with the diff from the one before
+func List(ctx context.Context, q db.Querier, postID int) ([]types.PostRevision, error) {
+	rows, err := q.Query(ctx,
+		`SELECT revision_id, post_id, revision_number, tag_id, title, content, COALESCE(content_html, ''), created_date
+		FROM posts_revisions
+		WHERE post_id = $1
+		ORDER BY revision_number`,
+		postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisionArr := []types.PostRevision{}
+	for rows.Next() {
+		var revision types.PostRevision
+		var created time.Time
+		if err := rows.Scan(&revision.Revision_ID, &revision.Post_ID, &revision.Revision_Number, &revision.Tag_ID,
+			&revision.Title, &revision.Content, &revision.Content_HTML, &created); err != nil {
+			return nil, err
+		}
+		revision.Created_Date = created.Format(time.RFC3339)
+
+		revision.Title_Diff = []types.DiffLine{}
+		revision.Content_Diff = []types.DiffLine{}
+		if len(revisionArr) > 0 {
+			previous := revisionArr[len(revisionArr)-1]
+			revision.Title_Diff = LineDiff(previous.Title, revision.Title)
+			revision.Content_Diff = LineDiff(previous.Content, revision.Content)
+		}
+		revisionArr = append(revisionArr, revision)
+	}
+	return revisionArr, rows.Err()
+}
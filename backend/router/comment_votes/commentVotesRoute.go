@@ -10,6 +10,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minrui13/backend/moderation"
 	"github.com/minrui13/backend/types"
 	"github.com/minrui13/backend/util"
 )
@@ -72,6 +73,25 @@ func (h *Handler) AddCommentVote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	//archived or read-only topics, banned users and locked threads cannot get new votes
+	err = moderation.CheckCanContributeToComment(ctx, h.db, commentIDInt, userIDInt)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid comment id"))
+		return
+	}
+	if moderation.IsLocked(err) {
+		util.WriteError(w, http.StatusLocked, err)
+		return
+	}
+	if moderation.IsForbidden(err) {
+		util.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
 	var newCommentVoteID int
 	commentVotes := new(types.VoteCountIDResult)
 
@@ -93,12 +113,7 @@ func (h *Handler) AddCommentVote(w http.ResponseWriter, r *http.Request) {
 	}
 
 	err = h.db.QueryRow(ctx,
-		`SELECT
-    		COUNT(comment_vote_id) FILTER (WHERE vote_type = 1)  AS upvotes,
-    		COUNT(comment_vote_id) FILTER (WHERE vote_type = -1) AS downvotes
-		FROM comments_votes
-		WHERE comment_id = $1;
-		`,
+		`SELECT upvote_count, downvote_count FROM posts_comments WHERE comment_id = $1`,
 		commentIDInt).Scan(&commentVotes.Upvote_Count, &commentVotes.Downvote_Count)
 
 	if err != nil {
@@ -139,6 +154,25 @@ func (h *Handler) UpdateCommentVote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	//votes in archived or read-only topics and locked threads are frozen
+	err = moderation.CheckCanChangeCommentVote(ctx, h.db, commentVoteIDInt)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid vote id"))
+		return
+	}
+	if moderation.IsLocked(err) {
+		util.WriteError(w, http.StatusLocked, err)
+		return
+	}
+	if moderation.IsForbidden(err) {
+		util.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
 	var commentID int
 	commentVotes := new(types.VoteCountResult)
 	//get data from db
@@ -157,12 +191,7 @@ func (h *Handler) UpdateCommentVote(w http.ResponseWriter, r *http.Request) {
 	}
 
 	err = h.db.QueryRow(ctx,
-		`SELECT
-    		COUNT(comment_vote_id) FILTER (WHERE vote_type = 1)  AS upvotes,
-    		COUNT(comment_vote_id) FILTER (WHERE vote_type = -1) AS downvotes
-		FROM comments_votes
-		WHERE comment_id = $1;
-		`,
+		`SELECT upvote_count, downvote_count FROM posts_comments WHERE comment_id = $1`,
 		commentID).Scan(&commentVotes.Upvote_Count, &commentVotes.Downvote_Count)
 
 	if err != nil {
@@ -190,6 +219,25 @@ func (h *Handler) DeleteCommentVote(w http.ResponseWriter, r *http.Request) {
 		util.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
+	//votes in archived or read-only topics and locked threads are frozen
+	err = moderation.CheckCanChangeCommentVote(ctx, h.db, commentVoteIDInt)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid vote id"))
+		return
+	}
+	if moderation.IsLocked(err) {
+		util.WriteError(w, http.StatusLocked, err)
+		return
+	}
+	if moderation.IsForbidden(err) {
+		util.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
 	var commentID int
 	commentsVotes := new(types.VoteCountResult)
 	//get data from db
@@ -206,12 +254,7 @@ func (h *Handler) DeleteCommentVote(w http.ResponseWriter, r *http.Request) {
 	}
 
 	err = h.db.QueryRow(ctx,
-		`SELECT
-    		COUNT(comment_vote_id) FILTER (WHERE vote_type = 1)  AS upvotes,
-    		COUNT(comment_vote_id) FILTER (WHERE vote_type = -1) AS downvotes
-		FROM comments_votes
-		WHERE comment_id = $1;
-		`,
+		`SELECT upvote_count, downvote_count FROM posts_comments WHERE comment_id = $1`,
 		commentID).Scan(&commentsVotes.Upvote_Count, &commentsVotes.Downvote_Count)
 
 	if err != nil {
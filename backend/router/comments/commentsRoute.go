@@ -12,6 +12,8 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/minrui13/backend/cursor"
+	"github.com/minrui13/backend/markdown"
+	"github.com/minrui13/backend/moderation"
 	"github.com/minrui13/backend/types"
 	"github.com/minrui13/backend/util"
 )
@@ -116,31 +118,18 @@ func (h *Handler) GetMainCommentsByPostID(w http.ResponseWriter, r *http.Request
 		p.author_id,
 		pc.parent_comment_id,
 		pc.content,
+		COALESCE(pc.content_html, '') AS content_html,
 		pc.created_date,
 		cvv.comment_vote_id as vote_id,
-		COALESCE(cv.num_of_upvotes, 0) as num_of_upvotes,
-		COALESCE(cv.num_of_downvotes, 0) as num_of_downvotes,
-		COALESCE(cv.sum_of_votes, 0) as sum_of_votes,
+		pc.upvote_count AS num_of_upvotes,
+		pc.downvote_count AS num_of_downvotes,
+		pc.upvote_count - pc.downvote_count AS sum_of_votes,
 		COALESCE(cvv.vote_type, 0) AS vote_status,
-		COALESCE(cc.num_of_replies, 0) as num_of_replies
+		pc.reply_count AS num_of_replies,
+		` + moderation.ThreadLockedSQL("pc.comment_id") + ` AS is_locked
 		FROM posts_comments pc
 		INNER JOIN users u ON u.user_id = pc.user_id
 		INNER JOIN profile_image i ON i.image_id = u.image_id
-		LEFT JOIN (
-		SELECT comment_id,
-		COUNT(comment_id) FILTER (WHERE vote_type = 1) as num_of_upvotes,
-		COUNT(comment_id) FILTER (WHERE vote_type = -1) as num_of_downvotes,
-		SUM(vote_type) as sum_of_votes
-		FROM comments_votes
-		GROUP BY comment_id
-		) cv ON cv.comment_id = pc.comment_id
-		LEFT JOIN (
-		SELECT parent_comment_id, 
-		COUNT(comment_id) as num_of_replies
-		FROM posts_comments 
-		WHERE parent_comment_id IS NOT NULL
-		GROUP BY parent_comment_id
-		) cc ON cc.parent_comment_id = pc.comment_id
 		LEFT JOIN comments_votes cvv ON cvv.comment_id = pc.comment_id AND cvv.user_id = $1
 		INNER JOIN posts p ON p.post_id = pc.post_id
 		WHERE pc.post_id =  $2
@@ -154,9 +143,9 @@ func (h *Handler) GetMainCommentsByPostID(w http.ResponseWriter, r *http.Request
 		var orderStatement string
 		switch sortBy {
 		case "new":
-			orderStatement = `  ORDER BY pc.created_date DESC,  COALESCE(cv.sum_of_votes,0) DESC, COALESCE(cc.num_of_replies,0) DESC, pc.comment_id DESC  `
+			orderStatement = `  ORDER BY pc.created_date DESC,  (pc.upvote_count - pc.downvote_count) DESC, pc.reply_count DESC, pc.comment_id DESC  `
 		default:
-			orderStatement = ` ORDER BY sum_of_votes DESC, COALESCE(cc.num_of_replies,0) DESC, pc.created_date DESC,  pc.comment_id DESC `
+			orderStatement = ` ORDER BY sum_of_votes DESC, pc.reply_count DESC, pc.created_date DESC,  pc.comment_id DESC `
 		}
 		SQLStatement := baseSQLStatement + orderStatement + ` LIMIT $3`
 
@@ -168,11 +157,11 @@ func (h *Handler) GetMainCommentsByPostID(w http.ResponseWriter, r *http.Request
 			SQLStatement := baseSQLStatement + `
 				AND (
 					pc.created_date < $3
-					OR (pc.created_date = $3 AND COALESCE(cv.sum_of_votes,0) < $4)
-					OR (pc.created_date = $3 AND COALESCE(cv.sum_of_votes,0) = $4 AND COALESCE(cc.num_of_replies, 0) < $5)
-					OR (pc.created_date = $3 AND COALESCE(cv.sum_of_votes,0) = $4 AND COALESCE(cc.num_of_replies, 0) = $5 AND pc.comment_id <$6)
+					OR (pc.created_date = $3 AND (pc.upvote_count - pc.downvote_count) < $4)
+					OR (pc.created_date = $3 AND (pc.upvote_count - pc.downvote_count) = $4 AND pc.reply_count < $5)
+					OR (pc.created_date = $3 AND (pc.upvote_count - pc.downvote_count) = $4 AND pc.reply_count = $5 AND pc.comment_id <$6)
 				)
-				ORDER BY pc.created_date DESC, COALESCE(cv.sum_of_votes,0) DESC, COALESCE(cc.num_of_replies,0) DESC, pc.comment_id DESC
+				ORDER BY pc.created_date DESC, (pc.upvote_count - pc.downvote_count) DESC, pc.reply_count DESC, pc.comment_id DESC
 				LIMIT $7`
 
 			rows, err = h.db.Query(
@@ -189,12 +178,12 @@ func (h *Handler) GetMainCommentsByPostID(w http.ResponseWriter, r *http.Request
 		default:
 			SQLStatement := baseSQLStatement + `
 			AND (
-				COALESCE(cv.sum_of_votes,0) < $3
-				OR ( COALESCE(cv.sum_of_votes,0) = $3 AND COALESCE(cc.num_of_replies,0) < $4)
-				OR ( COALESCE(cv.sum_of_votes,0) = $3 AND COALESCE(cc.num_of_replies,0) = $4 AND pc.created_date < $5)
-				OR ( COALESCE(cv.sum_of_votes,0) = $3 AND COALESCE(cc.num_of_replies,0) = $4 AND pc.created_date = $5 AND pc.comment_id < $6)
+				(pc.upvote_count - pc.downvote_count) < $3
+				OR ( (pc.upvote_count - pc.downvote_count) = $3 AND pc.reply_count < $4)
+				OR ( (pc.upvote_count - pc.downvote_count) = $3 AND pc.reply_count = $4 AND pc.created_date < $5)
+				OR ( (pc.upvote_count - pc.downvote_count) = $3 AND pc.reply_count = $4 AND pc.created_date = $5 AND pc.comment_id < $6)
 			)
-			ORDER BY COALESCE(cv.sum_of_votes,0) DESC, COALESCE(cc.num_of_replies,0) DESC, pc.created_date DESC, pc.comment_id DESC
+			ORDER BY (pc.upvote_count - pc.downvote_count) DESC, pc.reply_count DESC, pc.created_date DESC, pc.comment_id DESC
 			LIMIT $7`
 			rows, err = h.db.Query(
 				ctx,
@@ -223,8 +212,8 @@ func (h *Handler) GetMainCommentsByPostID(w http.ResponseWriter, r *http.Request
 		var created time.Time
 
 		if err := rows.Scan(&comment.Comment_ID, &comment.User_ID, &comment.Username, &comment.DisplayName, &comment.Image_Name, &comment.Post_ID,
-			&comment.Post_User_ID, &comment.Parent_Comment_ID, &comment.Content, &created, &comment.Vote_ID, &comment.Upvote_Count, &comment.Downvote_Count,
-			&comment.Sum_Votes, &comment.Vote_Status, &comment.Reply_Count); err != nil {
+			&comment.Post_User_ID, &comment.Parent_Comment_ID, &comment.Content, &comment.Content_HTML, &created, &comment.Vote_ID, &comment.Upvote_Count, &comment.Downvote_Count,
+			&comment.Sum_Votes, &comment.Vote_Status, &comment.Reply_Count, &comment.Is_Locked); err != nil {
 			util.WriteError(w, http.StatusInternalServerError, err)
 			return
 		}
@@ -294,31 +283,18 @@ func (h *Handler) GetReplyByCommentId(w http.ResponseWriter, r *http.Request) {
 			p.author_id,
 			pc.parent_comment_id,
 			pc.content,
+			COALESCE(pc.content_html, '') AS content_html,
 			pc.created_date,
 			cvv.comment_vote_id as vote_id,
-			COALESCE(cv.num_of_upvotes, 0) as num_of_upvotes,
-			COALESCE(cv.num_of_downvotes, 0) as num_of_downvotes,
-			COALESCE(cv.sum_of_votes, 0) as sum_of_votes,
+			pc.upvote_count AS num_of_upvotes,
+			pc.downvote_count AS num_of_downvotes,
+			pc.upvote_count - pc.downvote_count AS sum_of_votes,
 			COALESCE(cvv.vote_type, 0) AS vote_status,
-			COALESCE(cc.num_of_replies, 0) as num_of_replies
+			pc.reply_count AS num_of_replies,
+			`+moderation.ThreadLockedSQL("pc.comment_id")+` AS is_locked
 			FROM posts_comments pc
 			INNER JOIN users u ON u.user_id = pc.user_id
 			INNER JOIN profile_image i ON i.image_id = u.image_id
-			LEFT JOIN (
-			SELECT comment_id,
-			COUNT(comment_id) FILTER (WHERE vote_type = 1) as num_of_upvotes,
-			COUNT(comment_id) FILTER (WHERE vote_type = -1) as num_of_downvotes,
-			SUM(vote_type) as sum_of_votes
-			FROM comments_votes
-			GROUP BY comment_id
-			) cv ON cv.comment_id = pc.comment_id
-			LEFT JOIN (
-			SELECT parent_comment_id, 
-			COUNT(comment_id) as num_of_replies
-			FROM posts_comments 
-			WHERE parent_comment_id IS NOT NULL
-			GROUP BY parent_comment_id
-			) cc ON cc.parent_comment_id = pc.comment_id
 			LEFT JOIN comments_votes cvv ON cvv.comment_id = pc.comment_id AND cvv.user_id = $1
 			WHERE pc.parent_comment_id = $2 
 			INNER JOIN posts p ON p.post_id = pc.post_id
@@ -335,31 +311,18 @@ func (h *Handler) GetReplyByCommentId(w http.ResponseWriter, r *http.Request) {
 			p.author_id,
 			pc.parent_comment_id,
 			pc.content,
+			COALESCE(pc.content_html, '') AS content_html,
 			pc.created_date,
 			cvv.comment_vote_id as vote_id,
-			COALESCE(cv.num_of_upvotes, 0) as num_of_upvotes,
-			COALESCE(cv.num_of_downvotes, 0) as num_of_downvotes,
-			COALESCE(cv.sum_of_votes, 0) as sum_of_votes,
+			pc.upvote_count AS num_of_upvotes,
+			pc.downvote_count AS num_of_downvotes,
+			pc.upvote_count - pc.downvote_count AS sum_of_votes,
 			COALESCE(cvv.vote_type, 0) AS vote_status,
-			COALESCE(cc.num_of_replies, 0) as num_of_replies
+			pc.reply_count AS num_of_replies,
+			`+moderation.ThreadLockedSQL("pc.comment_id")+` AS is_locked
 			FROM posts_comments pc
 			INNER JOIN users u ON u.user_id = pc.user_id
 			INNER JOIN profile_image i ON i.image_id = u.image_id
-			LEFT JOIN (
-			SELECT comment_id,
-			COUNT(comment_id) FILTER (WHERE vote_type = 1) as num_of_upvotes,
-			COUNT(comment_id) FILTER (WHERE vote_type = -1) as num_of_downvotes,
-			SUM(vote_type) as sum_of_votes
-			FROM comments_votes
-			GROUP BY comment_id
-			) cv ON cv.comment_id = pc.comment_id
-			LEFT JOIN (
-			SELECT parent_comment_id, 
-			COUNT(comment_id) as num_of_replies
-			FROM posts_comments 
-			WHERE parent_comment_id IS NOT NULL
-			GROUP BY parent_comment_id
-			) cc ON cc.parent_comment_id = pc.comment_id
 			LEFT JOIN comments_votes cvv ON cvv.comment_id = pc.comment_id AND cvv.user_id = $1
 			INNER JOIN all_replies ar ON pc.parent_comment_id = ar.comment_id
 			INNER JOIN posts p ON p.post_id = pc.post_id
@@ -383,8 +346,8 @@ func (h *Handler) GetReplyByCommentId(w http.ResponseWriter, r *http.Request) {
 		var created time.Time
 
 		if err := rows.Scan(&comment.Comment_ID, &comment.User_ID, &comment.Username, &comment.DisplayName, &comment.Image_Name, &comment.Post_ID, &comment.Post_User_ID,
-			&comment.Parent_Comment_ID, &comment.Content, &created, &comment.Vote_ID, &comment.Upvote_Count, &comment.Downvote_Count,
-			&comment.Sum_Votes, &comment.Vote_Status, &comment.Reply_Count); err != nil {
+			&comment.Parent_Comment_ID, &comment.Content, &comment.Content_HTML, &created, &comment.Vote_ID, &comment.Upvote_Count, &comment.Downvote_Count,
+			&comment.Sum_Votes, &comment.Vote_Status, &comment.Reply_Count, &comment.Is_Locked); err != nil {
 			util.WriteError(w, http.StatusInternalServerError, err)
 			return
 		}
@@ -414,37 +377,24 @@ func (h *Handler) GetCommentByCommentId(ctx context.Context, payload *types.Comm
 			p.author_id,
 			pc.parent_comment_id,
 			pc.content,
+			COALESCE(pc.content_html, '') AS content_html,
 			pc.created_date,
 			cvv.comment_vote_id as vote_id,
-			COALESCE(cv.num_of_upvotes, 0) as num_of_upvotes,
-			COALESCE(cv.num_of_downvotes, 0) as num_of_downvotes,
-			COALESCE(cv.sum_of_votes, 0) as sum_of_votes,
+			pc.upvote_count AS num_of_upvotes,
+			pc.downvote_count AS num_of_downvotes,
+			pc.upvote_count - pc.downvote_count AS sum_of_votes,
 			COALESCE(cvv.vote_type, 0) AS vote_status,
-			COALESCE(cc.num_of_replies, 0) as num_of_replies
+			pc.reply_count AS num_of_replies,
+			`+moderation.ThreadLockedSQL("pc.comment_id")+` AS is_locked
 			FROM posts_comments pc
 			INNER JOIN users u ON u.user_id = pc.user_id
 			INNER JOIN profile_image i ON i.image_id = u.image_id
-			LEFT JOIN (
-			SELECT comment_id,
-			COUNT(comment_id) FILTER (WHERE vote_type = 1) as num_of_upvotes,
-			COUNT(comment_id) FILTER (WHERE vote_type = -1) as num_of_downvotes,
-			SUM(vote_type) as sum_of_votes
-			FROM comments_votes
-			GROUP BY comment_id
-			) cv ON cv.comment_id = pc.comment_id
-			LEFT JOIN (
-			SELECT parent_comment_id, 
-			COUNT(comment_id) as num_of_replies
-			FROM posts_comments 
-			WHERE parent_comment_id IS NOT NULL
-			GROUP BY parent_comment_id
-			) cc ON cc.parent_comment_id = pc.comment_id
 			LEFT JOIN comments_votes cvv ON cvv.comment_id = pc.comment_id AND cvv.user_id = $1
 			INNER JOIN posts p ON p.post_id = pc.post_id
 			WHERE pc.comment_id = $2 
 			`, payload.User_ID, payload.Comment_ID).Scan(&comment.Comment_ID, &comment.User_ID, &comment.Username, &comment.DisplayName, &comment.Image_Name, &comment.Post_ID, &comment.Post_User_ID,
-		&comment.Parent_Comment_ID, &comment.Content, &created, &comment.Vote_ID, &comment.Upvote_Count, &comment.Downvote_Count,
-		&comment.Sum_Votes, &comment.Vote_Status, &comment.Reply_Count)
+		&comment.Parent_Comment_ID, &comment.Content, &comment.Content_HTML, &created, &comment.Vote_ID, &comment.Upvote_Count, &comment.Downvote_Count,
+		&comment.Sum_Votes, &comment.Vote_Status, &comment.Reply_Count, &comment.Is_Locked)
 
 	comment.Created_Date = created.Format(time.RFC3339)
 	if err != nil {
@@ -507,13 +457,40 @@ func (h *Handler) AddComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	//archived or read-only topics, banned users and locked posts cannot get new comments
+	err = moderation.CheckCanContribute(ctx, h.db, postIDInt, userIDInt)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid post id"))
+		return
+	}
+	//nor can locked threads get new replies
+	if err == nil && parentCommentIDInt != nil {
+		err = moderation.CheckCanContributeToComment(ctx, h.db, *parentCommentIDInt, userIDInt)
+		if moderation.IsNotFound(err) {
+			util.WriteError(w, http.StatusNotFound, errors.New("invalid parent comment id"))
+			return
+		}
+	}
+	if moderation.IsLocked(err) {
+		util.WriteError(w, http.StatusLocked, err)
+		return
+	}
+	if moderation.IsForbidden(err) {
+		util.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
 	var newCommentID int
 	//get data from db
 	err = h.db.QueryRow(ctx,
 		`INSERT INTO posts_comments
-		(user_id, post_id, parent_comment_id, content)
-		VALUES ($1, $2, $3, $4) RETURNING comment_id`,
-		userIDInt, postIDInt, parentCommentIDInt, payload.Content).Scan(&newCommentID)
+		(user_id, post_id, parent_comment_id, content, content_html, content_html_version)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING comment_id`,
+		userIDInt, postIDInt, parentCommentIDInt, payload.Content, markdown.Render(payload.Content), markdown.Version).Scan(&newCommentID)
 
 	if err != nil {
 		util.WriteError(w, http.StatusInternalServerError, err)
@@ -567,10 +544,10 @@ func (h *Handler) UpdateComment(w http.ResponseWriter, r *http.Request) {
 	//get data from db
 	err = h.db.QueryRow(ctx,
 		`UPDATE posts_comments
-			SET content = $1
+			SET content = $1, content_html = $3, content_html_version = $4
 			WHERE comment_id = $2
 			RETURNING user_id`,
-		payload.Content, commentIDInt).Scan(&userID)
+		payload.Content, commentIDInt, markdown.Render(payload.Content), markdown.Version).Scan(&userID)
 
 	if err != nil {
 		util.WriteError(w, http.StatusInternalServerError, err)
@@ -9,6 +9,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minrui13/backend/moderation"
 	"github.com/minrui13/backend/types"
 	"github.com/minrui13/backend/util"
 )
@@ -70,6 +71,25 @@ func (h *Handler) AddPostVote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	//archived or read-only topics, banned users and locked posts cannot get new votes
+	err = moderation.CheckCanContribute(ctx, h.db, postIDInt, userIDInt)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid post id"))
+		return
+	}
+	if moderation.IsLocked(err) {
+		util.WriteError(w, http.StatusLocked, err)
+		return
+	}
+	if moderation.IsForbidden(err) {
+		util.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
 	var newPostVoteID int
 	postsVotes := new(types.VoteCountIDResult)
 
@@ -91,12 +111,7 @@ func (h *Handler) AddPostVote(w http.ResponseWriter, r *http.Request) {
 	}
 
 	err = h.db.QueryRow(ctx,
-		`SELECT
-    		COUNT(post_vote_id) FILTER (WHERE vote_type = 1)  AS upvotes,
-    		COUNT(post_vote_id) FILTER (WHERE vote_type = -1) AS downvotes
-		FROM posts_votes
-		WHERE post_id = $1;
-		`,
+		`SELECT upvote_count, downvote_count FROM posts WHERE post_id = $1`,
 		postIDInt).Scan(&postsVotes.Upvote_Count, &postsVotes.Downvote_Count)
 
 	if err != nil {
@@ -137,6 +152,25 @@ func (h *Handler) UpdatePostVote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	//votes in archived or read-only topics and locked threads are frozen
+	err = moderation.CheckCanChangePostVote(ctx, h.db, postVoteIDInt)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid vote id"))
+		return
+	}
+	if moderation.IsLocked(err) {
+		util.WriteError(w, http.StatusLocked, err)
+		return
+	}
+	if moderation.IsForbidden(err) {
+		util.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
 	var postID int
 	postsVotes := new(types.VoteCountResult)
 	//get data from db
@@ -155,12 +189,7 @@ func (h *Handler) UpdatePostVote(w http.ResponseWriter, r *http.Request) {
 	}
 
 	err = h.db.QueryRow(ctx,
-		`SELECT
-    		COUNT(post_vote_id) FILTER (WHERE vote_type = 1)  AS upvotes,
-    		COUNT(post_vote_id) FILTER (WHERE vote_type = -1) AS downvotes
-		FROM posts_votes
-		WHERE post_id = $1;
-		`,
+		`SELECT upvote_count, downvote_count FROM posts WHERE post_id = $1`,
 		postID).Scan(&postsVotes.Upvote_Count, &postsVotes.Downvote_Count)
 
 	if err != nil {
@@ -184,6 +213,25 @@ func (h *Handler) DeletePostVote(w http.ResponseWriter, r *http.Request) {
 		util.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
+	//votes in archived or read-only topics and locked threads are frozen
+	err = moderation.CheckCanChangePostVote(ctx, h.db, postVoteIDInt)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid vote id"))
+		return
+	}
+	if moderation.IsLocked(err) {
+		util.WriteError(w, http.StatusLocked, err)
+		return
+	}
+	if moderation.IsForbidden(err) {
+		util.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
 	var postID int
 	postsVotes := new(types.VoteCountResult)
 	//get data from db
@@ -200,12 +248,7 @@ func (h *Handler) DeletePostVote(w http.ResponseWriter, r *http.Request) {
 	}
 
 	err = h.db.QueryRow(ctx,
-		`SELECT
-    		COUNT(post_vote_id) FILTER (WHERE vote_type = 1)  AS upvotes,
-    		COUNT(post_vote_id) FILTER (WHERE vote_type = -1) AS downvotes
-		FROM posts_votes
-		WHERE post_id = $1;
-		`,
+		`SELECT upvote_count, downvote_count FROM posts WHERE post_id = $1`,
 		postID).Scan(&postsVotes.Upvote_Count, &postsVotes.Downvote_Count)
 
 	if err != nil {
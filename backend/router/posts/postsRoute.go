@@ -1,9 +1,11 @@
 package postsRouter
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -12,18 +14,40 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minrui13/backend/config"
+	"github.com/minrui13/backend/crossposts"
 	"github.com/minrui13/backend/cursor"
+	"github.com/minrui13/backend/feed"
+	"github.com/minrui13/backend/links"
+	"github.com/minrui13/backend/markdown"
+	"github.com/minrui13/backend/moderation"
+	"github.com/minrui13/backend/nsfw"
+	"github.com/minrui13/backend/pins"
+	"github.com/minrui13/backend/polls"
+	"github.com/minrui13/backend/publishing"
+	"github.com/minrui13/backend/ranking"
+	"github.com/minrui13/backend/related"
+	"github.com/minrui13/backend/revisions"
+	"github.com/minrui13/backend/search"
+	"github.com/minrui13/backend/slug"
 	"github.com/minrui13/backend/types"
+	"github.com/minrui13/backend/urlhistory"
 	"github.com/minrui13/backend/util"
+	"github.com/minrui13/backend/views"
 )
 
 type Handler struct {
-	db *pgxpool.Pool
+	db     *pgxpool.Pool
+	search search.Engine
+	views  *views.Recorder
+	feed   *feed.Pipeline
+	links  links.Fetcher
 }
 
-func NewHandler(db *pgxpool.Pool) *Handler {
-	return &Handler{db: db}
+func NewHandler(db *pgxpool.Pool, searchEngine search.Engine, viewRecorder *views.Recorder, homeFeed *feed.Pipeline, linkClient links.Fetcher) *Handler {
+	return &Handler{db: db, search: searchEngine, views: viewRecorder, feed: homeFeed, links: linkClient}
 }
 
 func (h *Handler) Router(r *mux.Router) *mux.Router {
@@ -32,7 +56,7 @@ func (h *Handler) Router(r *mux.Router) *mux.Router {
 	//Get all posts by topic_id
 	r.HandleFunc("/allPostsByTopic/{topic_id}/{user_id}", h.GetAllPostsByTopicID).Methods("POST")
 	//Get post by id
-	r.HandleFunc("/getPostByID/{user_id}", h.GetPostById).Methods("POST")
+	r.HandleFunc("/getPostByID/{post_id}/{user_id}", h.GetPostById).Methods("POST")
 	//Get post by url
 	r.HandleFunc("/getPostByURL/{user_id}/{post_url}", h.GetPostByURL).Methods("POST")
 	//Get most popular posts
@@ -45,6 +69,22 @@ func (h *Handler) Router(r *mux.Router) *mux.Router {
 	r.HandleFunc("/updatePost/{post_id}", h.UpdatePost).Methods("PUT")
 	//Delete posts
 	r.HandleFunc("/deletePost/{post_id}", h.DeletePost).Methods("DELETE")
+	//Get own drafts and scheduled posts
+	r.HandleFunc("/myDrafts/{user_id}", h.GetMyDrafts).Methods("GET")
+	//Publish, schedule or move a post back to drafts
+	r.HandleFunc("/publishPost/{post_id}/{user_id}", h.PublishPost).Methods("PUT")
+	//Get edit history of a post
+	r.HandleFunc("/postRevisions/{post_id}/{user_id}", h.GetPostRevisions).Methods("GET")
+	//Full text search over posts and comments
+	r.HandleFunc("/search", h.SearchPosts).Methods("GET")
+	//Vote in a poll
+	r.HandleFunc("/votePoll/{post_id}/{user_id}", h.VotePoll).Methods("POST")
+	//Make a post a site-wide announcement, admins only
+	r.HandleFunc("/announcePost/{post_id}/{user_id}", h.AnnouncePost).Methods("PUT")
+	//Get posts related to a post and more posts from its topic
+	r.HandleFunc("/relatedPosts/{post_id}/{user_id}", h.GetRelatedPosts).Methods("POST")
+	//Crosspost a post into another topic
+	r.HandleFunc("/crosspost/{post_id}/{topic_id}/{user_id}", h.Crosspost).Methods("POST")
 
 	return r
 }
@@ -87,13 +127,18 @@ func (h *Handler) GetAllPosts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	//optional created date range, kept by every page
+	dateRange, err := ranking.ParseDateRange(query)
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
 	//check cursor
 	var decodedCursor any
-	if cursorParam != "" {
-		//buzzing - post =  then sum_of_votes then created date first
-		//sum of votes over num of upvotes because a post can have a lot of upvotes
-		//but the downvotes might contradict whether it is actally the best
+	if cursorParam != "" && !ranking.IsRanked(sortBy) {
 		//new -  post =   then created date first then sum_of_votes
+		//ranked sorts decode their cursor in ranking.Build
 		switch sortBy {
 		case "alpha":
 			decodedCursor, err = cursor.DecodeAlphaCursor(cursorParam)
@@ -111,6 +156,28 @@ func (h *Handler) GetAllPosts(w http.ResponseWriter, r *http.Request) {
 		rows pgx.Rows
 	)
 
+	//buzz, top, controversial, rising and views are ordered by a score
+	//new and alpha select a placeholder score
+	sortScore := "0::float8"
+	var rankQuery ranking.Query
+	if ranking.IsRanked(sortBy) {
+		//scores and windows are taken from the database time on the first page
+		var now time.Time
+		if cursorParam == "" {
+			now, err = ranking.Now(ctx, h.db)
+			if err != nil {
+				util.WriteError(w, http.StatusInternalServerError, err)
+				return
+			}
+		}
+		rankQuery, err = ranking.Build(sortBy, query.Get("window"), cursorParam, now, 5)
+		if err != nil {
+			util.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		sortScore = rankQuery.Score
+	}
+
 	//n this query, it retrieves all the information needed when dispalying the post
 	//in top of post information, get number of votes, comments and if user bookmarked the post
 	//since user_id starts from 1, if pass in user_id 0, bookmark instantly false
@@ -133,12 +200,29 @@ func (h *Handler) GetAllPosts(w http.ResponseWriter, r *http.Request) {
 		p.title, 
 		p.content, 
 		p.created_date,
+		` + moderation.FlairColumns + `,
+		p.post_type,
+		p.is_pinned,
+		p.announced_date IS NOT NULL AS is_announcement,
+		p.is_locked,
+		t.is_read_only AS is_topic_read_only,
+		` + nsfw.Columns + `,
+		` + polls.Column + `,
+		` + links.Column + `,
+		` + crossposts.Column + `,
+		to_char(p.edited_date, 'YYYY-MM-DD"T"HH24:MI:SS"Z"') AS edited_date,
+		p.edit_count,
+		p.deleted_by,
+		COALESCE(p.content_html, '') AS content_html,
+		` + sortScore + ` AS sort_score,
 		pvv.post_vote_id as vote_id,
-		COALESCE(pv.num_of_upvotes, 0) as num_of_upvotes,
-		COALESCE(pv.num_of_downvotes, 0) as num_of_downvotes,
-		COALESCE(pv.sum_of_votes, 0) AS sum_of_votes,
+		p.upvote_count AS num_of_upvotes,
+		p.downvote_count AS num_of_downvotes,
+		p.upvote_count - p.downvote_count AS sum_of_votes,
 		COALESCE(pvv.vote_type, 0) AS vote_status,
-		COALESCE(pc.num_of_comments, 0) AS num_of_comments,
+		p.comment_count AS num_of_comments,
+		p.view_count,
+		p.viewer_count,
 		pb.post_bookmark_id as bookmark_id, 
 		CASE WHEN pb.post_id IS NULL THEN FALSE ELSE TRUE END AS is_bookmarked
 		FROM posts p 
@@ -147,107 +231,66 @@ func (h *Handler) GetAllPosts(w http.ResponseWriter, r *http.Request) {
 		INNER JOIN profile_image i ON u.image_id = i.image_id
 		INNER JOIN topics t ON t.topic_id = p.topic_id
 		INNER JOIN categories c ON t.category_id = c.category_id
-		LEFT JOIN (
-			SELECT post_id, 
-			COUNT(post_vote_id) FILTER (WHERE vote_type = 1) as num_of_upvotes,
-			COUNT(post_vote_id) FILTER (WHERE vote_type = -1) as num_of_downvotes,
-			SUM(vote_type) as sum_of_votes 
-			FROM posts_votes
-			GROUP BY post_id
-    	) pv ON p.post_id = pv.post_id 
 		LEFT JOIN posts_votes pvv ON p.post_id = pvv.post_id AND pvv.user_id = $1
-		LEFT JOIN (
-			SELECT post_id,
-			COUNT(comment_id) as num_of_comments 
-			FROM posts_comments
-			GROUP BY post_id
-    	) pc ON pc.post_id = p.post_id
 		LEFT JOIN posts_bookmarks pb ON pb.post_id = p.post_id AND pb.user_id = $1
-		WHERE LOWER(p.title) LIKE $2 
+		WHERE LOWER(p.title) LIKE $2 AND p.status = 'published' AND p.deleted_date IS NULL` + pins.NotAnnouncement + nsfw.Visible + ranking.DateRangeWhere(3) + `
 		`
-	if cursorParam == "" {
+	if ranking.IsRanked(sortBy) {
+		SQLStatement := baseSQLStatement + rankQuery.Where + rankQuery.Order + fmt.Sprintf(` LIMIT $%d`, 5+len(rankQuery.Args))
+		args := append([]any{userID, search, dateRange.From_Date, dateRange.To_Date}, rankQuery.Args...)
+		rows, err = h.db.Query(ctx, SQLStatement, append(args, limitAddOne)...)
+	} else if cursorParam == "" {
 		var orderStatement string
 		switch sortBy {
 		case "new":
-			orderStatement = `  ORDER BY p.created_date DESC, COALESCE(pv.sum_of_votes,0) DESC, COALESCE(pc.num_of_comments, 0) DESC, p.post_id DESC `
+			orderStatement = `  ORDER BY p.created_date DESC, (p.upvote_count - p.downvote_count) DESC, p.comment_count DESC, p.post_id DESC `
 		case "alpha":
 			orderStatement = ` ORDER BY p.title ASC,  p.created_date DESC`
-		default:
-			orderStatement = ` ORDER BY COALESCE(pv.sum_of_votes,0) DESC, COALESCE(pc.num_of_comments, 0) DESC, p.created_date DESC, p.post_id DESC  `
 		}
-		SQLStatement := baseSQLStatement + `
-		GROUP BY p.post_id, p.post_url, u.user_id, u.username, u.display_name, i.image_name, t.topic_id, t.creator_id, t.topic_name, t.topic_url, c.icon_name, tags.tag_name, tag_icon, tag_description, p.title, p.content, p.created_date, pb.post_id, pvv.vote_type, vote_id, bookmark_id, pv.num_of_upvotes,
-		pv.num_of_downvotes, pc.num_of_comments, pv.sum_of_votes ` + orderStatement + ` LIMIT $3`
-		rows, err = h.db.Query(ctx, SQLStatement, userID, search, limitAddOne)
+		SQLStatement := baseSQLStatement + orderStatement + ` LIMIT $5`
+		rows, err = h.db.Query(ctx, SQLStatement, userID, search, dateRange.From_Date, dateRange.To_Date, limitAddOne)
 	} else {
 		switch d := decodedCursor.(type) {
 		case *types.SumVotesDateCursor:
-			var SQLStatement string
 			d = decodedCursor.(*types.SumVotesDateCursor)
-			switch sortBy {
-			case "new":
-				SQLStatement = baseSQLStatement + `
-				AND (
-					p.created_date < $3
-					OR (p.created_date = $3 AND COALESCE(pv.sum_of_votes,0) < $4)
-					OR (p.created_date = $3 AND COALESCE(pv.sum_of_votes,0) = $4 AND COALESCE(pc.num_of_comments, 0) < $5)
-					OR (p.created_date = $3 AND COALESCE(pv.sum_of_votes,0) = $4 AND COALESCE(pc.num_of_comments, 0) = $5 AND p.post_id < $6)
-				)
-				GROUP BY p.post_id, p.post_url, u.user_id, u.username, u.display_name, i.image_name, t.topic_id, t.creator_id, t.topic_name, t.topic_url, c.icon_name, tags.tag_name, tag_icon, tag_description, p.title, p.content, p.created_date, pb.post_id, pvv.vote_type, vote_id, bookmark_id, pv.num_of_upvotes,
-				pv.num_of_downvotes, pc.num_of_comments, pv.sum_of_votes
-				ORDER BY p.created_date DESC, COALESCE(pv.sum_of_votes,0) DESC, COALESCE(pc.num_of_comments, 0) DESC, p.post_id DESC LIMIT $7`
-				rows, err = h.db.Query(
-					ctx,
-					SQLStatement,
-					userID,
-					search,
-					d.Created_Date,
-					d.Sum_Votes_Count,
-					d.Comment_Count,
-					d.Post_ID,
-					limitAddOne,
-				)
-			default:
-				SQLStatement = baseSQLStatement + `
-				AND (
-					COALESCE(pv.sum_of_votes,0) < $3
-					OR (COALESCE(pv.sum_of_votes,0) = $3 AND COALESCE(pc.num_of_comments, 0) < $4)
-					OR (COALESCE(pv.sum_of_votes,0) = $3 AND COALESCE(pc.num_of_comments, 0) = $4 AND p.created_date < $5)
-					OR (COALESCE(pv.sum_of_votes,0) = $3 AND COALESCE(pc.num_of_comments, 0) = $4 AND p.created_date = $5 AND p.post_id < $6)
-				)
-				GROUP BY p.post_id, p.post_url, u.user_id, u.username, u.display_name, i.image_name,t.topic_id, t.creator_id, t.topic_name, t.topic_url, c.icon_name, tags.tag_name, tag_icon, tag_description, p.title, p.content, p.created_date, pb.post_id, pvv.vote_type, vote_id, bookmark_id, pv.num_of_upvotes,
-				pv.num_of_downvotes, pc.num_of_comments, pv.sum_of_votes
-				ORDER BY COALESCE(pv.sum_of_votes,0) DESC, COALESCE(pc.num_of_comments, 0) DESC, p.created_date DESC, p.post_id DESC
-				LIMIT $7
-			`
-				rows, err = h.db.Query(
-					ctx,
-					SQLStatement,
-					userID,
-					search,
-					d.Sum_Votes_Count,
-					d.Comment_Count,
-					d.Created_Date,
-					d.Post_ID,
-					limitAddOne,
-				)
-			}
+			//only new pages with this cursor
+			SQLStatement := baseSQLStatement + `
+			AND (
+				p.created_date < $5
+				OR (p.created_date = $5 AND (p.upvote_count - p.downvote_count) < $6)
+				OR (p.created_date = $5 AND (p.upvote_count - p.downvote_count) = $6 AND p.comment_count < $7)
+				OR (p.created_date = $5 AND (p.upvote_count - p.downvote_count) = $6 AND p.comment_count = $7 AND p.post_id < $8)
+			)
+			ORDER BY p.created_date DESC, (p.upvote_count - p.downvote_count) DESC, p.comment_count DESC, p.post_id DESC LIMIT $9`
+			rows, err = h.db.Query(
+				ctx,
+				SQLStatement,
+				userID,
+				search,
+				dateRange.From_Date,
+				dateRange.To_Date,
+				d.Created_Date,
+				d.Sum_Votes_Count,
+				d.Comment_Count,
+				d.Post_ID,
+				limitAddOne,
+			)
 		case *types.AlphaDateCursor:
 			d = decodedCursor.(*types.AlphaDateCursor)
 			SQLStatement := baseSQLStatement + `
 			AND (
-				p.title > $4
-				OR (p.title = $4 AND p.created_date < $3)
+				p.title > $6
+				OR (p.title = $6 AND p.created_date < $5)
 			)
-			GROUP BY p.post_id, p.post_url, u.user_id, u.username, u.display_name, i.image_name, t.topic_id, t.creator_id, t.topic_name, t.topic_url, c.icon_name, tags.tag_name, tag_icon, tag_description, p.title, p.content, p.created_date, pb.post_id, pvv.vote_type, vote_id, bookmark_id, pv.num_of_upvotes,
-			pv.num_of_downvotes, pc.num_of_comments, pv.sum_of_votes
 			ORDER BY p.title ASC, p.created_date DESC
-			LIMIT $5`
+			LIMIT $7`
 			rows, err = h.db.Query(
 				ctx,
 				SQLStatement,
 				userID,
 				search,
+				dateRange.From_Date,
+				dateRange.To_Date,
 				d.Created_Date,
 				d.Title,
 				limitAddOne,
@@ -264,18 +307,21 @@ func (h *Handler) GetAllPosts(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var postsArr []types.PostSumVotesResult
+	var scoreArr []float64
 	for rows.Next() {
 		var post types.PostSumVotesResult
 		var created time.Time
+		var sortScore float64
 
 		if err := rows.Scan(&post.Post_ID, &post.Post_URL, &post.User_ID, &post.Username, &post.DisplayName, &post.User_Image, &post.Topic_ID, &post.Topic_User_ID, &post.Topic_Name, &post.Topic_URL, &post.Category_Icon, &post.Tag_Name, &post.Tag_Icon, &post.Tag_Description,
-			&post.Title, &post.Content, &created, &post.Vote_ID, &post.Upvote_Count, &post.Downvote_Count, &post.Sum_Votes, &post.Vote_Status, &post.Comment_Count, &post.Bookmark_ID, &post.Is_Bookmarked); err != nil {
+			&post.Title, &post.Content, &created, &post.Post_Flair, &post.User_Flair, &post.Post_Type, &post.Is_Pinned, &post.Is_Announcement, &post.Is_Locked, &post.Is_Topic_Read_Only, &post.Is_NSFW, &post.Is_Spoiler, &post.Blur_NSFW, &post.Blur_Spoiler, &post.Poll, &post.Link, &post.Crosspost_Of_ID, &post.Crosspost_Of, &post.Crosspost_Count, &post.Edited_Date, &post.Edit_Count, &post.Deleted_By, &post.Content_HTML, &sortScore, &post.Vote_ID, &post.Upvote_Count, &post.Downvote_Count, &post.Sum_Votes, &post.Vote_Status, &post.Comment_Count, &post.View_Count, &post.Viewer_Count, &post.Bookmark_ID, &post.Is_Bookmarked); err != nil {
 			util.WriteError(w, http.StatusInternalServerError, err)
 			return
 		}
 
 		post.Created_Date = created.Format(time.RFC3339)
 		postsArr = append(postsArr, post)
+		scoreArr = append(scoreArr, sortScore)
 	}
 
 	var nextCursor *string
@@ -290,7 +336,7 @@ func (h *Handler) GetAllPosts(w http.ResponseWriter, r *http.Request) {
 			if err == nil {
 				nextCursor = &c
 			}
-		default:
+		case "new":
 			c, err := cursor.EncodeSumVotesDateCursor(types.SumVotesDateCursor{
 				Sum_Votes_Count: last.Sum_Votes,
 				Created_Date:    last.Created_Date,
@@ -300,16 +346,32 @@ func (h *Handler) GetAllPosts(w http.ResponseWriter, r *http.Request) {
 			if err == nil {
 				nextCursor = &c
 			}
+		default:
+			c, err := rankQuery.NextCursor(scoreArr[limitQuery-1], last.Sum_Votes, last.Comment_Count, last.Post_ID)
+			if err == nil {
+				nextCursor = &c
+			}
 		}
 		postsArr = postsArr[:limitQuery]
 	} else {
 		nextCursor = nil
 	}
 
-	util.WriteJSON(w, http.StatusOK, map[string]any{
+	//announcements are only on the first page, they are never part of result
+	response := map[string]any{
 		"result": postsArr,
 		"cursor": nextCursor,
-	})
+	}
+	if cursorParam == "" {
+		pinnedArr, err := h.getPinnedPosts(ctx, userID, search, ` AND p.announced_date IS NOT NULL`, `p.announced_date DESC, p.post_id DESC`)
+		if err != nil {
+			util.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		response["announcements"] = pinnedArr
+	}
+
+	util.WriteJSON(w, http.StatusOK, response)
 }
 
 // Get all posts by topicID
@@ -351,13 +413,18 @@ func (h *Handler) GetAllPostsByTopicID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	//optional created date range, kept by every page
+	dateRange, err := ranking.ParseDateRange(query)
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
 	//check cursor
 	var decodedCursor any
-	if cursorParam != "" {
-		//buzzing - post =  then sum_of_votes then created date first
-		//sum of votes over num of upvotes because a post can have a lot of upvotes
-		//but the downvotes might contradict whether it is actally the best
+	if cursorParam != "" && !ranking.IsRanked(sortBy) {
 		//new -  post =   then created date first then sum_of_votes
+		//ranked sorts decode their cursor in ranking.Build
 		switch sortBy {
 		case "alpha":
 			decodedCursor, err = cursor.DecodeAlphaCursor(cursorParam)
@@ -375,6 +442,28 @@ func (h *Handler) GetAllPostsByTopicID(w http.ResponseWriter, r *http.Request) {
 		rows pgx.Rows
 	)
 
+	//buzz, top, controversial, rising and views are ordered by a score
+	//new and alpha select a placeholder score
+	sortScore := "0::float8"
+	var rankQuery ranking.Query
+	if ranking.IsRanked(sortBy) {
+		//scores and windows are taken from the database time on the first page
+		var now time.Time
+		if cursorParam == "" {
+			now, err = ranking.Now(ctx, h.db)
+			if err != nil {
+				util.WriteError(w, http.StatusInternalServerError, err)
+				return
+			}
+		}
+		rankQuery, err = ranking.Build(sortBy, query.Get("window"), cursorParam, now, 6)
+		if err != nil {
+			util.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		sortScore = rankQuery.Score
+	}
+
 	//n this query, it retrieves all the information needed when dispalying the post
 	//in top of post information, get number of votes, comments and if user bookmarked the post
 	//since user_id starts from 1, if pass in user_id 0, bookmark instantly false
@@ -397,12 +486,29 @@ func (h *Handler) GetAllPostsByTopicID(w http.ResponseWriter, r *http.Request) {
 		p.title, 
 		p.content, 
 		p.created_date,
+		` + moderation.FlairColumns + `,
+		p.post_type,
+		p.is_pinned,
+		p.announced_date IS NOT NULL AS is_announcement,
+		p.is_locked,
+		t.is_read_only AS is_topic_read_only,
+		` + nsfw.Columns + `,
+		` + polls.Column + `,
+		` + links.Column + `,
+		` + crossposts.Column + `,
+		to_char(p.edited_date, 'YYYY-MM-DD"T"HH24:MI:SS"Z"') AS edited_date,
+		p.edit_count,
+		p.deleted_by,
+		COALESCE(p.content_html, '') AS content_html,
+		` + sortScore + ` AS sort_score,
 		pvv.post_vote_id as vote_id,
-		COALESCE(pv.num_of_upvotes, 0) as num_of_upvotes,
-		COALESCE(pv.num_of_downvotes, 0) as num_of_downvotes,
-		COALESCE(pv.sum_of_votes, 0) AS sum_of_votes,
+		p.upvote_count AS num_of_upvotes,
+		p.downvote_count AS num_of_downvotes,
+		p.upvote_count - p.downvote_count AS sum_of_votes,
 		COALESCE(pvv.vote_type, 0) AS vote_status,
-		COALESCE(pc.num_of_comments, 0) AS num_of_comments,
+		p.comment_count AS num_of_comments,
+		p.view_count,
+		p.viewer_count,
 		pb.post_bookmark_id as bookmark_id, 
 		CASE WHEN pb.post_id IS NULL THEN FALSE ELSE TRUE END AS is_bookmarked
 		FROM posts p 
@@ -411,110 +517,68 @@ func (h *Handler) GetAllPostsByTopicID(w http.ResponseWriter, r *http.Request) {
 		INNER JOIN profile_image i ON u.image_id = i.image_id
 		INNER JOIN topics t ON t.topic_id = p.topic_id
 		INNER JOIN categories c ON t.category_id = c.category_id
-		LEFT JOIN (
-			SELECT post_id, 
-			COUNT(post_vote_id) FILTER (WHERE vote_type = 1) as num_of_upvotes,
-			COUNT(post_vote_id) FILTER (WHERE vote_type = -1) as num_of_downvotes,
-			SUM(vote_type) as sum_of_votes 
-			FROM posts_votes
-			GROUP BY post_id
-    	) pv ON p.post_id = pv.post_id 
 		LEFT JOIN posts_votes pvv ON p.post_id = pvv.post_id AND pvv.user_id = $1
-		LEFT JOIN (
-			SELECT post_id,
-			COUNT(comment_id) as num_of_comments 
-			FROM posts_comments
-			GROUP BY post_id
-    	) pc ON pc.post_id = p.post_id
 		LEFT JOIN posts_bookmarks pb ON pb.post_id = p.post_id AND pb.user_id = $1
-		WHERE LOWER(p.title) LIKE $2 and t.topic_id = $3
+		WHERE LOWER(p.title) LIKE $2 and t.topic_id = $3 AND p.status = 'published' AND p.deleted_date IS NULL` + pins.NotPinned + nsfw.Visible + ranking.DateRangeWhere(4) + `
 		`
-	if cursorParam == "" {
+	if ranking.IsRanked(sortBy) {
+		SQLStatement := baseSQLStatement + rankQuery.Where + rankQuery.Order + fmt.Sprintf(` LIMIT $%d`, 6+len(rankQuery.Args))
+		args := append([]any{userID, search, topicIDInt, dateRange.From_Date, dateRange.To_Date}, rankQuery.Args...)
+		rows, err = h.db.Query(ctx, SQLStatement, append(args, limitAddOne)...)
+	} else if cursorParam == "" {
 		var orderStatement string
 		switch sortBy {
 		case "new":
-			orderStatement = `  ORDER BY p.created_date DESC, COALESCE(pv.sum_of_votes,0) DESC, COALESCE(pc.num_of_comments, 0) DESC, p.post_id DESC `
+			orderStatement = `  ORDER BY p.created_date DESC, (p.upvote_count - p.downvote_count) DESC, p.comment_count DESC, p.post_id DESC `
 		case "alpha":
 			orderStatement = ` ORDER BY p.title ASC,  p.created_date DESC`
-		default:
-			orderStatement = ` ORDER BY COALESCE(pv.sum_of_votes,0) DESC, COALESCE(pc.num_of_comments, 0) DESC, p.created_date DESC, p.post_id DESC  `
 		}
-		SQLStatement := baseSQLStatement + `
-		GROUP BY p.post_id, p.post_url, u.user_id, u.username, u.display_name, i.image_name, t.topic_id, t.creator_id, t.topic_name, t.topic_url, c.icon_name, tags.tag_name, tag_icon, tag_description, p.title, p.content, p.created_date, pb.post_id, pvv.vote_type, vote_id, bookmark_id, pv.num_of_upvotes,
-		pv.num_of_downvotes, pc.num_of_comments, pv.sum_of_votes ` + orderStatement + ` LIMIT $4`
-		rows, err = h.db.Query(ctx, SQLStatement, userID, search, topicIDInt, limitAddOne)
+		SQLStatement := baseSQLStatement + orderStatement + ` LIMIT $6`
+		rows, err = h.db.Query(ctx, SQLStatement, userID, search, topicIDInt, dateRange.From_Date, dateRange.To_Date, limitAddOne)
 	} else {
 		switch d := decodedCursor.(type) {
 		case *types.SumVotesDateCursor:
-			var SQLStatement string
 			d = decodedCursor.(*types.SumVotesDateCursor)
-			switch sortBy {
-			case "new":
-				SQLStatement = baseSQLStatement + `
-				AND (
-					p.created_date < $4
-					OR (p.created_date = $4 AND COALESCE(pv.sum_of_votes,0) < $5)
-					OR (p.created_date = $4 AND COALESCE(pv.sum_of_votes,0) = $5 AND COALESCE(pc.num_of_comments, 0) < $6)
-					OR (p.created_date = $4 AND COALESCE(pv.sum_of_votes,0) = $5 AND COALESCE(pc.num_of_comments, 0) = $6 AND p.post_id < $7)
-				)
-				GROUP BY p.post_id, p.post_url, u.user_id, u.username, u.display_name, i.image_name, t.topic_id, t.creator_id, t.topic_name, t.topic_url, c.icon_name, tags.tag_name, tag_icon, tag_description, p.title, p.content, p.created_date, pb.post_id, pvv.vote_type, vote_id, bookmark_id, pv.num_of_upvotes,
-				pv.num_of_downvotes, pc.num_of_comments, pv.sum_of_votes
-				ORDER BY p.created_date DESC, COALESCE(pv.sum_of_votes,0) DESC, COALESCE(pc.num_of_comments, 0) DESC, p.post_id DESC LIMIT $8`
-				rows, err = h.db.Query(
-					ctx,
-					SQLStatement,
-					userID,
-					search,
-					topicIDInt,
-					d.Created_Date,
-					d.Sum_Votes_Count,
-					d.Comment_Count,
-					d.Post_ID,
-					limitAddOne,
-				)
-			default:
-				SQLStatement = baseSQLStatement + `
-				AND (
-					COALESCE(pv.sum_of_votes,0) < $4
-					OR (COALESCE(pv.sum_of_votes,0) = $4 AND COALESCE(pc.num_of_comments, 0) < $5)
-					OR (COALESCE(pv.sum_of_votes,0) = $4 AND COALESCE(pc.num_of_comments, 0) = $5 AND p.created_date < $6)
-					OR (COALESCE(pv.sum_of_votes,0) = $4 AND COALESCE(pc.num_of_comments, 0) = $5 AND p.created_date = $6 AND p.post_id < $7)
-				)
-				GROUP BY p.post_id, p.post_url, u.user_id, u.username, u.display_name, i.image_name,t.topic_id, t.creator_id, t.topic_name, t.topic_url, c.icon_name, tags.tag_name, tag_icon, tag_description, p.title, p.content, p.created_date, pb.post_id, pvv.vote_type, vote_id, bookmark_id, pv.num_of_upvotes,
-				pv.num_of_downvotes, pc.num_of_comments, pv.sum_of_votes
-				ORDER BY COALESCE(pv.sum_of_votes,0) DESC, COALESCE(pc.num_of_comments, 0) DESC, p.created_date DESC, p.post_id DESC
-				LIMIT $8
-			`
-				rows, err = h.db.Query(
-					ctx,
-					SQLStatement,
-					userID,
-					search,
-					topicIDInt,
-					d.Sum_Votes_Count,
-					d.Comment_Count,
-					d.Created_Date,
-					d.Post_ID,
-					limitAddOne,
-				)
-			}
+			//only new pages with this cursor
+			SQLStatement := baseSQLStatement + `
+			AND (
+				p.created_date < $6
+				OR (p.created_date = $6 AND (p.upvote_count - p.downvote_count) < $7)
+				OR (p.created_date = $6 AND (p.upvote_count - p.downvote_count) = $7 AND p.comment_count < $8)
+				OR (p.created_date = $6 AND (p.upvote_count - p.downvote_count) = $7 AND p.comment_count = $8 AND p.post_id < $9)
+			)
+			ORDER BY p.created_date DESC, (p.upvote_count - p.downvote_count) DESC, p.comment_count DESC, p.post_id DESC LIMIT $10`
+			rows, err = h.db.Query(
+				ctx,
+				SQLStatement,
+				userID,
+				search,
+				topicIDInt,
+				dateRange.From_Date,
+				dateRange.To_Date,
+				d.Created_Date,
+				d.Sum_Votes_Count,
+				d.Comment_Count,
+				d.Post_ID,
+				limitAddOne,
+			)
 		case *types.AlphaDateCursor:
 			d = decodedCursor.(*types.AlphaDateCursor)
 			SQLStatement := baseSQLStatement + `
 			AND (
-				p.title > $4
-				OR (p.title = $4 AND p.created_date < $5)
+				p.title > $6
+				OR (p.title = $6 AND p.created_date < $7)
 			)
-			GROUP BY p.post_id, p.post_url, u.user_id, u.username, u.display_name, i.image_name, t.topic_id, t.creator_id, t.topic_name, t.topic_url, c.icon_name, tags.tag_name, tag_icon, tag_description, p.title, p.content, p.created_date, pb.post_id, pvv.vote_type, vote_id, bookmark_id, pv.num_of_upvotes,
-			pv.num_of_downvotes, pc.num_of_comments, pv.sum_of_votes
 			ORDER BY p.title ASC, p.created_date DESC
-			LIMIT $6`
+			LIMIT $8`
 			rows, err = h.db.Query(
 				ctx,
 				SQLStatement,
 				userID,
 				search,
 				topicIDInt,
+				dateRange.From_Date,
+				dateRange.To_Date,
 				d.Title,
 				d.Created_Date,
 				limitAddOne,
@@ -531,18 +595,21 @@ func (h *Handler) GetAllPostsByTopicID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var postsArr []types.PostSumVotesResult
+	var scoreArr []float64
 	for rows.Next() {
 		var post types.PostSumVotesResult
 		var created time.Time
+		var sortScore float64
 
 		if err := rows.Scan(&post.Post_ID, &post.Post_URL, &post.User_ID, &post.Username, &post.DisplayName, &post.User_Image, &post.Topic_ID, &post.Topic_User_ID, &post.Topic_Name, &post.Topic_URL, &post.Category_Icon, &post.Tag_Name, &post.Tag_Icon, &post.Tag_Description,
-			&post.Title, &post.Content, &created, &post.Vote_ID, &post.Upvote_Count, &post.Downvote_Count, &post.Sum_Votes, &post.Vote_Status, &post.Comment_Count, &post.Bookmark_ID, &post.Is_Bookmarked); err != nil {
+			&post.Title, &post.Content, &created, &post.Post_Flair, &post.User_Flair, &post.Post_Type, &post.Is_Pinned, &post.Is_Announcement, &post.Is_Locked, &post.Is_Topic_Read_Only, &post.Is_NSFW, &post.Is_Spoiler, &post.Blur_NSFW, &post.Blur_Spoiler, &post.Poll, &post.Link, &post.Crosspost_Of_ID, &post.Crosspost_Of, &post.Crosspost_Count, &post.Edited_Date, &post.Edit_Count, &post.Deleted_By, &post.Content_HTML, &sortScore, &post.Vote_ID, &post.Upvote_Count, &post.Downvote_Count, &post.Sum_Votes, &post.Vote_Status, &post.Comment_Count, &post.View_Count, &post.Viewer_Count, &post.Bookmark_ID, &post.Is_Bookmarked); err != nil {
 			util.WriteError(w, http.StatusInternalServerError, err)
 			return
 		}
 
 		post.Created_Date = created.Format(time.RFC3339)
 		postsArr = append(postsArr, post)
+		scoreArr = append(scoreArr, sortScore)
 	}
 
 	var nextCursor *string
@@ -557,7 +624,7 @@ func (h *Handler) GetAllPostsByTopicID(w http.ResponseWriter, r *http.Request) {
 			if err == nil {
 				nextCursor = &c
 			}
-		default:
+		case "new":
 			c, err := cursor.EncodeSumVotesDateCursor(types.SumVotesDateCursor{
 				Sum_Votes_Count: last.Sum_Votes,
 				Created_Date:    last.Created_Date,
@@ -567,16 +634,32 @@ func (h *Handler) GetAllPostsByTopicID(w http.ResponseWriter, r *http.Request) {
 			if err == nil {
 				nextCursor = &c
 			}
+		default:
+			c, err := rankQuery.NextCursor(scoreArr[limitQuery-1], last.Sum_Votes, last.Comment_Count, last.Post_ID)
+			if err == nil {
+				nextCursor = &c
+			}
 		}
 		postsArr = postsArr[:limitQuery]
 	} else {
 		nextCursor = nil
 	}
 
-	util.WriteJSON(w, http.StatusOK, map[string]any{
+	//pinned are only on the first page, they are never part of result
+	response := map[string]any{
 		"result": postsArr,
 		"cursor": nextCursor,
-	})
+	}
+	if cursorParam == "" {
+		pinnedArr, err := h.getPinnedPosts(ctx, userID, search, ` AND p.topic_id = $3 AND p.is_pinned`, `p.pinned_date DESC, p.post_id DESC`, topicIDInt)
+		if err != nil {
+			util.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		response["pinned"] = pinnedArr
+	}
+
+	util.WriteJSON(w, http.StatusOK, response)
 }
 
 // Get all posts by post id
@@ -620,14 +703,30 @@ func (h *Handler) GetPostById(w http.ResponseWriter, r *http.Request) {
 		tags.tag_name, 
 		tags.icon_name as tag_icon, 
 		tags.description as tag_description, 
-		p.title, 
-		p.content, 
+		CASE WHEN p.deleted_date IS NULL THEN p.title WHEN p.deleted_by = 'moderator' THEN '[removed]' ELSE '[deleted]' END AS title,
+		CASE WHEN p.deleted_date IS NULL THEN p.content ELSE '' END AS content,
 		p.created_date,
+		`+moderation.FlairColumns+`,
+		p.post_type,
+		p.is_pinned,
+		p.announced_date IS NOT NULL AS is_announcement,
+		p.is_locked,
+		t.is_read_only AS is_topic_read_only,
+		`+nsfw.Columns+`,
+		`+polls.Column+`,
+		`+links.Column+`,
+		`+crossposts.Column+`,
+		to_char(p.edited_date, 'YYYY-MM-DD"T"HH24:MI:SS"Z"') AS edited_date,
+		p.edit_count,
+		p.deleted_by,
+		CASE WHEN p.deleted_date IS NULL THEN COALESCE(p.content_html, '') ELSE '' END AS content_html,
 		pvv.post_vote_id as vote_id,
-		COALESCE(pv.num_of_upvotes, 0) as num_of_upvotes,
-		COALESCE(pv.num_of_downvotes, 0) as num_of_downvotes,
+		p.upvote_count AS num_of_upvotes,
+		p.downvote_count AS num_of_downvotes,
 		COALESCE(pvv.vote_type, 0) AS vote_status,
-		COALESCE(pc.num_of_comments, 0) AS num_of_comments,
+		p.comment_count AS num_of_comments,
+		p.view_count,
+		p.viewer_count,
 		pb.post_bookmark_id as bookmark_id, 
 		CASE WHEN pb.post_id IS NULL THEN FALSE ELSE TRUE END AS is_bookmarked
 		FROM posts p
@@ -636,41 +735,31 @@ func (h *Handler) GetPostById(w http.ResponseWriter, r *http.Request) {
 		INNER JOIN profile_image i ON u.image_id = i.image_id
 		INNER JOIN categories c ON t.category_id = c.category_id
 		LEFT JOIN tags ON tags.tag_id = p.tag_id
-		LEFT JOIN (
-		SELECT post_id, 
-		COUNT(post_vote_id) FILTER (WHERE vote_type = 1) as num_of_upvotes,
-		COUNT(post_vote_id) FILTER (WHERE vote_type = -1) as num_of_downvotes 
-		FROM posts_votes
-		GROUP BY post_id
-		) pv ON p.post_id = pv.post_id 
 		LEFT JOIN posts_votes pvv ON p.post_id = pvv.post_id AND pvv.user_id = $1
-		LEFT JOIN (
-		SELECT post_id,
-		COUNT(comment_id) as num_of_comments 
-		FROM posts_comments
-		GROUP BY post_id
-		) pc ON pc.post_id = p.post_id
 		LEFT JOIN posts_bookmarks pb ON pb.post_id = p.post_id AND pb.user_id = $1
-		WHERE p.post_id = $2
-		GROUP BY p.post_id, u.username, u.display_name, u.user_id, i.image_name,t.topic_id, t.creator_id, t.topic_name, t.topic_url, tags.tag_name, tag_icon, tag_description, p.title, p.content, p.created_date, pb.post_id, pvv.vote_type, vote_id, bookmark_id, pv.num_of_upvotes,
-    pv.num_of_downvotes, pc.num_of_comments
-		ORDER BY p.created_date DESC`,
+		WHERE p.post_id = $2 AND (p.status = 'published' OR p.author_id = $1)`,
 		userIDInt, postIDInt).
 		Scan(&post.Post_ID, &post.Post_URL, &post.User_ID, &post.Username, &post.DisplayName, &post.User_Image, &post.Topic_ID, &post.Topic_User_ID, &post.Topic_Name, &post.Topic_URL, &post.Category_Icon, &post.Tag_Name, &post.Tag_Icon, &post.Tag_Description,
-			&post.Title, &post.Content, &created, &post.Vote_ID, &post.Upvote_Count, &post.Downvote_Count, &post.Vote_Status, &post.Comment_Count, &post.Bookmark_ID, &post.Is_Bookmarked)
+			&post.Title, &post.Content, &created, &post.Post_Flair, &post.User_Flair, &post.Post_Type, &post.Is_Pinned, &post.Is_Announcement, &post.Is_Locked, &post.Is_Topic_Read_Only, &post.Is_NSFW, &post.Is_Spoiler, &post.Blur_NSFW, &post.Blur_Spoiler, &post.Poll, &post.Link, &post.Crosspost_Of_ID, &post.Crosspost_Of, &post.Crosspost_Count, &post.Edited_Date, &post.Edit_Count, &post.Deleted_By, &post.Content_HTML, &post.Vote_ID, &post.Upvote_Count, &post.Downvote_Count, &post.Vote_Status, &post.Comment_Count, &post.View_Count, &post.Viewer_Count, &post.Bookmark_ID, &post.Is_Bookmarked)
 
 	post.Created_Date = created.Format(time.RFC3339)
+	if errors.Is(err, pgx.ErrNoRows) {
+		util.WriteError(w, http.StatusNotFound, errors.New("post not found"))
+		return
+	}
 	if err != nil {
-		util.WriteError(w, http.StatusNotFound, err)
+		util.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	h.recordView(r, post, userIDInt)
+
 	util.WriteJSON(w, http.StatusOK, post)
 }
 
 func (h *Handler) GetPostByURL(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	post := new(types.PostDefaultResult)
+	post := new(types.PostDetailResult)
 	var created time.Time
 	//get user_id from params
 	userID := mux.Vars(r)["user_id"]
@@ -689,6 +778,18 @@ func (h *Handler) GetPostByURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	//old urls from before a rename resolve to the current one
+	postURL, err = urlhistory.ResolvePostURL(ctx, h.db, postURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid post url"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	post.Canonical_URL = urlhistory.PostPath(postURL)
+
 	//get data from db
 	err = h.db.QueryRow(ctx,
 		`SELECT 
@@ -706,14 +807,30 @@ func (h *Handler) GetPostByURL(w http.ResponseWriter, r *http.Request) {
 		tags.tag_name, 
 		tags.icon_name as tag_icon, 
 		tags.description as tag_description, 
-		p.title, 
-		p.content, 
+		CASE WHEN p.deleted_date IS NULL THEN p.title WHEN p.deleted_by = 'moderator' THEN '[removed]' ELSE '[deleted]' END AS title,
+		CASE WHEN p.deleted_date IS NULL THEN p.content ELSE '' END AS content,
 		p.created_date,
+		`+moderation.FlairColumns+`,
+		p.post_type,
+		p.is_pinned,
+		p.announced_date IS NOT NULL AS is_announcement,
+		p.is_locked,
+		t.is_read_only AS is_topic_read_only,
+		`+nsfw.Columns+`,
+		`+polls.Column+`,
+		`+links.Column+`,
+		`+crossposts.Column+`,
+		to_char(p.edited_date, 'YYYY-MM-DD"T"HH24:MI:SS"Z"') AS edited_date,
+		p.edit_count,
+		p.deleted_by,
+		CASE WHEN p.deleted_date IS NULL THEN COALESCE(p.content_html, '') ELSE '' END AS content_html,
 		pvv.post_vote_id as vote_id,
-		COALESCE(pv.num_of_upvotes, 0) as num_of_upvotes,
-		COALESCE(pv.num_of_downvotes, 0) as num_of_downvotes,
+		p.upvote_count AS num_of_upvotes,
+		p.downvote_count AS num_of_downvotes,
 		COALESCE(pvv.vote_type, 0) AS vote_status,
-		COALESCE(pc.num_of_comments, 0) AS num_of_comments,
+		p.comment_count AS num_of_comments,
+		p.view_count,
+		p.viewer_count,
 		pb.post_bookmark_id as bookmark_id, 
 		CASE WHEN pb.post_id IS NULL THEN FALSE ELSE TRUE END AS is_bookmarked
 		FROM posts p
@@ -722,28 +839,13 @@ func (h *Handler) GetPostByURL(w http.ResponseWriter, r *http.Request) {
 		INNER JOIN profile_image i ON u.image_id = i.image_id
 		INNER JOIN categories c ON t.category_id = c.category_id
 		LEFT JOIN tags ON tags.tag_id = p.tag_id
-		LEFT JOIN (
-		SELECT post_id, 
-		COUNT(post_vote_id) FILTER (WHERE vote_type = 1) as num_of_upvotes,
-		COUNT(post_vote_id) FILTER (WHERE vote_type = -1) as num_of_downvotes 
-		FROM posts_votes
-		GROUP BY post_id
-		) pv ON p.post_id = pv.post_id 
 		LEFT JOIN posts_votes pvv ON p.post_id = pvv.post_id AND pvv.user_id = $1
-		LEFT JOIN (
-		SELECT post_id,
-		COUNT(comment_id) as num_of_comments 
-		FROM posts_comments
-		GROUP BY post_id
-		) pc ON pc.post_id = p.post_id
 		LEFT JOIN posts_bookmarks pb ON pb.post_id = p.post_id AND pb.user_id = $1
-		WHERE p.post_url = $2
-		GROUP BY p.post_id, u.username, u.display_name, u.user_id, i.image_name, t.topic_id, t.creator_id, t.topic_name, t.topic_url, c.icon_name, tags.tag_name, tag_icon, tag_description, p.title, p.content, p.created_date, pb.post_id, pvv.vote_type, vote_id, bookmark_id, pv.num_of_upvotes,
-    pv.num_of_downvotes, pc.num_of_comments
+		WHERE p.post_url = $2 AND (p.status = 'published' OR p.author_id = $1)
 		ORDER BY p.created_date DESC`,
 		userIDInt, postURL).
 		Scan(&post.Post_ID, &post.Post_URL, &post.User_ID, &post.Username, &post.DisplayName, &post.User_Image, &post.Topic_ID, &post.Topic_User_ID, &post.Topic_Name, &post.Topic_URL, &post.Category_Icon, &post.Tag_Name, &post.Tag_Icon, &post.Tag_Description,
-			&post.Title, &post.Content, &created, &post.Vote_ID, &post.Upvote_Count, &post.Downvote_Count, &post.Vote_Status, &post.Comment_Count, &post.Bookmark_ID, &post.Is_Bookmarked)
+			&post.Title, &post.Content, &created, &post.Post_Flair, &post.User_Flair, &post.Post_Type, &post.Is_Pinned, &post.Is_Announcement, &post.Is_Locked, &post.Is_Topic_Read_Only, &post.Is_NSFW, &post.Is_Spoiler, &post.Blur_NSFW, &post.Blur_Spoiler, &post.Poll, &post.Link, &post.Crosspost_Of_ID, &post.Crosspost_Of, &post.Crosspost_Count, &post.Edited_Date, &post.Edit_Count, &post.Deleted_By, &post.Content_HTML, &post.Vote_ID, &post.Upvote_Count, &post.Downvote_Count, &post.Vote_Status, &post.Comment_Count, &post.View_Count, &post.Viewer_Count, &post.Bookmark_ID, &post.Is_Bookmarked)
 
 	// format created date to RFC3339
 	post.Created_Date = created.Format(time.RFC3339)
@@ -759,18 +861,27 @@ func (h *Handler) GetPostByURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.recordView(r, &post.PostDefaultResult, userIDInt)
+
 	util.WriteJSON(w, http.StatusOK, post)
 }
 
+// count a view of an opened post
+// authors reading their own posts and deleted posts are not counted
+func (h *Handler) recordView(r *http.Request, post *types.PostDefaultResult, userID int) {
+	if post.User_ID == userID || post.Deleted_By != nil {
+		return
+	}
+	h.views.Record(post.Post_ID, views.Viewer(r, userID))
+}
+
 // main page for login user
-// get posts from topics that are under same categories of the topics user follows as well as the most popular posts
-// only if user folllows topics with limits
+// posts of followed topics and users, of topics in the same categories and trending posts, ranked by the feed pipeline
 func (h *Handler) FilterByFollowAndPopularity(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	query := r.URL.Query()
 	limit := query.Get("limit")
 	cursorParam := query.Get("cursor")
-	sortBy := query.Get("sortBy")
 
 	//only verified users can access the data
 	//check token and token header
@@ -794,303 +905,153 @@ func (h *Handler) FilterByFollowAndPopularity(w http.ResponseWriter, r *http.Req
 	//convert limitQuery to integer (check if valid integer)
 	limitQuery, err := strconv.Atoi(limit)
 	//check if limit is an integer
+	if err != nil || limitQuery < 1 {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid limit"))
+		return
+	}
+
+	//optional created date range, kept by every page
+	dateRange, err := ranking.ParseDateRange(query)
 	if err != nil {
 		util.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
-	//add one for later on to check if there is more post
-	limitAddOne := limitQuery + 1
 
-	//check cursor
-	var decodedCursor any
+	var decodedCursor *types.FeedCursor
 	if cursorParam != "" {
-		switch sortBy {
-		case "alpha":
-			decodedCursor, err = cursor.DecodeAlphaCursor(cursorParam)
-		default:
-			decodedCursor, err = cursor.DecodeSumVotesDateCursor(cursorParam)
-		}
-
+		decodedCursor, err = cursor.DecodeFeedCursor(cursorParam)
 		if err != nil {
 			util.WriteError(w, http.StatusBadRequest, err)
 			return
 		}
 	}
 
-	var (
-		rows pgx.Rows
-	)
-	//get data from db //for main feed
-	//first select statement,
-	//first select statement - get posts from topics that are under same categories of the topics user follows
-	//second select statement - most popular posts
-	baseSQLStatement := `SELECT * FROM (
-    		SELECT DISTINCT
-				p.post_id,
-				p.post_url,
-				u.user_id,
-				u.username, 
-				u.display_name,
-				i.image_name, 
-				t.topic_id,
-				t.creator_id,
-				t.topic_name, 
-				t.topic_url,
-				c.icon_name as category_icon, 
-				tags.tag_name, 
-				tags.icon_name as tag_icon, 
-				tags.description as tag_description, 
-				p.title,
-				p.content,
-				p.created_date,
-				pvv.post_vote_id as vote_id,
-				COALESCE(pv.num_of_upvotes, 0) as num_of_upvotes,
-				COALESCE(pv.num_of_downvotes, 0) as num_of_downvotes,
-				COALESCE(pv.sum_of_votes, 0) AS sum_of_votes,
-				COALESCE(pvv.vote_type, 0) AS vote_status,
-				COALESCE(pc.num_of_comments, 0) AS num_of_comments,
-				pb.post_bookmark_id as bookmark_id,
-				CASE WHEN pb.post_id IS NULL THEN FALSE ELSE TRUE END AS is_bookmarked,
-				true as is_following
-				FROM posts p
-				INNER JOIN users u ON u.user_id = p.author_id
-				INNER JOIN profile_image i ON u.image_id = i.image_id
-				INNER JOIN topics t ON p.topic_id = t.topic_id
-				INNER JOIN categories c ON t.category_id = c.category_id
-				INNER JOIN topics_followers tf ON tf.topic_id = t.topic_id
-				LEFT JOIN tags ON tags.tag_id = p.tag_id
-				LEFT JOIN (
-					SELECT post_id, 
-					COUNT(post_vote_id) FILTER (WHERE vote_type = 1) as num_of_upvotes,
-					COUNT(post_vote_id) FILTER (WHERE vote_type = -1) as num_of_downvotes,
-					SUM(vote_type) as sum_of_votes 
-					FROM posts_votes
-					GROUP BY post_id
-    		) pv ON p.post_id = pv.post_id 
-				LEFT JOIN posts_votes pvv ON p.post_id = pvv.post_id AND pvv.user_id = $1
-				LEFT JOIN (
-					SELECT post_id,
-					COUNT(comment_id) as num_of_comments 
-					FROM posts_comments
-					GROUP BY post_id
-				) pc ON pc.post_id = p.post_id
-				LEFT JOIN posts_bookmarks pb ON pb.post_id = p.post_id AND pb.user_id = $1
-				WHERE tf.user_id = $1
-				GROUP BY p.post_id, u.user_id, u.username, u.display_name, i.image_name, t.topic_id,  t.creator_id, t.topic_name, t.topic_url, c.icon_name, tags.tag_name, tag_icon, tag_description, p.title, p.content, p.created_date, pb.post_id, pvv.vote_type, vote_id, bookmark_id, pv.num_of_upvotes, pv.num_of_downvotes, pv.sum_of_votes, pc.num_of_comments
-    		UNION ALL
-			SELECT
-				p.post_id,
-				p.post_url,
-				u.user_id,
-				u.username, 
-				u.display_name,
-				i.image_name, 
-				t.topic_id,
-				t.creator_id,
-				t.topic_name, 
-				t.topic_url,
-				c.icon_name as category_icon, 
-				tags.tag_name, 
-				tags.icon_name as tag_icon, 
-				tags.description as tag_description,
-				p.title,
-				p.content,
-				p.created_date,
-				pvv.post_vote_id as vote_id,
-				COALESCE(pv.num_of_upvotes, 0) as num_of_upvotes,
-				COALESCE(pv.num_of_downvotes, 0) as num_of_downvotes,
-				COALESCE(pv.sum_of_votes, 0) AS sum_of_votes,
-				COALESCE(pvv.vote_type, 0) AS vote_status,
-				COALESCE(pc.num_of_comments, 0) AS num_of_comments,
-				pb.post_bookmark_id as bookmark_id,
-				CASE WHEN pb.post_id IS NULL THEN FALSE ELSE TRUE END AS is_bookmarked,
-				false as is_following
-				FROM posts p
-				INNER JOIN users u ON u.user_id = p.author_id
-				INNER JOIN profile_image i ON u.image_id = i.image_id
-				INNER JOIN topics t ON p.topic_id = t.topic_id
-				INNER JOIN categories c ON t.category_id = c.category_id
-				LEFT JOIN (
-					SELECT post_id, 
-					COUNT(post_vote_id) FILTER (WHERE vote_type = 1) as num_of_upvotes,
-					COUNT(post_vote_id) FILTER (WHERE vote_type = -1) as num_of_downvotes,
-					SUM(vote_type) as sum_of_votes 
-					FROM posts_votes
-					GROUP BY post_id
-				) pv ON p.post_id = pv.post_id 
-				LEFT JOIN posts_votes pvv ON p.post_id = pvv.post_id AND pvv.user_id = $1
-				LEFT JOIN (
-					SELECT post_id,
-					COUNT(comment_id) as num_of_comments 
-					FROM posts_comments
-					GROUP BY post_id
-				) pc ON pc.post_id = p.post_id
-				LEFT JOIN tags ON tags.tag_id = p.tag_id
-				LEFT JOIN posts_bookmarks pb ON pb.post_id = p.post_id AND pb.user_id = $1
-				WHERE t.visibility = 'public' AND p.post_id NOT IN (
-					SELECT p2.post_id
-					FROM posts p2
-					JOIN topics_followers tf2 ON tf2.topic_id = p2.topic_id
-					WHERE tf2.user_id = $1
-				)
-				GROUP BY p.post_id, u.user_id, u.username, u.display_name,t.topic_id, t.creator_id, t.topic_name, t.topic_url, i.image_name,  c.icon_name,tags.tag_name, p.title, p.content, p.created_date, pb.post_id, vote_type, vote_id, bookmark_id, tag_icon, tag_description, num_of_upvotes, num_of_downvotes, num_of_comments, sum_of_votes
-		) main_feed_post
-		`
-
-	if cursorParam == "" {
-		var orderStatement string
-		switch sortBy {
-		case "new":
-			orderStatement = ` ORDER BY is_following DESC, created_date DESC, sum_of_votes DESC, num_of_comments DESC, post_id DESC `
-		case "alpha":
-			orderStatement = ` ORDER BY title ASC, created_date DESC `
-		default:
-			orderStatement = ` ORDER BY is_following DESC, sum_of_votes DESC, num_of_comments DESC, created_date DESC, post_id DESC `
-
-		}
-		//if ?cursor= gives empty string
-		SQLStatement := baseSQLStatement + orderStatement + ` LIMIT $2`
-		rows, err = h.db.Query(ctx, SQLStatement, userID, limitAddOne)
-	} else {
-		switch d := decodedCursor.(type) {
-		case *types.SumVotesDateCursor:
-			d = decodedCursor.(*types.SumVotesDateCursor)
-			switch sortBy {
-			case "new":
-				SQLStatement := baseSQLStatement + `
-				WHERE (
-					is_following < $2 OR 
-					(
-						is_following = $2 AND 
-						( 
-						created_date < $3
-						OR (created_date = $3 AND sum_of_votes < $4)
-						OR (created_date = $3 AND sum_of_votes = $4 AND num_of_comments < $5)
-						OR (created_date = $3 AND sum_of_votes = $4 AND num_of_comments = $5 AND post_id < $6)
-						)
-					)
-				)	
-				ORDER BY is_following DESC, created_date DESC, sum_of_votes DESC, num_of_comments DESC, post_id DESC
-				LIMIT $7
-				`
-				rows, err = h.db.Query(
-					ctx,
-					SQLStatement,
-					userID,
-					d.Is_Following,
-					d.Created_Date,
-					d.Sum_Votes_Count,
-					d.Comment_Count,
-					d.Post_ID,
-					limitAddOne,
-				)
-
-			default:
-				SQLStatement := baseSQLStatement + `
-				WHERE (
-					is_following < $2 OR 
-					(
-						is_following = $2 AND 
-						( 
-						sum_of_votes < $3
-						OR (sum_of_votes = $3 AND num_of_comments < $4)
-						OR (sum_of_votes = $3 AND num_of_comments = $4 AND created_date < $5)
-						OR (sum_of_votes = $3 AND num_of_comments = $4 AND created_date = $5 AND post_id < $6)
-						)
-					)
-				)
-				ORDER BY is_following DESC, sum_of_votes DESC, num_of_comments DESC, created_date DESC, post_id DESC
-				LIMIT $7
-				`
-				rows, err = h.db.Query(
-					ctx,
-					SQLStatement,
-					userID,
-					d.Is_Following,
-					d.Sum_Votes_Count,
-					d.Comment_Count,
-					d.Created_Date,
-					d.Post_ID,
-					limitAddOne,
-				)
-			}
-		case *types.AlphaDateCursor:
-			d = decodedCursor.(*types.AlphaDateCursor)
-			SQLStatement := baseSQLStatement + `
-			WHERE ( title > $2 
-			OR (title = $2 AND created_date < $3)
-			)
-			ORDER BY title ASC, created_date DESC
-			LIMIT $4
-	`
-			rows, err = h.db.Query(
-				ctx,
-				SQLStatement,
-				userID,
-				d.Title,
-				d.Created_Date,
-				limitAddOne,
-			)
-		}
-
-	}
-
-	//database error 500 status code
-	//same as res.send(500)
+	//the page is taken from the ranking of the first page, kept in a snapshot
+	tx, err := h.db.Begin(ctx)
 	if err != nil {
 		util.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
+	defer tx.Rollback(ctx)
 
-	postsArr := make([]types.PostSumVotesIsFollowingResult, 0)
-	for rows.Next() {
-		var post types.PostSumVotesIsFollowingResult
-		var created time.Time
+	candidates, nextCursor, err := h.feed.Page(ctx, tx, userID, limitQuery, dateRange, decodedCursor)
+	if errors.Is(err, feed.ErrCursorExpired) {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
 
-		if err := rows.Scan(&post.Post_ID, &post.Post_URL, &post.User_ID, &post.Username, &post.DisplayName, &post.User_Image,
-			&post.Topic_ID, &post.Topic_User_ID, &post.Topic_Name, &post.Topic_URL, &post.Category_Icon, &post.Tag_Name, &post.Tag_Icon, &post.Tag_Description,
-			&post.Title, &post.Content, &created, &post.Vote_ID, &post.Upvote_Count, &post.Downvote_Count, &post.Sum_Votes, &post.Vote_Status, &post.Comment_Count, &post.Bookmark_ID, &post.Is_Bookmarked, &post.Is_Following); err != nil {
+	postIDs := make([]int, 0, len(candidates))
+	for _, candidate := range candidates {
+		postIDs = append(postIDs, candidate.Post_ID)
+	}
+	postsArr, err := h.getFeedPosts(ctx, userID, postIDs)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	//announcements are only on the first page, they are never part of result
+	response := map[string]any{
+		"result": postsArr,
+		"cursor": nextCursor,
+	}
+	if cursorParam == "" {
+		pinnedArr, err := h.getPinnedPosts(ctx, userID, "%", ` AND p.announced_date IS NOT NULL`, `p.announced_date DESC, p.post_id DESC`)
+		if err != nil {
 			util.WriteError(w, http.StatusInternalServerError, err)
 			return
 		}
+		response["announcements"] = pinnedArr
+	}
 
-		post.Created_Date = created.Format(time.RFC3339)
-		postsArr = append(postsArr, post)
+	util.WriteJSON(w, http.StatusOK, response)
+}
+
+// load the posts of a feed page, in the order of postIDs
+// posts deleted since they were ranked are left out
+func (h *Handler) getFeedPosts(ctx context.Context, userID int, postIDs []int) ([]types.PostSumVotesIsFollowingResult, error) {
+	rows, err := h.db.Query(ctx, `SELECT
+		p.post_id,
+		p.post_url,
+		u.user_id,
+		u.username, 
+		u.display_name,
+		i.image_name, 
+		t.topic_id,
+		t.creator_id,
+		t.topic_name, 
+		t.topic_url,
+		c.icon_name as category_icon, 
+		tags.tag_name, 
+		tags.icon_name as tag_icon, 
+		tags.description as tag_description, 
+		p.title,
+		p.content,
+		p.created_date,
+		`+moderation.FlairColumns+`,
+		p.post_type,
+		p.is_pinned,
+		p.announced_date IS NOT NULL AS is_announcement,
+		p.is_locked,
+		t.is_read_only AS is_topic_read_only,
+		`+nsfw.Columns+`,
+		`+polls.Column+`,
+		`+links.Column+`,
+		`+crossposts.Column+`,
+		to_char(p.edited_date, 'YYYY-MM-DD"T"HH24:MI:SS"Z"') AS edited_date,
+		p.edit_count,
+		p.deleted_by,
+		COALESCE(p.content_html, '') AS content_html,
+		pvv.post_vote_id as vote_id,
+		p.upvote_count AS num_of_upvotes,
+		p.downvote_count AS num_of_downvotes,
+		p.upvote_count - p.downvote_count AS sum_of_votes,
+		COALESCE(pvv.vote_type, 0) AS vote_status,
+		p.comment_count AS num_of_comments,
+		p.view_count,
+		p.viewer_count,
+		pb.post_bookmark_id as bookmark_id,
+		CASE WHEN pb.post_id IS NULL THEN FALSE ELSE TRUE END AS is_bookmarked,
+		EXISTS (SELECT 1 FROM topics_followers tf WHERE tf.topic_id = p.topic_id AND tf.user_id = $1) AS is_following
+		FROM posts p
+		INNER JOIN users u ON u.user_id = p.author_id
+		INNER JOIN profile_image i ON u.image_id = i.image_id
+		INNER JOIN topics t ON p.topic_id = t.topic_id
+		INNER JOIN categories c ON t.category_id = c.category_id
+		LEFT JOIN tags ON tags.tag_id = p.tag_id
+		LEFT JOIN posts_votes pvv ON p.post_id = pvv.post_id AND pvv.user_id = $1
+		LEFT JOIN posts_bookmarks pb ON pb.post_id = p.post_id AND pb.user_id = $1
+		WHERE p.post_id = ANY($2) AND p.status = 'published' AND p.deleted_date IS NULL
+		ORDER BY array_position($2, p.post_id)`,
+		userID, postIDs)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	var nextCursor *string
-	if len(postsArr) > limitQuery {
-		last := postsArr[limitQuery-1]
-		switch sortBy {
-		case "alpha":
-			c, err := cursor.EncodeAlphaCursor(types.AlphaDateCursor{
-				Created_Date: last.Created_Date,
-				Title:        last.Title,
-			})
-			if err == nil {
-				nextCursor = &c
-			}
-		default:
-			c, err := cursor.EncodeSumVotesDateCursor(types.SumVotesDateCursor{
-				Created_Date:    last.Created_Date,
-				Sum_Votes_Count: last.Sum_Votes,
-				Comment_Count:   last.Comment_Count,
-				Is_Following:    &last.Is_Following,
-				Post_ID:         &last.Post_ID,
-			})
-			if err == nil {
-				nextCursor = &c
-			}
+	postsArr := make([]types.PostSumVotesIsFollowingResult, 0, len(postIDs))
+	for rows.Next() {
+		var post types.PostSumVotesIsFollowingResult
+		var created time.Time
+
+		if err := rows.Scan(&post.Post_ID, &post.Post_URL, &post.User_ID, &post.Username, &post.DisplayName, &post.User_Image,
+			&post.Topic_ID, &post.Topic_User_ID, &post.Topic_Name, &post.Topic_URL, &post.Category_Icon, &post.Tag_Name, &post.Tag_Icon, &post.Tag_Description,
+			&post.Title, &post.Content, &created, &post.Post_Flair, &post.User_Flair, &post.Post_Type, &post.Is_Pinned, &post.Is_Announcement, &post.Is_Locked, &post.Is_Topic_Read_Only, &post.Is_NSFW, &post.Is_Spoiler, &post.Blur_NSFW, &post.Blur_Spoiler, &post.Poll, &post.Link, &post.Crosspost_Of_ID, &post.Crosspost_Of, &post.Crosspost_Count, &post.Edited_Date, &post.Edit_Count, &post.Deleted_By, &post.Content_HTML, &post.Vote_ID, &post.Upvote_Count, &post.Downvote_Count, &post.Sum_Votes, &post.Vote_Status, &post.Comment_Count, &post.View_Count, &post.Viewer_Count, &post.Bookmark_ID, &post.Is_Bookmarked, &post.Is_Following); err != nil {
+			return nil, err
 		}
-		postsArr = postsArr[:limitQuery]
-	} else {
-		nextCursor = nil
-	}
 
-	util.WriteJSON(w, http.StatusOK, map[string]any{
-		"result": postsArr,
-		"cursor": nextCursor,
-	})
+		post.Created_Date = created.Format(time.RFC3339)
+		postsArr = append(postsArr, post)
+	}
+	return postsArr, rows.Err()
 }
 
 // for the for you tab just for post under topics user follow
@@ -1130,6 +1091,13 @@ func (h *Handler) FilterByFollow(w http.ResponseWriter, r *http.Request) {
 	//add one for later on to check if there is more post
 	limitAddOne := limitQuery + 1
 
+	//optional created date range, kept by every page
+	dateRange, err := ranking.ParseDateRange(query)
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
 	//check cursor
 	var decodedCursor any
 	if cursorParam != "" {
@@ -1169,12 +1137,28 @@ func (h *Handler) FilterByFollow(w http.ResponseWriter, r *http.Request) {
 		p.title,
 		p.content,
 		p.created_date,
+		` + moderation.FlairColumns + `,
+		p.post_type,
+		p.is_pinned,
+		p.announced_date IS NOT NULL AS is_announcement,
+		p.is_locked,
+		t.is_read_only AS is_topic_read_only,
+		` + nsfw.Columns + `,
+		` + polls.Column + `,
+		` + links.Column + `,
+		` + crossposts.Column + `,
+		to_char(p.edited_date, 'YYYY-MM-DD"T"HH24:MI:SS"Z"') AS edited_date,
+		p.edit_count,
+		p.deleted_by,
+		COALESCE(p.content_html, '') AS content_html,
 		pvv.post_vote_id as vote_id, 
-		COALESCE(pv.num_of_upvotes, 0) as num_of_upvotes,
-		COALESCE(pv.num_of_downvotes, 0) as num_of_downvotes,
-		COALESCE(pv.sum_of_votes, 0) AS sum_of_votes,
+		p.upvote_count AS num_of_upvotes,
+		p.downvote_count AS num_of_downvotes,
+		p.upvote_count - p.downvote_count AS sum_of_votes,
 		COALESCE(pvv.vote_type, 0) AS vote_status,
-		COALESCE(pc.num_of_comments, 0) AS num_of_comments,
+		p.comment_count AS num_of_comments,
+		p.view_count,
+		p.viewer_count,
 		pb.post_bookmark_id as bookmark_id, 
 		CASE WHEN pb.post_id IS NULL THEN FALSE ELSE TRUE END AS is_bookmarked
 		FROM posts p
@@ -1183,39 +1167,25 @@ func (h *Handler) FilterByFollow(w http.ResponseWriter, r *http.Request) {
 		INNER JOIN profile_image i ON i.image_id = u.image_id
 		INNER JOIN topics t ON t.topic_id = p.topic_id
 		INNER JOIN categories c ON t.category_id = c.category_id
-		LEFT JOIN ( 
-			SELECT post_id,  
-			COUNT(post_vote_id) FILTER (WHERE vote_type = 1) as num_of_upvotes,
-			COUNT(post_vote_id) FILTER (WHERE vote_type = -1) as num_of_downvotes,
-			SUM(vote_type) as sum_of_votes 
-      		FROM posts_votes
-			GROUP BY post_id
-		) pv ON p.post_id = pv.post_id 
 		LEFT JOIN posts_votes pvv ON p.post_id = pvv.post_id AND pvv.user_id = $1
-		LEFT JOIN (
-			SELECT post_id,
-			COUNT(comment_id) as num_of_comments 
-			FROM posts_comments
-			GROUP BY post_id
-    	) pc ON pc.post_id = p.post_id
 		LEFT JOIN posts_bookmarks pb ON pb.post_id = p.post_id AND pb.user_id = $1
 		INNER JOIN topics_followers tf ON tf.topic_id = t.topic_id
-		WHERE tf.user_id = $1
+		WHERE tf.user_id = $1 AND p.status = 'published' AND p.deleted_date IS NULL` + nsfw.Visible + ranking.DateRangeWhere(2) + `
 		`
 	//if no cursor param. first batch
 	if cursorParam == "" {
 		var orderStatement string
 		switch sortBy {
 		case "new":
-			orderStatement = ` ORDER BY p.created_date, COALESCE(pv.sum_of_votes, 0) DESC, COALESCE(pc.num_of_comments, 0) DESC, p.post_id DESC  `
+			orderStatement = ` ORDER BY p.created_date DESC, (p.upvote_count - p.downvote_count) DESC, p.comment_count DESC, p.post_id DESC  `
 		case "alpha":
 			orderStatement = ` ORDER BY p.title ASC, p.created_date DESC `
 		default:
-			orderStatement = ` ORDER BY COALESCE(pv.sum_of_votes, 0) DESC,  COALESCE(pc.num_of_comments, 0) DESC, p.created_date DESC, p.post_id DESC `
+			orderStatement = ` ORDER BY (p.upvote_count - p.downvote_count) DESC,  p.comment_count DESC, p.created_date DESC, p.post_id DESC `
 		}
 
-		SQLStatement := baseSQLStatement + orderStatement + ` LIMIT $2`
-		rows, err = h.db.Query(ctx, SQLStatement, userID, limitAddOne)
+		SQLStatement := baseSQLStatement + orderStatement + ` LIMIT $4`
+		rows, err = h.db.Query(ctx, SQLStatement, userID, dateRange.From_Date, dateRange.To_Date, limitAddOne)
 	} else {
 		switch d := decodedCursor.(type) {
 		case *types.SumVotesDateCursor:
@@ -1224,17 +1194,19 @@ func (h *Handler) FilterByFollow(w http.ResponseWriter, r *http.Request) {
 			case "new":
 				SQLStatement := baseSQLStatement + `
 				AND (
-					p.created_date < $2
-					OR (p.created_date = $2 AND COALESCE(pv.sum_of_votes,0) < $3)
-					OR (p.created_date = $2 AND COALESCE(pv.sum_of_votes,0) = $3,  COALESCE(pc.num_of_comments, 0) < $4 )
-					OR (p.created_date = $2 AND COALESCE(pv.sum_of_votes,0) = $3,  COALESCE(pc.num_of_comments, 0) = $4, p.post_id < $5)
+					p.created_date < $4
+					OR (p.created_date = $4 AND (p.upvote_count - p.downvote_count) < $5)
+					OR (p.created_date = $4 AND (p.upvote_count - p.downvote_count) = $5 AND p.comment_count < $6)
+					OR (p.created_date = $4 AND (p.upvote_count - p.downvote_count) = $5 AND p.comment_count = $6 AND p.post_id < $7)
 				)
-				ORDER BY p.created_date, COALESCE(pv.sum_of_votes, 0) DESC, COALESCE(pc.num_of_comments, 0) DESC, p.post_id DESC LIMIT $6`
+				ORDER BY p.created_date DESC, (p.upvote_count - p.downvote_count) DESC, p.comment_count DESC, p.post_id DESC LIMIT $8`
 
 				rows, err = h.db.Query(
 					ctx,
 					SQLStatement,
 					userID,
+					dateRange.From_Date,
+					dateRange.To_Date,
 					d.Created_Date,
 					d.Sum_Votes_Count,
 					d.Comment_Count,
@@ -1245,18 +1217,20 @@ func (h *Handler) FilterByFollow(w http.ResponseWriter, r *http.Request) {
 			default:
 				SQLStatement := baseSQLStatement + `
 				AND (
-					COALESCE(pv.sum_of_votes,0) < $2
-					OR (COALESCE(pv.sum_of_votes,0) = $2 AND COALESCE(pc.num_of_comments, 0) < $3)
-					OR (COALESCE(pv.sum_of_votes,0) = $2 AND COALESCE(pc.num_of_comments, 0) = $3 AND p.created_date < $4)
-					OR (COALESCE(pv.sum_of_votes,0) = $2 AND COALESCE(pc.num_of_comments, 0) = $3 AND p.created_date < $4 AND p.post_id = $5)
+					(p.upvote_count - p.downvote_count) < $4
+					OR ((p.upvote_count - p.downvote_count) = $4 AND p.comment_count < $5)
+					OR ((p.upvote_count - p.downvote_count) = $4 AND p.comment_count = $5 AND p.created_date < $6)
+					OR ((p.upvote_count - p.downvote_count) = $4 AND p.comment_count = $5 AND p.created_date = $6 AND p.post_id < $7)
 				)
-				ORDER BY COALESCE(pv.sum_of_votes,0) DESC, p.created_date DESC, p.post_id DESC
-				LIMIT $6
+				ORDER BY (p.upvote_count - p.downvote_count) DESC, p.comment_count DESC, p.created_date DESC, p.post_id DESC
+				LIMIT $8
 			`
 				rows, err = h.db.Query(
 					ctx,
 					SQLStatement,
 					userID,
+					dateRange.From_Date,
+					dateRange.To_Date,
 					d.Sum_Votes_Count,
 					d.Comment_Count,
 					d.Created_Date,
@@ -1268,19 +1242,19 @@ func (h *Handler) FilterByFollow(w http.ResponseWriter, r *http.Request) {
 			d = decodedCursor.(*types.AlphaDateCursor)
 			SQLStatement := baseSQLStatement + `
 			AND (
-				p.title > $2
-				OR (p.title = $2 AND p.created_date < $3)
+				p.title > $4
+				OR (p.title = $4 AND p.created_date < $5)
 			)
-			GROUP BY p.post_id, p.post_url, u.user_id, u.username, u.display_name, i.image_name, t.topic_id, t.creator_id, t.topic_name, t.topic_url, c.icon_name, tags.tag_name, tag_icon, tag_description, p.title, p.content, p.created_date, pb.post_id, pvv.vote_type, vote_id, bookmark_id, pv.num_of_upvotes,
-			pv.num_of_downvotes, pc.num_of_comments, pv.sum_of_votes
 			ORDER BY p.title ASC, p.created_date DESC
-			LIMIT $4`
+			LIMIT $6`
 			rows, err = h.db.Query(
 				ctx,
 				SQLStatement,
 				userID,
-				d.Created_Date,
+				dateRange.From_Date,
+				dateRange.To_Date,
 				d.Title,
+				d.Created_Date,
 				limitAddOne,
 			)
 		}
@@ -1300,7 +1274,7 @@ func (h *Handler) FilterByFollow(w http.ResponseWriter, r *http.Request) {
 
 		if err := rows.Scan(&post.Post_ID, &post.Post_URL, &post.User_ID, &post.Username, &post.DisplayName, &post.User_Image,
 			&post.Topic_ID, &post.Topic_User_ID, &post.Topic_Name, &post.Topic_URL, &post.Category_Icon, &post.Tag_Name, &post.Tag_Icon, &post.Tag_Description,
-			&post.Title, &post.Content, &created, &post.Vote_ID, &post.Upvote_Count, &post.Downvote_Count, &post.Sum_Votes, &post.Vote_Status, &post.Comment_Count, &post.Bookmark_ID, &post.Is_Bookmarked); err != nil {
+			&post.Title, &post.Content, &created, &post.Post_Flair, &post.User_Flair, &post.Post_Type, &post.Is_Pinned, &post.Is_Announcement, &post.Is_Locked, &post.Is_Topic_Read_Only, &post.Is_NSFW, &post.Is_Spoiler, &post.Blur_NSFW, &post.Blur_Spoiler, &post.Poll, &post.Link, &post.Crosspost_Of_ID, &post.Crosspost_Of, &post.Crosspost_Count, &post.Edited_Date, &post.Edit_Count, &post.Deleted_By, &post.Content_HTML, &post.Vote_ID, &post.Upvote_Count, &post.Downvote_Count, &post.Sum_Votes, &post.Vote_Status, &post.Comment_Count, &post.View_Count, &post.Viewer_Count, &post.Bookmark_ID, &post.Is_Bookmarked); err != nil {
 			util.WriteError(w, http.StatusInternalServerError, err)
 			return
 		}
@@ -1381,28 +1355,165 @@ func (h *Handler) AddPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if payload.Title == "" || payload.Content == "" || payload.Post_URL == "" {
+	//polls and links can go without content, the options or the link are the content
+	if payload.Title == "" || (payload.Content == "" && payload.Poll == nil && payload.Link_URL == nil) {
 		util.WriteError(w, http.StatusBadRequest, errors.New("invalid inputs"))
 		return
 	}
+	if payload.Poll != nil && payload.Link_URL != nil {
+		util.WriteError(w, http.StatusBadRequest, errors.New("a post cannot be both a poll and a link"))
+		return
+	}
+
+	postType := polls.PostTypeText
+	if payload.Poll != nil {
+		postType = polls.PostTypePoll
+		if err := polls.Check(payload.Poll); err != nil {
+			util.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if payload.Link_URL != nil {
+		postType = links.PostTypeLink
+		if _, err := links.CheckURL(*payload.Link_URL); err != nil {
+			util.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	//drafts stay private, scheduled posts are published by the background publisher
+	status, err := publishing.CheckStatus(payload.Status, payload.Scheduled_Date)
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if status != publishing.StatusScheduled {
+		payload.Scheduled_Date = nil
+	}
+
+	//archived topics and banned users cannot get new posts
+	err = moderation.CheckCanParticipate(ctx, h.db, topicIDInt, userIDInt)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid topic id"))
+		return
+	}
+	if moderation.IsLocked(err) {
+		util.WriteError(w, http.StatusLocked, err)
+		return
+	}
+	if moderation.IsForbidden(err) {
+		util.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	//post flair must belong to the topic, some topics require one
+	if err := h.checkPostFlair(ctx, topicIDInt, payload.Post_Flair_ID, status != publishing.StatusDraft); err != nil {
+		if errors.Is(err, moderation.ErrInvalidFlair) || errors.Is(err, moderation.ErrFlairNeeded) {
+			util.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	//the page is read before the post is stored so no transaction waits on it
+	//a page that cannot be read only leaves the preview empty, private addresses are refused
+	var preview types.LinkPreview
+	if payload.Link_URL != nil {
+		preview, err = links.Fetch(ctx, h.links, *payload.Link_URL)
+		if errors.Is(err, links.ErrInvalidURL) || errors.Is(err, links.ErrBlockedAddress) {
+			util.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	//content is markdown, the sanitized html is stored so feeds do not render it again
+	contentHTML := markdown.Render(payload.Content)
+
+	//the post and its poll or link are stored together
+	//the transaction is rolled back before the next attempt when the insert fails
+	insert := func(postURL string) (int, error) {
+		tx, err := h.db.Begin(ctx)
+		if err != nil {
+			return 0, err
+		}
+		defer tx.Rollback(ctx)
+
+		//posts in an nsfw topic are always nsfw, as crossposts into it are
+		var postID int
+		err = tx.QueryRow(ctx,
+			`INSERT INTO posts (topic_id, author_id, tag_id, title, content, post_url, post_flair_id, status, scheduled_date,
+			content_html, content_html_version, post_type, is_nsfw, is_spoiler)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12,
+			COALESCE($13, FALSE) OR (SELECT is_nsfw FROM topics WHERE topic_id = $1), $14) RETURNING post_id`,
+			topicIDInt, userIDInt, payload.Tag_ID, payload.Title, payload.Content, postURL, payload.Post_Flair_ID, status, payload.Scheduled_Date,
+			contentHTML, markdown.Version, postType, payload.Is_NSFW, payload.Is_Spoiler,
+		).Scan(&postID)
+		if err != nil {
+			return 0, err
+		}
+
+		if payload.Poll != nil {
+			if err := polls.Create(ctx, tx, postID, *payload.Poll); err != nil {
+				return 0, err
+			}
+		}
+		if payload.Link_URL != nil {
+			if err := links.Create(ctx, tx, postID, preview); err != nil {
+				return 0, err
+			}
+		}
+		return postID, tx.Commit(ctx)
+	}
 
+	//post url is generated from the title and does not change when the title is edited
+	//try again with a new suffix if another post took the url in the meantime
 	var Post_ID int
-	err = h.db.QueryRow(ctx,
-		`INSERT INTO posts (topic_id, author_id, tag_id, title, content, post_url)
-		VALUES ($1, $2, $3, $4, $5, $6) RETURNING post_id`,
-		topicIDInt, userIDInt, payload.Tag_ID, payload.Title, payload.Content, payload.Post_URL,
-	).Scan(&Post_ID)
+	for attempt := 1; ; attempt++ {
+		postURL, err := slug.ForPost(ctx, h.db, payload.Title)
+		if err != nil {
+			util.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		Post_ID, err = insert(postURL)
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
+			if attempt < slug.InsertAttempts {
+				continue
+			}
+			util.WriteError(w, http.StatusConflict, slug.ErrTaken)
+			return
+		}
 
+		//server error
+		if err != nil {
+			util.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		break
+	}
+
+	result, err := h.getAddedPost(ctx, userIDInt, Post_ID)
 	//server error
 	if err != nil {
 		util.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	util.WriteJSON(w, http.StatusOK, result)
+
+}
+
+// get a post just added by the user, as addPost and crosspost return it
+func (h *Handler) getAddedPost(ctx context.Context, userID int, postID int) (types.PostDefaultResult, error) {
 	var result types.PostDefaultResult
 	var created time.Time
 
-	err = h.db.QueryRow(ctx, `SELECT 
+	err := h.db.QueryRow(ctx, `SELECT 
 		p.post_id,
 		p.post_url,
 		u.user_id, 
@@ -1420,12 +1531,28 @@ func (h *Handler) AddPost(w http.ResponseWriter, r *http.Request) {
 		p.title, 
 		p.content, 
 		p.created_date,
+		`+moderation.FlairColumns+`,
+		p.post_type,
+		p.is_pinned,
+		p.announced_date IS NOT NULL AS is_announcement,
+		p.is_locked,
+		t.is_read_only AS is_topic_read_only,
+		`+nsfw.Columns+`,
+		`+polls.Column+`,
+		`+links.Column+`,
+		`+crossposts.Column+`,
+		to_char(p.edited_date, 'YYYY-MM-DD"T"HH24:MI:SS"Z"') AS edited_date,
+		p.edit_count,
+		p.deleted_by,
+		COALESCE(p.content_html, '') AS content_html,
 		pvv.post_vote_id as vote_id,
-		COALESCE(pv.num_of_upvotes, 0) as num_of_upvotes,
-		COALESCE(pv.num_of_downvotes, 0) as num_of_downvotes,
-		COALESCE(pv.sum_of_votes, 0) AS sum_of_votes,
+		p.upvote_count AS num_of_upvotes,
+		p.downvote_count AS num_of_downvotes,
+		p.upvote_count - p.downvote_count AS sum_of_votes,
 		COALESCE(pvv.vote_type, 0) AS vote_status,
-		COALESCE(pc.num_of_comments, 0) AS num_of_comments,
+		p.comment_count AS num_of_comments,
+		p.view_count,
+		p.viewer_count,
 		pb.post_bookmark_id as bookmark_id, 
 		CASE WHEN pb.post_id IS NULL THEN FALSE ELSE TRUE END AS is_bookmarked
 		FROM posts p 
@@ -1434,38 +1561,17 @@ func (h *Handler) AddPost(w http.ResponseWriter, r *http.Request) {
 		INNER JOIN profile_image i ON u.image_id = i.image_id
 		INNER JOIN topics t ON t.topic_id = p.topic_id
 		INNER JOIN categories c ON t.category_id = c.category_id
-		LEFT JOIN (
-			SELECT post_id, 
-			COUNT(post_vote_id) FILTER (WHERE vote_type = 1) as num_of_upvotes,
-			COUNT(post_vote_id) FILTER (WHERE vote_type = -1) as num_of_downvotes,
-			SUM(vote_type) as sum_of_votes 
-			FROM posts_votes
-			GROUP BY post_id
-    	) pv ON p.post_id = pv.post_id 
 		LEFT JOIN posts_votes pvv ON p.post_id = pvv.post_id AND pvv.user_id = $1
-		LEFT JOIN (
-			SELECT post_id,
-			COUNT(comment_id) as num_of_comments 
-			FROM posts_comments
-			GROUP BY post_id
-    	) pc ON pc.post_id = p.post_id
 		LEFT JOIN posts_bookmarks pb ON pb.post_id = p.post_id AND pb.user_id = $1
 		WHERE p.post_id = $2 `,
-		userIDInt, Post_ID).
+		userID, postID).
 		Scan(&result.Post_ID, &result.Post_URL, &result.User_ID, &result.Username, &result.DisplayName, &result.User_Image,
 			&result.Topic_ID, &result.Topic_User_ID, &result.Topic_Name, &result.Topic_URL, &result.Category_Icon, &result.Tag_Name, &result.Tag_Icon, &result.Tag_Description,
-			&result.Title, &result.Content, &created, &result.Vote_ID, &result.Upvote_Count, &result.Downvote_Count, &result.Sum_Votes, &result.Vote_Status, &result.Comment_Count, &result.Bookmark_ID, &result.Is_Bookmarked)
+			&result.Title, &result.Content, &created, &result.Post_Flair, &result.User_Flair, &result.Post_Type, &result.Is_Pinned, &result.Is_Announcement, &result.Is_Locked, &result.Is_Topic_Read_Only, &result.Is_NSFW, &result.Is_Spoiler, &result.Blur_NSFW, &result.Blur_Spoiler, &result.Poll, &result.Link, &result.Crosspost_Of_ID, &result.Crosspost_Of, &result.Crosspost_Count, &result.Edited_Date, &result.Edit_Count, &result.Deleted_By, &result.Content_HTML, &result.Vote_ID, &result.Upvote_Count, &result.Downvote_Count, &result.Sum_Votes, &result.Vote_Status, &result.Comment_Count, &result.View_Count, &result.Viewer_Count, &result.Bookmark_ID, &result.Is_Bookmarked)
 
 	// format created date to RFC3339
 	result.Created_Date = created.Format(time.RFC3339)
-	//server error
-	if err != nil {
-		util.WriteError(w, http.StatusInternalServerError, err)
-		return
-	}
-
-	util.WriteJSON(w, http.StatusOK, result)
-
+	return result, err
 }
 
 // Update/edit posts information
@@ -1497,17 +1603,88 @@ func (h *Handler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if payload.Title == "" || payload.Content == "" {
+	var topicIDInt int
+	var status, postType string
+	var isCrosspost bool
+	err = h.db.QueryRow(ctx, `SELECT topic_id, status, post_type, crosspost_of_id IS NOT NULL FROM posts WHERE post_id = $1 AND deleted_date IS NULL`, postIDInt).
+		Scan(&topicIDInt, &status, &postType, &isCrosspost)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid post id"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	//polls, links and crossposts can go without content, poll options and links cannot be edited
+	if payload.Title == "" || (payload.Content == "" && postType != polls.PostTypePoll && postType != links.PostTypeLink && !isCrosspost) {
 		util.WriteError(w, http.StatusBadRequest, errors.New("invalid inputs"))
 		return
 	}
 
-	var result types.PostUpdatePayload
-	err = h.db.QueryRow(ctx,
-		`UPDATE posts SET tag_id = $1, title = $2, content = $3
-		WHERE post_id = $4 RETURNING tag_id, title, content`,
-		payload.Tag_ID, payload.Title, payload.Content, postIDInt,
-	).Scan(&result.Tag_ID, &result.Title, &result.Content)
+	//post flair must belong to the topic, some topics require one
+	if err := h.checkPostFlair(ctx, topicIDInt, payload.Post_Flair_ID, status != publishing.StatusDraft); err != nil {
+		if errors.Is(err, moderation.ErrInvalidFlair) || errors.Is(err, moderation.ErrFlairNeeded) {
+			util.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	//the old url keeps redirecting to the new one
+	if payload.Post_URL != nil && *payload.Post_URL != "" {
+		if !slug.IsValid(*payload.Post_URL) {
+			util.WriteError(w, http.StatusBadRequest, errors.New("post url may only contain lowercase letters, numbers and hyphens"))
+			return
+		}
+		err = urlhistory.RenamePost(ctx, tx, postIDInt, *payload.Post_URL)
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
+			util.WriteError(w, http.StatusConflict, errors.New("post url already taken"))
+			return
+		}
+		if err != nil {
+			util.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	//keep the version before this edit
+	if err := revisions.Snapshot(ctx, tx, postIDInt); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	//edits to published posts after the grace window are marked as edited
+	//posts in an nsfw topic stay nsfw
+	var result types.PostUpdateResult
+	err = tx.QueryRow(ctx,
+		`UPDATE posts SET tag_id = $1, title = $2, content = $3, post_flair_id = $4,
+		content_html = $7, content_html_version = $8,
+		is_nsfw = COALESCE($9, is_nsfw) OR (SELECT t.is_nsfw FROM topics t WHERE t.topic_id = posts.topic_id), is_spoiler = COALESCE($10, is_spoiler),
+		edited_date = CASE WHEN is_marked THEN CURRENT_TIMESTAMP ELSE edited_date END,
+		edit_count = CASE WHEN is_marked THEN edit_count + 1 ELSE edit_count END
+		FROM (
+			SELECT status = 'published'
+				AND created_date < CURRENT_TIMESTAMP - make_interval(secs => $6)
+				AND (tag_id, title, content) IS DISTINCT FROM ($1, $2, $3) AS is_marked
+			FROM posts WHERE post_id = $5
+		) AS edit
+		WHERE post_id = $5
+		RETURNING post_id, tag_id, title, content, post_flair_id, post_url,
+		to_char(edited_date, 'YYYY-MM-DD"T"HH24:MI:SS"Z"'), edit_count, is_nsfw, is_spoiler`,
+		payload.Tag_ID, payload.Title, payload.Content, payload.Post_Flair_ID, postIDInt, config.Envs.PostEditGraceSeconds,
+		markdown.Render(payload.Content), markdown.Version, payload.Is_NSFW, payload.Is_Spoiler,
+	).Scan(&result.Post_ID, &result.Tag_ID, &result.Title, &result.Content, &result.Post_Flair_ID, &result.Post_URL,
+		&result.Edited_Date, &result.Edit_Count, &result.Is_NSFW, &result.Is_Spoiler)
 
 	//server error
 	if err != nil {
@@ -1515,10 +1692,50 @@ func (h *Handler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	//and the version after it
+	if err := revisions.Snapshot(ctx, tx, postIDInt); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
 	util.WriteJSON(w, http.StatusOK, result)
 
 }
 
+// check the post flair against the topic settings
+// drafts can leave out a required flair until they are published
+func (h *Handler) checkPostFlair(ctx context.Context, topicID int, flairID *int, required bool) error {
+	if flairID == nil {
+		if !required {
+			return nil
+		}
+		var requireFlair bool
+		err := h.db.QueryRow(ctx, `SELECT require_post_flair FROM topics WHERE topic_id = $1`, topicID).
+			Scan(&requireFlair)
+		if err != nil {
+			return err
+		}
+		if requireFlair {
+			return moderation.ErrFlairNeeded
+		}
+		return nil
+	}
+
+	isFlair, err := moderation.IsTopicFlair(ctx, h.db, topicID, *flairID, moderation.FlairKindPost)
+	if err != nil {
+		return err
+	}
+	if !isFlair {
+		return moderation.ErrInvalidFlair
+	}
+	return nil
+}
+
 // delete posts information
 func (h *Handler) DeletePost(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -1533,9 +1750,11 @@ func (h *Handler) DeletePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//delete post from db
+	//mark post as deleted by its author, comments stay visible
+	//the purge job removes it after the retention period
 	response, err := h.db.Exec(ctx,
-		`DELETE FROM posts WHERE post_id=$1`,
+		`UPDATE posts SET deleted_date = CURRENT_TIMESTAMP, deleted_by = 'author', deleted_by_user_id = author_id
+		WHERE post_id = $1 AND deleted_date IS NULL`,
 		postIDInt,
 	)
 
@@ -1555,3 +1774,793 @@ func (h *Handler) DeletePost(w http.ResponseWriter, r *http.Request) {
 	})
 
 }
+
+// Get the drafts and scheduled posts of the user, newest first
+func (h *Handler) GetMyDrafts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	//get user_id from params
+	userID := mux.Vars(r)["user_id"]
+	//convert userID to integer (check if valid integer)
+	userIDInt, err := strconv.Atoi(userID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	rows, err := h.db.Query(ctx,
+		`SELECT p.post_id, p.post_url, t.topic_id, t.topic_name, t.topic_url, p.tag_id, p.post_flair_id,
+		p.title, p.content, p.status, p.scheduled_date, p.created_date
+		FROM posts p
+		INNER JOIN topics t ON t.topic_id = p.topic_id
+		WHERE p.author_id = $1 AND p.status <> 'published' AND p.deleted_date IS NULL
+		ORDER BY p.created_date DESC, p.post_id DESC`,
+		userIDInt)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer rows.Close()
+
+	drafts := []types.PostDraftResult{}
+	for rows.Next() {
+		var draft types.PostDraftResult
+		var scheduled *time.Time
+		var created time.Time
+		if err := rows.Scan(&draft.Post_ID, &draft.Post_URL, &draft.Topic_ID, &draft.Topic_Name, &draft.Topic_URL, &draft.Tag_ID, &draft.Post_Flair_ID,
+			&draft.Title, &draft.Content, &draft.Status, &scheduled, &created); err != nil {
+			util.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if scheduled != nil {
+			formatted := scheduled.Format(time.RFC3339)
+			draft.Scheduled_Date = &formatted
+		}
+		draft.Created_Date = created.Format(time.RFC3339)
+		drafts = append(drafts, draft)
+	}
+	if err := rows.Err(); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, drafts)
+}
+
+// Publish a draft now, schedule it, or move a scheduled post back to drafts
+func (h *Handler) PublishPost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	//get post_id from params
+	postID := mux.Vars(r)["post_id"]
+	//convert postID to integer (check if valid integer)
+	postIDInt, err := strconv.Atoi(postID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	//get user_id from params
+	userID := mux.Vars(r)["user_id"]
+	//convert userID to integer (check if valid integer)
+	userIDInt, err := strconv.Atoi(userID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.PostPublishPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Status == "" {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid inputs"))
+		return
+	}
+	status, err := publishing.CheckStatus(payload.Status, payload.Scheduled_Date)
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if status != publishing.StatusScheduled {
+		payload.Scheduled_Date = nil
+	}
+
+	//only the author can publish their own post
+	var topicIDInt int
+	var currentStatus string
+	var flairID *int
+	err = h.db.QueryRow(ctx,
+		`SELECT topic_id, status, post_flair_id FROM posts WHERE post_id = $1 AND author_id = $2 AND deleted_date IS NULL`,
+		postIDInt, userIDInt).Scan(&topicIDInt, &currentStatus, &flairID)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid post id"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if currentStatus == publishing.StatusPublished {
+		util.WriteError(w, http.StatusConflict, publishing.ErrAlreadyPublished)
+		return
+	}
+
+	if status != publishing.StatusDraft {
+		//same checks as a new post
+		err = moderation.CheckCanParticipate(ctx, h.db, topicIDInt, userIDInt)
+		if moderation.IsLocked(err) {
+			util.WriteError(w, http.StatusLocked, err)
+			return
+		}
+		if moderation.IsForbidden(err) {
+			util.WriteError(w, http.StatusForbidden, err)
+			return
+		}
+		if err != nil {
+			util.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if err := h.checkPostFlair(ctx, topicIDInt, flairID, true); err != nil {
+			if errors.Is(err, moderation.ErrInvalidFlair) || errors.Is(err, moderation.ErrFlairNeeded) {
+				util.WriteError(w, http.StatusBadRequest, err)
+				return
+			}
+			util.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	//publishing now moves the post to the top of the new feeds
+	_, err = h.db.Exec(ctx,
+		`UPDATE posts SET status = $1, scheduled_date = $2,
+		created_date = CASE WHEN $1 = 'published' THEN CURRENT_TIMESTAMP ELSE created_date END
+		WHERE post_id = $3`,
+		status, payload.Scheduled_Date, postIDInt)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, map[string]any{
+		"post_id":        postIDInt,
+		"status":         status,
+		"scheduled_date": payload.Scheduled_Date,
+	})
+}
+
+// Get every version of a post with the changes between them
+// pass in 0 as user_id if non signup or login users
+func (h *Handler) GetPostRevisions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//get post_id from params
+	postID := mux.Vars(r)["post_id"]
+	//convert postID to integer (check if valid integer)
+	postIDInt, err := strconv.Atoi(postID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	//get user_id from params
+	userID := mux.Vars(r)["user_id"]
+	//convert userID to integer (check if valid integer)
+	userIDInt, err := strconv.Atoi(userID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	//drafts only have history for their author, deleted posts have none
+	var isVisible bool
+	err = h.db.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM posts WHERE post_id = $1 AND deleted_date IS NULL AND (status = 'published' OR author_id = $2))`,
+		postIDInt, userIDInt).Scan(&isVisible)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !isVisible {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid post id"))
+		return
+	}
+
+	revisionArr, err := revisions.List(ctx, h.db, postIDInt)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, revisionArr)
+}
+
+// Search published posts by title, content and comments, best match first
+// q supports "phrases", prefix* words, -excluded words and OR
+// optional filters topic_id, tag_id, author_id and from / to dates (YYYY-MM-DD)
+// optional user_id of the viewer, nsfw posts follow their preferences as in the feeds
+func (h *Handler) SearchPosts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	filter := types.SearchFilter{Query: query.Get("q"), Limit: 20}
+	if limit := query.Get("limit"); limit != "" {
+		limitQuery, err := strconv.Atoi(limit)
+		if err != nil || limitQuery < 1 || limitQuery > 50 {
+			util.WriteError(w, http.StatusBadRequest, errors.New("limit must be between 1 and 50"))
+			return
+		}
+		filter.Limit = limitQuery
+	}
+	limitQuery := filter.Limit
+	//add one for later on to check if there is more post
+	filter.Limit = limitQuery + 1
+
+	//optional id filters
+	for name, target := range map[string]**int{
+		"topic_id":  &filter.Topic_ID,
+		"tag_id":    &filter.Tag_ID,
+		"author_id": &filter.Author_ID,
+	} {
+		if value := query.Get(name); value != "" {
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				util.WriteError(w, http.StatusBadRequest, errors.New("invalid "+name))
+				return
+			}
+			*target = &id
+		}
+	}
+
+	//optional viewer, anonymous viewers get no nsfw posts
+	if value := query.Get("user_id"); value != "" {
+		userID, err := strconv.Atoi(value)
+		if err != nil {
+			util.WriteError(w, http.StatusBadRequest, errors.New("invalid user_id"))
+			return
+		}
+		filter.Viewer_ID = &userID
+	}
+
+	//optional date range
+	for name, target := range map[string]**time.Time{
+		"from": &filter.From,
+		"to":   &filter.To,
+	} {
+		if value := query.Get(name); value != "" {
+			date, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				util.WriteError(w, http.StatusBadRequest, errors.New("invalid "+name+" date"))
+				return
+			}
+			*target = &date
+		}
+	}
+	if filter.From != nil && filter.To != nil && filter.To.Before(*filter.From) {
+		util.WriteError(w, http.StatusBadRequest, errors.New("to date must not be before from date"))
+		return
+	}
+
+	//check cursor
+	if cursorParam := query.Get("cursor"); cursorParam != "" {
+		decodedCursor, err := cursor.DecodeRankCursor(cursorParam)
+		if err != nil {
+			util.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		filter.Cursor = decodedCursor
+	}
+
+	resultArr, err := h.search.SearchPosts(ctx, h.db, filter)
+	if errors.Is(err, search.ErrEmptyQuery) {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var nextCursor *string
+	if len(resultArr) > limitQuery {
+		last := resultArr[limitQuery-1]
+		c, err := cursor.EncodeRankCursor(types.RankCursor{
+			Rank:    last.Rank,
+			Post_ID: last.Post_ID,
+		})
+		if err == nil {
+			nextCursor = &c
+		}
+		resultArr = resultArr[:limitQuery]
+	}
+
+	util.WriteJSON(w, http.StatusOK, map[string]any{
+		"result": resultArr,
+		"cursor": nextCursor,
+	})
+}
+
+// vote in a poll, once per user
+// returns the poll with its tallies
+func (h *Handler) VotePoll(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	//get post_id from params
+	postID := mux.Vars(r)["post_id"]
+	//convert postID to integer (check if valid integer)
+	postIDInt, err := strconv.Atoi(postID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	//get user_id from params
+	userID := mux.Vars(r)["user_id"]
+	//convert userID to integer (check if valid integer)
+	userIDInt, err := strconv.Atoi(userID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.PollVotePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid inputs"))
+		return
+	}
+
+	//archived or read-only topics, banned users and locked posts cannot get new votes
+	err = moderation.CheckCanContribute(ctx, h.db, postIDInt, userIDInt)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid post id"))
+		return
+	}
+	if moderation.IsLocked(err) {
+		util.WriteError(w, http.StatusLocked, err)
+		return
+	}
+	if moderation.IsForbidden(err) {
+		util.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	err = polls.Vote(ctx, tx, postIDInt, userIDInt, payload.Option_IDs)
+	if polls.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, polls.ErrNotPoll)
+		return
+	}
+	if polls.IsInvalidVote(err) {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if errors.Is(err, polls.ErrAlreadyVoted) {
+		util.WriteError(w, http.StatusConflict, err)
+		return
+	}
+	if errors.Is(err, polls.ErrPollClosed) {
+		util.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	poll, err := polls.Get(ctx, tx, postIDInt, userIDInt)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, poll)
+}
+
+// posts shown above the first page of a feed, pinned posts or announcements
+// where and order pick them, their placeholders start at $3
+func (h *Handler) getPinnedPosts(ctx context.Context, userID int, search string, where string, order string, args ...any) ([]types.PostSumVotesResult, error) {
+	rows, err := h.db.Query(ctx, `SELECT 
+		p.post_id,
+		p.post_url,
+		u.user_id, 
+		u.username, 
+		u.display_name,
+		i.image_name, 
+		t.topic_id, 
+		t.creator_id, 
+		t.topic_name, 
+		t.topic_url,
+		c.icon_name as category_icon, 
+		tags.tag_name, 
+		tags.icon_name as tag_icon, 
+		tags.description as tag_description, 
+		p.title, 
+		p.content, 
+		p.created_date,
+		`+moderation.FlairColumns+`,
+		p.post_type,
+		p.is_pinned,
+		p.announced_date IS NOT NULL AS is_announcement,
+		p.is_locked,
+		t.is_read_only AS is_topic_read_only,
+		`+nsfw.Columns+`,
+		`+polls.Column+`,
+		`+links.Column+`,
+		`+crossposts.Column+`,
+		to_char(p.edited_date, 'YYYY-MM-DD"T"HH24:MI:SS"Z"') AS edited_date,
+		p.edit_count,
+		p.deleted_by,
+		COALESCE(p.content_html, '') AS content_html,
+		pvv.post_vote_id as vote_id,
+		p.upvote_count AS num_of_upvotes,
+		p.downvote_count AS num_of_downvotes,
+		p.upvote_count - p.downvote_count AS sum_of_votes,
+		COALESCE(pvv.vote_type, 0) AS vote_status,
+		p.comment_count AS num_of_comments,
+		p.view_count,
+		p.viewer_count,
+		pb.post_bookmark_id as bookmark_id, 
+		CASE WHEN pb.post_id IS NULL THEN FALSE ELSE TRUE END AS is_bookmarked
+		FROM posts p 
+		LEFT JOIN tags ON tags.tag_id = p.tag_id
+		INNER JOIN users u ON u.user_id = p.author_id
+		INNER JOIN profile_image i ON u.image_id = i.image_id
+		INNER JOIN topics t ON t.topic_id = p.topic_id
+		INNER JOIN categories c ON t.category_id = c.category_id
+		LEFT JOIN posts_votes pvv ON p.post_id = pvv.post_id AND pvv.user_id = $1
+		LEFT JOIN posts_bookmarks pb ON pb.post_id = p.post_id AND pb.user_id = $1
+		WHERE LOWER(p.title) LIKE $2 AND p.status = 'published' AND p.deleted_date IS NULL`+nsfw.Visible+where+`
+		ORDER BY `+order,
+		append([]any{userID, search}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	postsArr := make([]types.PostSumVotesResult, 0)
+	for rows.Next() {
+		var post types.PostSumVotesResult
+		var created time.Time
+
+		if err := rows.Scan(&post.Post_ID, &post.Post_URL, &post.User_ID, &post.Username, &post.DisplayName, &post.User_Image, &post.Topic_ID, &post.Topic_User_ID, &post.Topic_Name, &post.Topic_URL, &post.Category_Icon, &post.Tag_Name, &post.Tag_Icon, &post.Tag_Description,
+			&post.Title, &post.Content, &created, &post.Post_Flair, &post.User_Flair, &post.Post_Type, &post.Is_Pinned, &post.Is_Announcement, &post.Is_Locked, &post.Is_Topic_Read_Only, &post.Is_NSFW, &post.Is_Spoiler, &post.Blur_NSFW, &post.Blur_Spoiler, &post.Poll, &post.Link, &post.Crosspost_Of_ID, &post.Crosspost_Of, &post.Crosspost_Count, &post.Edited_Date, &post.Edit_Count, &post.Deleted_By, &post.Content_HTML, &post.Vote_ID, &post.Upvote_Count, &post.Downvote_Count, &post.Sum_Votes, &post.Vote_Status, &post.Comment_Count, &post.View_Count, &post.Viewer_Count, &post.Bookmark_ID, &post.Is_Bookmarked); err != nil {
+			return nil, err
+		}
+
+		post.Created_Date = created.Format(time.RFC3339)
+		postsArr = append(postsArr, post)
+	}
+	return postsArr, rows.Err()
+}
+
+// Make a post a site-wide announcement or stop it being one, admins only
+func (h *Handler) AnnouncePost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	//get post_id from params
+	postID := mux.Vars(r)["post_id"]
+	//convert postID to integer (check if valid integer)
+	postIDInt, err := strconv.Atoi(postID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	//get user_id from params
+	userID := mux.Vars(r)["user_id"]
+	//convert userID to integer (check if valid integer)
+	userIDInt, err := strconv.Atoi(userID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.TogglePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid inputs"))
+		return
+	}
+
+	isAdmin, err := moderation.IsAdmin(ctx, h.db, userIDInt)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !isAdmin {
+		util.WriteError(w, http.StatusForbidden, moderation.ErrNotAdmin)
+		return
+	}
+
+	topicID, err := moderation.TopicIDByPublishedPost(ctx, h.db, postIDInt)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid post id"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	if err := pins.Announce(ctx, tx, postIDInt, payload.Value); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	//the topic's moderators see it in their log
+	action := moderation.ActionUnannouncePost
+	if payload.Value {
+		action = moderation.ActionAnnouncePost
+	}
+	if err := moderation.Log(ctx, tx, types.ModerationLogEntry{
+		Topic_ID:       topicID,
+		Moderator_ID:   &userIDInt,
+		Action:         action,
+		Target_Post_ID: &postIDInt,
+	}); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, map[string]any{
+		"post_id":         postIDInt,
+		"is_announcement": payload.Value,
+	})
+}
+
+// Crosspost a post into another topic
+// the crosspost embeds the original and has its own votes and comments
+func (h *Handler) Crosspost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	//get post_id from params
+	postID := mux.Vars(r)["post_id"]
+	//convert postID to integer (check if valid integer)
+	postIDInt, err := strconv.Atoi(postID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	//get topic_id from params
+	topicID := mux.Vars(r)["topic_id"]
+	//convert topicID to integer (check if valid integer)
+	topicIDInt, err := strconv.Atoi(topicID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	//get user_id from params
+	userID := mux.Vars(r)["user_id"]
+	//convert userID to integer (check if valid integer)
+	userIDInt, err := strconv.Atoi(userID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.CrosspostPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid inputs"))
+		return
+	}
+
+	original, err := crossposts.GetOriginal(ctx, h.db, postIDInt)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid post id"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if original.Topic_ID == topicIDInt {
+		util.WriteError(w, http.StatusBadRequest, crossposts.ErrSameTopic)
+		return
+	}
+	if payload.Title == "" {
+		payload.Title = original.Title
+	}
+
+	//the user must be able to post in the topic it goes to
+	err = moderation.CheckCanParticipate(ctx, h.db, topicIDInt, userIDInt)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid topic id"))
+		return
+	}
+	if moderation.IsLocked(err) {
+		util.WriteError(w, http.StatusLocked, err)
+		return
+	}
+	if moderation.IsForbidden(err) {
+		util.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.checkPostFlair(ctx, topicIDInt, payload.Post_Flair_ID, true); err != nil {
+		if errors.Is(err, moderation.ErrInvalidFlair) || errors.Is(err, moderation.ErrFlairNeeded) {
+			util.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	//the crosspost has no content of its own, the original is embedded when it is read
+	//it stays nsfw when the original or the topic it goes to is
+	var Post_ID int
+	for attempt := 1; ; attempt++ {
+		postURL, err := slug.ForPost(ctx, h.db, payload.Title)
+		if err != nil {
+			util.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		err = h.db.QueryRow(ctx,
+			`INSERT INTO posts (topic_id, author_id, tag_id, title, content, post_url, post_flair_id, status,
+			content_html, content_html_version, post_type, is_nsfw, is_spoiler, crosspost_of_id)
+			VALUES ($1, $2, $3, $4, '', $5, $6, $7, '', $8, $9,
+			$10 OR (SELECT is_nsfw FROM topics WHERE topic_id = $1), $11, $12) RETURNING post_id`,
+			topicIDInt, userIDInt, payload.Tag_ID, payload.Title, postURL, payload.Post_Flair_ID, publishing.StatusPublished,
+			markdown.Version, polls.PostTypeText, original.Is_NSFW, original.Is_Spoiler, original.Post_ID,
+		).Scan(&Post_ID)
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
+			if pgErr.ConstraintName == crossposts.UniqueIndex {
+				util.WriteError(w, http.StatusConflict, crossposts.ErrAlreadyCrossposted)
+				return
+			}
+			if attempt < slug.InsertAttempts {
+				continue
+			}
+			util.WriteError(w, http.StatusConflict, slug.ErrTaken)
+			return
+		}
+
+		//server error
+		if err != nil {
+			util.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		break
+	}
+
+	result, err := h.getAddedPost(ctx, userIDInt, Post_ID)
+	//server error
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, result)
+}
+
+// Get posts to show under a post, related by tag, topic and title
+// posts the user has voted on are left out
+func (h *Handler) GetRelatedPosts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//get post_id from params
+	postID := mux.Vars(r)["post_id"]
+	//convert postID to integer (check if valid integer)
+	postIDInt, err := strconv.Atoi(postID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	//get user_id from params
+	userID := mux.Vars(r)["user_id"]
+	//convert userID to integer (check if valid integer)
+	userIDInt, err := strconv.Atoi(userID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limitQuery := related.DefaultLimit
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		limitQuery, err = strconv.Atoi(limit)
+		if err != nil || limitQuery < 1 || limitQuery > related.MaxLimit {
+			util.WriteError(w, http.StatusBadRequest, errors.New("limit must be between 1 and 20"))
+			return
+		}
+	}
+
+	postsArr, err := related.Posts(ctx, h.db, postIDInt, userIDInt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid post id"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	relatedArr, moreFromTopicArr := related.Split(postsArr, limitQuery)
+	util.WriteJSON(w, http.StatusOK, map[string]any{
+		"related":         relatedArr,
+		"more_from_topic": moreFromTopicArr,
+	})
+}
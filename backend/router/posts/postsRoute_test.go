@@ -0,0 +1,83 @@
+package postsRouter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/minrui13/backend/database/dbtest"
+	"github.com/minrui13/backend/views"
+)
+
+func servePost(h *Handler, path string) *httptest.ResponseRecorder {
+	r := mux.NewRouter()
+	h.Router(r)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+	return w
+}
+
+func TestGetPostByIDRejectsBadIDs(t *testing.T) {
+	h := NewHandler(nil, nil, views.NewRecorder(), nil, nil)
+	for _, path := range []string{"/getPostByID/abc/1", "/getPostByID/1/abc"} {
+		if w := servePost(h, path); w.Code != http.StatusBadRequest {
+			t.Errorf("POST %s = %d, want %d", path, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestGetPostByID(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+	recorder := views.NewRecorder()
+	h := NewHandler(pool, nil, recorder, nil, nil)
+
+	if w := servePost(h, "/getPostByID/-1/0"); w.Code != http.StatusNotFound {
+		t.Fatalf("POST a missing post = %d %s, want %d", w.Code, w.Body, http.StatusNotFound)
+	}
+
+	var postID, viewCount int
+	err := pool.QueryRow(ctx,
+		`SELECT post_id, view_count FROM posts WHERE status = 'published' AND deleted_date IS NULL ORDER BY post_id LIMIT 1`).
+		Scan(&postID, &viewCount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		t.Skip("the test database has no published posts")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := servePost(h, fmt.Sprintf("/getPostByID/%d/0", postID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST getPostByID = %d %s, want %d", w.Code, w.Body, http.StatusOK)
+	}
+	var post struct {
+		Post_ID int `json:"post_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &post); err != nil || post.Post_ID != postID {
+		t.Fatalf("getPostByID returned %s, want post %d", w.Body, postID)
+	}
+
+	//the anonymous view is counted, in a transaction that is rolled back
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback(ctx)
+	if err := recorder.Flush(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	var counted int
+	if err := tx.QueryRow(ctx, `SELECT view_count FROM posts WHERE post_id = $1`, postID).Scan(&counted); err != nil {
+		t.Fatal(err)
+	}
+	if counted != viewCount+1 {
+		t.Errorf("view count after opening the post = %d, want %d", counted, viewCount+1)
+	}
+}
@@ -0,0 +1,71 @@
+package shareRouter
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minrui13/backend/config"
+	"github.com/minrui13/backend/urlhistory"
+	"github.com/minrui13/backend/util"
+)
+
+type Handler struct {
+	db *pgxpool.Pool
+}
+
+func NewHandler(db *pgxpool.Pool) *Handler {
+	return &Handler{db: db}
+}
+
+// share links live outside /api and send browsers to the frontend page
+func (h *Handler) Router(r *mux.Router) *mux.Router {
+	//Redirect to topic page
+	r.HandleFunc("/topic/{topic_url}", h.ShareTopic).Methods("GET")
+	//Redirect to post page
+	r.HandleFunc("/post/{post_url}", h.SharePost).Methods("GET")
+
+	return r
+}
+
+// 301 to the current topic page, also for urls from before a rename
+func (h *Handler) ShareTopic(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	topicURL, err := urlhistory.ResolveTopicURL(ctx, h.db, mux.Vars(r)["topic_url"])
+	if errors.Is(err, sql.ErrNoRows) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid topic url"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	redirect(w, r, urlhistory.TopicPath(topicURL))
+}
+
+// 301 to the current post page, also for urls from before a rename
+func (h *Handler) SharePost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	postURL, err := urlhistory.ResolvePostURL(ctx, h.db, mux.Vars(r)["post_url"])
+	if errors.Is(err, sql.ErrNoRows) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid post url"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	redirect(w, r, urlhistory.PostPath(postURL))
+}
+
+// FRONTEND_URL is empty when the frontend is served from the same host
+func redirect(w http.ResponseWriter, r *http.Request, path string) {
+	http.Redirect(w, r, strings.TrimSuffix(config.Envs.FRONTEND_URL, "/")+path, http.StatusMovedPermanently)
+}
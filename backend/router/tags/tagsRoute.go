@@ -1,10 +1,13 @@
 package tagsRoute
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minrui13/backend/trending"
 	"github.com/minrui13/backend/types"
 	"github.com/minrui13/backend/util"
 )
@@ -20,6 +23,8 @@ func NewHandler(db *pgxpool.Pool) *Handler {
 func (h *Handler) Router(r *mux.Router) *mux.Router {
 	//Get all tags
 	r.HandleFunc("/", h.GetAllTags).Methods("Get")
+	//Get tags used and voted on faster than usual
+	r.HandleFunc("/trendingTags", h.GetTrendingTags).Methods("GET")
 
 	return r
 }
@@ -57,3 +62,26 @@ func (h *Handler) GetAllTags(w http.ResponseWriter, r *http.Request) {
 
 	util.WriteJSON(w, http.StatusOK, tagArr)
 }
+
+// Get trending tags
+func (h *Handler) GetTrendingTags(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	limitQuery := trending.DefaultLimit
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		var err error
+		limitQuery, err = strconv.Atoi(limit)
+		if err != nil || limitQuery < 1 || limitQuery > trending.MaxLimit {
+			util.WriteError(w, http.StatusBadRequest, errors.New("limit must be between 1 and 50"))
+			return
+		}
+	}
+
+	tagsArr, err := trending.Tags(ctx, h.db, limitQuery)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, tagsArr)
+}
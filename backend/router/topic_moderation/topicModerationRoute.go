@@ -0,0 +1,1930 @@
+package topicModerationRouter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minrui13/backend/cursor"
+	db "github.com/minrui13/backend/database"
+	"github.com/minrui13/backend/moderation"
+	"github.com/minrui13/backend/pins"
+	"github.com/minrui13/backend/types"
+	"github.com/minrui13/backend/util"
+)
+
+type Handler struct {
+	db *pgxpool.Pool
+}
+
+func NewHandler(db *pgxpool.Pool) *Handler {
+	return &Handler{db: db}
+}
+
+func (h *Handler) Router(r *mux.Router) *mux.Router {
+	//Get moderators of a topic (creator included)
+	r.HandleFunc("/getModerators/{topic_id}", h.GetModerators).Methods("POST")
+	//Appoint a moderator (topic creator only)
+	r.HandleFunc("/addModerator/{topic_id}/{user_id}", h.AddModerator).Methods("POST")
+	//Remove a moderator (topic creator only)
+	r.HandleFunc("/removeModerator/{topic_id}/{user_id}/{target_user_id}", h.RemoveModerator).Methods("DELETE")
+	//Remove a post in the topic
+	r.HandleFunc("/removePost/{user_id}/{post_id}", h.RemovePost).Methods("DELETE")
+	//Restore a deleted post
+	r.HandleFunc("/restorePost/{user_id}/{post_id}", h.RestorePost).Methods("PUT")
+	//Remove a comment in the topic
+	r.HandleFunc("/removeComment/{user_id}/{comment_id}", h.RemoveComment).Methods("DELETE")
+	//Lock or unlock a post
+	r.HandleFunc("/lockPost/{user_id}/{post_id}", h.LockPost).Methods("PUT")
+	//Pin or unpin a post
+	r.HandleFunc("/pinPost/{user_id}/{post_id}", h.PinPost).Methods("PUT")
+	//Lock or unlock a comment and its replies
+	r.HandleFunc("/lockComment/{user_id}/{comment_id}", h.LockComment).Methods("PUT")
+	//Mark or unmark a post as nsfw
+	r.HandleFunc("/markNSFW/{user_id}/{post_id}", h.MarkNSFW).Methods("PUT")
+	//Mark or unmark a post as a spoiler
+	r.HandleFunc("/markSpoiler/{user_id}/{post_id}", h.MarkSpoiler).Methods("PUT")
+	//Make a topic read-only or writable again
+	r.HandleFunc("/setReadOnly/{topic_id}/{user_id}", h.SetReadOnly).Methods("PUT")
+	//Make new posts of a topic nsfw by default or not
+	r.HandleFunc("/setNSFW/{topic_id}/{user_id}", h.SetNSFW).Methods("PUT")
+	//Ban user from the topic
+	r.HandleFunc("/banUser/{topic_id}/{user_id}", h.BanUser).Methods("POST")
+	//Lift ban
+	r.HandleFunc("/unbanUser/{topic_id}/{user_id}/{target_user_id}", h.UnbanUser).Methods("DELETE")
+	//Get active bans
+	r.HandleFunc("/getBans/{topic_id}/{user_id}", h.GetBans).Methods("POST")
+	//Get moderation log
+	r.HandleFunc("/getModerationLog/{topic_id}/{user_id}", h.GetModerationLog).Methods("POST")
+	//Get topic rules
+	r.HandleFunc("/getRules/{topic_id}", h.GetRules).Methods("POST")
+	//Add topic rule
+	r.HandleFunc("/addRule/{topic_id}/{user_id}", h.AddRule).Methods("POST")
+	//Update topic rule
+	r.HandleFunc("/updateRule/{rule_id}/{user_id}", h.UpdateRule).Methods("PUT")
+	//Delete topic rule
+	r.HandleFunc("/deleteRule/{rule_id}/{user_id}", h.DeleteRule).Methods("DELETE")
+	//Reorder topic rules
+	r.HandleFunc("/reorderRules/{topic_id}/{user_id}", h.ReorderRules).Methods("PUT")
+	//Get user and post flairs
+	r.HandleFunc("/getFlairs/{topic_id}", h.GetFlairs).Methods("POST")
+	//Add flair
+	r.HandleFunc("/addFlair/{topic_id}/{user_id}", h.AddFlair).Methods("POST")
+	//Update flair
+	r.HandleFunc("/updateFlair/{flair_id}/{user_id}", h.UpdateFlair).Methods("PUT")
+	//Delete flair
+	r.HandleFunc("/deleteFlair/{flair_id}/{user_id}", h.DeleteFlair).Methods("DELETE")
+	//Update sidebar, banner and post flair requirement
+	r.HandleFunc("/updateSidebar/{topic_id}/{user_id}", h.UpdateSidebar).Methods("PUT")
+
+	return r
+}
+
+// get integer from params
+func paramInt(r *http.Request, key string) (int, error) {
+	value, err := strconv.Atoi(mux.Vars(r)[key])
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s", key)
+	}
+	return value, nil
+}
+
+// write 403 if the user is not a moderator of the topic
+// returns false when the request should stop
+func (h *Handler) checkModerator(ctx context.Context, w http.ResponseWriter, topicID int, userID int) bool {
+	isModerator, err := moderation.IsTopicModerator(ctx, h.db, topicID, userID)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return false
+	}
+	if !isModerator {
+		util.WriteError(w, http.StatusForbidden, moderation.ErrNotModerator)
+		return false
+	}
+	return true
+}
+
+// Get moderators of a topic
+func (h *Handler) GetModerators(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	topicID, err := paramInt(r, "topic_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	//creator first, then appointed moderators by appointment date
+	rows, err := h.db.Query(ctx, `
+		SELECT t.topic_id, u.user_id, u.username, u.display_name, i.image_name, NULL::INT AS appointed_by, TRUE AS is_creator, t.created_date
+		FROM topics t
+		INNER JOIN users u ON u.user_id = t.creator_id
+		INNER JOIN profile_image i ON i.image_id = u.image_id
+		WHERE t.topic_id = $1
+		UNION ALL
+		SELECT tm.topic_id, u.user_id, u.username, u.display_name, i.image_name, tm.appointed_by, FALSE AS is_creator, tm.created_date
+		FROM topics_moderators tm
+		INNER JOIN users u ON u.user_id = tm.user_id
+		INNER JOIN profile_image i ON i.image_id = u.image_id
+		WHERE tm.topic_id = $1
+		ORDER BY is_creator DESC, created_date ASC`, topicID)
+
+	//database error 500 status code
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	moderatorsArr := make([]types.TopicModerator, 0)
+	for rows.Next() {
+		var moderator types.TopicModerator
+		var displayName *string
+		var created time.Time
+
+		if err := rows.Scan(&moderator.Topic_ID, &moderator.User_ID, &moderator.Username, &displayName, &moderator.Image_Name,
+			&moderator.Appointed_By, &moderator.Is_Creator, &created); err != nil {
+			util.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if displayName != nil {
+			moderator.Display_Name = *displayName
+		}
+		moderator.Created_Date = created.Format(time.RFC3339)
+		moderatorsArr = append(moderatorsArr, moderator)
+	}
+
+	if err := rows.Err(); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, moderatorsArr)
+}
+
+// Appoint a moderator
+func (h *Handler) AddModerator(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	topicID, err := paramInt(r, "topic_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	userID, err := paramInt(r, "user_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.TargetUserPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Target_User_ID == 0 {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid inputs"))
+		return
+	}
+
+	//only the creator can appoint moderators
+	isOwner, err := moderation.IsTopicOwner(ctx, h.db, topicID, userID)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !isOwner {
+		util.WriteError(w, http.StatusForbidden, moderation.ErrNotOwner)
+		return
+	}
+
+	if payload.Target_User_ID == userID {
+		util.WriteError(w, http.StatusBadRequest, errors.New("topic creator is already a moderator"))
+		return
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO topics_moderators (topic_id, user_id, appointed_by) VALUES ($1, $2, $3)`,
+		topicID, payload.Target_User_ID, userID)
+
+	//already a moderator
+	if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
+		util.WriteError(w, http.StatusConflict, errors.New("user is already a moderator"))
+		return
+	}
+	//user does not exist
+	if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23503" {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid user id"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := moderation.Log(ctx, tx, types.ModerationLogEntry{
+		Topic_ID:       topicID,
+		Moderator_ID:   &userID,
+		Action:         moderation.ActionAddModerator,
+		Target_User_ID: &payload.Target_User_ID,
+	}); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, map[string]int{
+		"topic_id": topicID,
+		"user_id":  payload.Target_User_ID,
+	})
+}
+
+// Remove a moderator
+func (h *Handler) RemoveModerator(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	topicID, err := paramInt(r, "topic_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	userID, err := paramInt(r, "user_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	targetUserID, err := paramInt(r, "target_user_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	//creator can remove anyone, moderators can only step down themselves
+	if userID != targetUserID {
+		isOwner, err := moderation.IsTopicOwner(ctx, h.db, topicID, userID)
+		if err != nil {
+			util.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !isOwner {
+			util.WriteError(w, http.StatusForbidden, moderation.ErrNotOwner)
+			return
+		}
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	response, err := tx.Exec(ctx,
+		`DELETE FROM topics_moderators WHERE topic_id = $1 AND user_id = $2`,
+		topicID, targetUserID)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if response.RowsAffected() == 0 {
+		util.WriteError(w, http.StatusNotFound, errors.New("moderator not found"))
+		return
+	}
+
+	if err := moderation.Log(ctx, tx, types.ModerationLogEntry{
+		Topic_ID:       topicID,
+		Moderator_ID:   &userID,
+		Action:         moderation.ActionRemoveModerator,
+		Target_User_ID: &targetUserID,
+	}); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, map[string]int{
+		"topic_id": topicID,
+		"user_id":  targetUserID,
+	})
+}
+
+// Remove a post as a moderator
+func (h *Handler) RemovePost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	userID, err := paramInt(r, "user_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	postID, err := paramInt(r, "post_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	topicID, err := moderation.TopicIDByPost(ctx, h.db, postID)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid post id"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !h.checkModerator(ctx, w, topicID, userID) {
+		return
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	//keep the author and title in the log since the post is purged later
+	var authorID int
+	var title string
+	err = tx.QueryRow(ctx,
+		`UPDATE posts SET deleted_date = CURRENT_TIMESTAMP, deleted_by = 'moderator', deleted_by_user_id = $2
+		WHERE post_id = $1 AND deleted_date IS NULL
+		RETURNING author_id, title`,
+		postID, userID).Scan(&authorID, &title)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusConflict, errors.New("post is already deleted"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := moderation.Log(ctx, tx, types.ModerationLogEntry{
+		Topic_ID:       topicID,
+		Moderator_ID:   &userID,
+		Action:         moderation.ActionRemovePost,
+		Target_User_ID: &authorID,
+		Target_Post_ID: &postID,
+		Details:        &title,
+	}); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, map[string]int{
+		"post_id": postID,
+	})
+}
+
+// Restore a post deleted by its author or removed by a moderator
+func (h *Handler) RestorePost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	userID, err := paramInt(r, "user_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	postID, err := paramInt(r, "post_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	topicID, err := moderation.TopicIDByPost(ctx, h.db, postID)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid post id"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !h.checkModerator(ctx, w, topicID, userID) {
+		return
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var authorID int
+	var deletedBy string
+	err = tx.QueryRow(ctx,
+		`UPDATE posts p SET deleted_date = NULL, deleted_by = NULL, deleted_by_user_id = NULL
+		FROM (SELECT post_id, deleted_by FROM posts WHERE post_id = $1 AND deleted_date IS NOT NULL FOR UPDATE) AS old
+		WHERE p.post_id = old.post_id
+		RETURNING p.author_id, old.deleted_by`,
+		postID).Scan(&authorID, &deletedBy)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusConflict, errors.New("post is not deleted"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	details := "deleted by " + deletedBy
+	if err := moderation.Log(ctx, tx, types.ModerationLogEntry{
+		Topic_ID:       topicID,
+		Moderator_ID:   &userID,
+		Action:         moderation.ActionRestorePost,
+		Target_User_ID: &authorID,
+		Target_Post_ID: &postID,
+		Details:        &details,
+	}); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, map[string]int{
+		"post_id": postID,
+	})
+}
+
+// Remove a comment as a moderator
+func (h *Handler) RemoveComment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	userID, err := paramInt(r, "user_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	commentID, err := paramInt(r, "comment_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	topicID, err := moderation.TopicIDByComment(ctx, h.db, commentID)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid comment id"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !h.checkModerator(ctx, w, topicID, userID) {
+		return
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var authorID, postID int
+	var content string
+	err = tx.QueryRow(ctx,
+		`DELETE FROM posts_comments WHERE comment_id = $1 RETURNING user_id, post_id, content`,
+		commentID).Scan(&authorID, &postID, &content)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := moderation.Log(ctx, tx, types.ModerationLogEntry{
+		Topic_ID:          topicID,
+		Moderator_ID:      &userID,
+		Action:            moderation.ActionRemoveComment,
+		Target_User_ID:    &authorID,
+		Target_Post_ID:    &postID,
+		Target_Comment_ID: &commentID,
+		Details:           &content,
+	}); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, map[string]int{
+		"comment_id": commentID,
+	})
+}
+
+// Lock or unlock a post
+func (h *Handler) LockPost(w http.ResponseWriter, r *http.Request) {
+	h.togglePost(w, r, "is_locked", moderation.ActionLockPost, moderation.ActionUnlockPost, nil)
+}
+
+// Pin or unpin a post, up to pins.MaxPerTopic per topic
+func (h *Handler) PinPost(w http.ResponseWriter, r *http.Request) {
+	h.togglePost(w, r, "is_pinned", moderation.ActionPinPost, moderation.ActionUnpinPost, pins.Pin)
+}
+
+// Mark or unmark a post as nsfw
+func (h *Handler) MarkNSFW(w http.ResponseWriter, r *http.Request) {
+	h.togglePost(w, r, "is_nsfw", moderation.ActionMarkNSFW, moderation.ActionUnmarkNSFW, nil)
+}
+
+// Mark or unmark a post as a spoiler
+func (h *Handler) MarkSpoiler(w http.ResponseWriter, r *http.Request) {
+	h.togglePost(w, r, "is_spoiler", moderation.ActionMarkSpoiler, moderation.ActionUnmarkSpoiler, nil)
+}
+
+// set a boolean post column and log it
+// column is never user input
+// after runs in the same transaction once the column is set, nil if there is nothing else to do
+func (h *Handler) togglePost(w http.ResponseWriter, r *http.Request, column string, onAction string, offAction string,
+	after func(ctx context.Context, q db.Querier, topicID int, postID int, value bool) error) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	userID, err := paramInt(r, "user_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	postID, err := paramInt(r, "post_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.TogglePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid inputs"))
+		return
+	}
+
+	topicID, err := moderation.TopicIDByPost(ctx, h.db, postID)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid post id"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !h.checkModerator(ctx, w, topicID, userID) {
+		return
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var authorID int
+	err = tx.QueryRow(ctx,
+		`UPDATE posts SET `+column+` = $1 WHERE post_id = $2 RETURNING author_id`,
+		payload.Value, postID).Scan(&authorID)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if after != nil {
+		err = after(ctx, tx, topicID, postID, payload.Value)
+		if errors.Is(err, pins.ErrTooManyPins) {
+			util.WriteError(w, http.StatusConflict, err)
+			return
+		}
+		if err != nil {
+			util.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	action := offAction
+	if payload.Value {
+		action = onAction
+	}
+	if err := moderation.Log(ctx, tx, types.ModerationLogEntry{
+		Topic_ID:       topicID,
+		Moderator_ID:   &userID,
+		Action:         action,
+		Target_User_ID: &authorID,
+		Target_Post_ID: &postID,
+	}); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, map[string]any{
+		"post_id": postID,
+		column:    payload.Value,
+	})
+}
+
+// Lock or unlock a comment, replies under a locked comment cannot get new replies or votes
+func (h *Handler) LockComment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	userID, err := paramInt(r, "user_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	commentID, err := paramInt(r, "comment_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.TogglePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid inputs"))
+		return
+	}
+
+	topicID, err := moderation.TopicIDByComment(ctx, h.db, commentID)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid comment id"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !h.checkModerator(ctx, w, topicID, userID) {
+		return
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var authorID, postID int
+	err = tx.QueryRow(ctx,
+		`UPDATE posts_comments SET is_locked = $1 WHERE comment_id = $2 RETURNING user_id, post_id`,
+		payload.Value, commentID).Scan(&authorID, &postID)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	action := moderation.ActionUnlockComment
+	if payload.Value {
+		action = moderation.ActionLockComment
+	}
+	if err := moderation.Log(ctx, tx, types.ModerationLogEntry{
+		Topic_ID:          topicID,
+		Moderator_ID:      &userID,
+		Action:            action,
+		Target_User_ID:    &authorID,
+		Target_Post_ID:    &postID,
+		Target_Comment_ID: &commentID,
+	}); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, map[string]any{
+		"comment_id": commentID,
+		"is_locked":  payload.Value,
+	})
+}
+
+// Ban a user from the topic
+func (h *Handler) BanUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	topicID, err := paramInt(r, "topic_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	userID, err := paramInt(r, "user_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.TopicBanPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Target_User_ID == 0 {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid inputs"))
+		return
+	}
+	if payload.Duration_Hours != nil && (*payload.Duration_Hours <= 0 || *payload.Duration_Hours > moderation.MaxBanHours) {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid ban duration"))
+		return
+	}
+
+	if !h.checkModerator(ctx, w, topicID, userID) {
+		return
+	}
+
+	//moderators cannot ban each other or the creator
+	targetIsModerator, err := moderation.IsTopicModerator(ctx, h.db, topicID, payload.Target_User_ID)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if targetIsModerator {
+		util.WriteError(w, http.StatusBadRequest, errors.New("cannot ban a moderator"))
+		return
+	}
+
+	details := "permanent"
+	if payload.Duration_Hours != nil {
+		details = fmt.Sprintf("%d hours", *payload.Duration_Hours)
+	}
+	if payload.Reason != nil && *payload.Reason != "" {
+		details += ": " + *payload.Reason
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	//banning again replaces the previous ban
+	//the expiry is taken from the database clock that IsBannedFromTopic compares it with, null is permanent
+	var banID int
+	err = tx.QueryRow(ctx,
+		`INSERT INTO topics_bans (topic_id, user_id, banned_by, reason, expires_date)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP + make_interval(hours => $5::int))
+		ON CONFLICT (topic_id, user_id) DO UPDATE
+		SET banned_by = EXCLUDED.banned_by, reason = EXCLUDED.reason,
+		created_date = current_timestamp, expires_date = EXCLUDED.expires_date
+		RETURNING topic_ban_id`,
+		topicID, payload.Target_User_ID, userID, payload.Reason, payload.Duration_Hours).Scan(&banID)
+
+	if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23503" {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid user id"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := moderation.Log(ctx, tx, types.ModerationLogEntry{
+		Topic_ID:       topicID,
+		Moderator_ID:   &userID,
+		Action:         moderation.ActionBanUser,
+		Target_User_ID: &payload.Target_User_ID,
+		Details:        &details,
+	}); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, map[string]int{
+		"topic_ban_id": banID,
+	})
+}
+
+// Lift a ban
+func (h *Handler) UnbanUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	topicID, err := paramInt(r, "topic_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	userID, err := paramInt(r, "user_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	targetUserID, err := paramInt(r, "target_user_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if !h.checkModerator(ctx, w, topicID, userID) {
+		return
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	response, err := tx.Exec(ctx,
+		`DELETE FROM topics_bans WHERE topic_id = $1 AND user_id = $2`,
+		topicID, targetUserID)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if response.RowsAffected() == 0 {
+		util.WriteError(w, http.StatusNotFound, errors.New("ban not found"))
+		return
+	}
+
+	if err := moderation.Log(ctx, tx, types.ModerationLogEntry{
+		Topic_ID:       topicID,
+		Moderator_ID:   &userID,
+		Action:         moderation.ActionUnbanUser,
+		Target_User_ID: &targetUserID,
+	}); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, map[string]int{
+		"topic_id": topicID,
+		"user_id":  targetUserID,
+	})
+}
+
+// Get active bans of a topic
+func (h *Handler) GetBans(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	topicID, err := paramInt(r, "topic_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	userID, err := paramInt(r, "user_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if !h.checkModerator(ctx, w, topicID, userID) {
+		return
+	}
+
+	rows, err := h.db.Query(ctx, `
+		SELECT tb.topic_ban_id, tb.topic_id, tb.user_id, u.username, tb.banned_by, tb.reason, tb.created_date, tb.expires_date
+		FROM topics_bans tb
+		INNER JOIN users u ON u.user_id = tb.user_id
+		WHERE tb.topic_id = $1 AND (tb.expires_date IS NULL OR tb.expires_date > current_timestamp)
+		ORDER BY tb.created_date DESC`, topicID)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	bansArr := make([]types.TopicBan, 0)
+	for rows.Next() {
+		var ban types.TopicBan
+		var created time.Time
+		var expires *time.Time
+
+		if err := rows.Scan(&ban.Topic_Ban_ID, &ban.Topic_ID, &ban.User_ID, &ban.Username, &ban.Banned_By, &ban.Reason, &created, &expires); err != nil {
+			util.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		ban.Created_Date = created.Format(time.RFC3339)
+		if expires != nil {
+			e := expires.Format(time.RFC3339)
+			ban.Expires_Date = &e
+		}
+		bansArr = append(bansArr, ban)
+	}
+
+	if err := rows.Err(); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, bansArr)
+}
+
+// Get moderation log, newest first
+func (h *Handler) GetModerationLog(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+	limit := query.Get("limit")
+	cursorParam := query.Get("cursor")
+
+	topicID, err := paramInt(r, "topic_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	userID, err := paramInt(r, "user_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	//convert limitQuery to integer (check if valid integer)
+	limitQuery, err := strconv.Atoi(limit)
+	//check if limit is an integer within the page size
+	if err != nil || limitQuery < 1 || limitQuery > 50 {
+		util.WriteError(w, http.StatusBadRequest, errors.New("limit must be between 1 and 50"))
+		return
+	}
+	//add one for later on to check if there is more logs
+	limitAddOne := limitQuery + 1
+
+	if !h.checkModerator(ctx, w, topicID, userID) {
+		return
+	}
+
+	baseSQLStatement := `SELECT
+		l.log_id, l.topic_id, l.moderator_id, u.username, l.action,
+		l.target_user_id, l.target_post_id, l.target_comment_id, l.details, l.created_date
+		FROM topics_moderation_log l
+		LEFT JOIN users u ON u.user_id = l.moderator_id
+		WHERE l.topic_id = $1
+		`
+
+	var rows pgx.Rows
+	if cursorParam == "" {
+		rows, err = h.db.Query(ctx, baseSQLStatement+` ORDER BY l.created_date DESC, l.log_id DESC LIMIT $2`,
+			topicID, limitAddOne)
+	} else {
+		d, decodeErr := cursor.DecodeDateIDCursor(cursorParam)
+		if decodeErr != nil {
+			util.WriteError(w, http.StatusBadRequest, decodeErr)
+			return
+		}
+		rows, err = h.db.Query(ctx, baseSQLStatement+`
+			AND (l.created_date < $2 OR (l.created_date = $2 AND l.log_id < $3))
+			ORDER BY l.created_date DESC, l.log_id DESC LIMIT $4`,
+			topicID, d.Created_Date, d.ID, limitAddOne)
+	}
+
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer rows.Close()
+
+	logsArr := make([]types.ModerationLogEntry, 0)
+	for rows.Next() {
+		var entry types.ModerationLogEntry
+		var created time.Time
+
+		if err := rows.Scan(&entry.Log_ID, &entry.Topic_ID, &entry.Moderator_ID, &entry.Moderator_Username, &entry.Action,
+			&entry.Target_User_ID, &entry.Target_Post_ID, &entry.Target_Comment_ID, &entry.Details, &created); err != nil {
+			util.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		entry.Created_Date = created.Format(time.RFC3339Nano)
+		logsArr = append(logsArr, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var nextCursor *string
+	if len(logsArr) > limitQuery {
+		last := logsArr[limitQuery-1]
+		c, err := cursor.EncodeDateIDCursor(types.DateIDCursor{
+			Created_Date: last.Created_Date,
+			ID:           last.Log_ID,
+		})
+		if err != nil {
+			util.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		nextCursor = &c
+		logsArr = logsArr[:limitQuery]
+	}
+
+	util.WriteJSON(w, http.StatusOK, map[string]any{
+		"result": logsArr,
+		"cursor": nextCursor,
+	})
+}
+
+// Get topic rules in display order
+func (h *Handler) GetRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	topicID, err := paramInt(r, "topic_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	rulesArr, err := moderation.GetTopicRules(ctx, h.db, topicID)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, rulesArr)
+}
+
+// Add a topic rule
+func (h *Handler) AddRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	topicID, err := paramInt(r, "topic_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	userID, err := paramInt(r, "user_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.TopicRulePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Title == "" {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid inputs"))
+		return
+	}
+
+	if !h.checkModerator(ctx, w, topicID, userID) {
+		return
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var rule types.TopicRule
+	err = tx.QueryRow(ctx,
+		`INSERT INTO topics_rules (topic_id, position, title, body) VALUES ($1, $2, $3, $4)
+		RETURNING rule_id, topic_id, position, title, body`,
+		topicID, payload.Position, payload.Title, payload.Body).
+		Scan(&rule.Rule_ID, &rule.Topic_ID, &rule.Position, &rule.Title, &rule.Body)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := moderation.Log(ctx, tx, types.ModerationLogEntry{
+		Topic_ID:     topicID,
+		Moderator_ID: &userID,
+		Action:       moderation.ActionAddRule,
+		Details:      &rule.Title,
+	}); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, rule)
+}
+
+// Update a topic rule
+func (h *Handler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	ruleID, err := paramInt(r, "rule_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	userID, err := paramInt(r, "user_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.TopicRulePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Title == "" {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid inputs"))
+		return
+	}
+
+	var topicID int
+	err = h.db.QueryRow(ctx, `SELECT topic_id FROM topics_rules WHERE rule_id = $1`, ruleID).Scan(&topicID)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid rule id"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !h.checkModerator(ctx, w, topicID, userID) {
+		return
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var rule types.TopicRule
+	err = tx.QueryRow(ctx,
+		`UPDATE topics_rules SET position = $1, title = $2, body = $3 WHERE rule_id = $4
+		RETURNING rule_id, topic_id, position, title, body`,
+		payload.Position, payload.Title, payload.Body, ruleID).
+		Scan(&rule.Rule_ID, &rule.Topic_ID, &rule.Position, &rule.Title, &rule.Body)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := moderation.Log(ctx, tx, types.ModerationLogEntry{
+		Topic_ID:     topicID,
+		Moderator_ID: &userID,
+		Action:       moderation.ActionUpdateRule,
+		Details:      &rule.Title,
+	}); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, rule)
+}
+
+// Delete a topic rule
+func (h *Handler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	ruleID, err := paramInt(r, "rule_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	userID, err := paramInt(r, "user_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var topicID int
+	err = h.db.QueryRow(ctx, `SELECT topic_id FROM topics_rules WHERE rule_id = $1`, ruleID).Scan(&topicID)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid rule id"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !h.checkModerator(ctx, w, topicID, userID) {
+		return
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var title string
+	err = tx.QueryRow(ctx, `DELETE FROM topics_rules WHERE rule_id = $1 RETURNING title`, ruleID).Scan(&title)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := moderation.Log(ctx, tx, types.ModerationLogEntry{
+		Topic_ID:     topicID,
+		Moderator_ID: &userID,
+		Action:       moderation.ActionDeleteRule,
+		Details:      &title,
+	}); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, map[string]int{
+		"rule_id": ruleID,
+	})
+}
+
+// Reorder rules, position follows the order of rule_ids
+func (h *Handler) ReorderRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	topicID, err := paramInt(r, "topic_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	userID, err := paramInt(r, "user_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.RuleOrderPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || len(payload.Rule_IDs) == 0 {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid inputs"))
+		return
+	}
+
+	if !h.checkModerator(ctx, w, topicID, userID) {
+		return
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	for position, ruleID := range payload.Rule_IDs {
+		response, err := tx.Exec(ctx,
+			`UPDATE topics_rules SET position = $1 WHERE rule_id = $2 AND topic_id = $3`,
+			position, ruleID, topicID)
+		if err != nil {
+			util.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		//rule from another topic
+		if response.RowsAffected() == 0 {
+			util.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid rule id %d", ruleID))
+			return
+		}
+	}
+
+	if err := moderation.Log(ctx, tx, types.ModerationLogEntry{
+		Topic_ID:     topicID,
+		Moderator_ID: &userID,
+		Action:       moderation.ActionReorderRules,
+	}); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	rulesArr, err := moderation.GetTopicRules(ctx, tx, topicID)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, rulesArr)
+}
+
+// Get user and post flairs of a topic
+func (h *Handler) GetFlairs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	topicID, err := paramInt(r, "topic_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	userFlairs, err := moderation.GetTopicFlairs(ctx, h.db, topicID, moderation.FlairKindUser)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	postFlairs, err := moderation.GetTopicFlairs(ctx, h.db, topicID, moderation.FlairKindPost)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, map[string]any{
+		"user_flairs": userFlairs,
+		"post_flairs": postFlairs,
+	})
+}
+
+// check flair inputs and fill in default colours
+func validateFlair(payload *types.TopicFlairPayload) error {
+	if payload.Kind != moderation.FlairKindUser && payload.Kind != moderation.FlairKindPost {
+		return errors.New("flair kind must be user or post")
+	}
+	if payload.Text == "" || len(payload.Text) > 64 {
+		return errors.New("flair text must be between 1 and 64 characters")
+	}
+	if payload.Background_Color == "" {
+		payload.Background_Color = "#e5e7eb"
+	}
+	if payload.Text_Color == "" {
+		payload.Text_Color = "#111827"
+	}
+	return nil
+}
+
+// Add a flair
+func (h *Handler) AddFlair(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	topicID, err := paramInt(r, "topic_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	userID, err := paramInt(r, "user_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.TopicFlairPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid inputs"))
+		return
+	}
+	if err := validateFlair(&payload); err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if !h.checkModerator(ctx, w, topicID, userID) {
+		return
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var flair types.TopicFlair
+	err = tx.QueryRow(ctx,
+		`INSERT INTO topics_flairs (topic_id, kind, text, background_color, text_color, position)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING flair_id, topic_id, kind, text, background_color, text_color, position`,
+		topicID, payload.Kind, payload.Text, payload.Background_Color, payload.Text_Color, payload.Position).
+		Scan(&flair.Flair_ID, &flair.Topic_ID, &flair.Kind, &flair.Text, &flair.Background_Color, &flair.Text_Color, &flair.Position)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	details := flair.Kind + ": " + flair.Text
+	if err := moderation.Log(ctx, tx, types.ModerationLogEntry{
+		Topic_ID:     topicID,
+		Moderator_ID: &userID,
+		Action:       moderation.ActionAddFlair,
+		Details:      &details,
+	}); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, flair)
+}
+
+// Update a flair, kind cannot be changed
+func (h *Handler) UpdateFlair(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	flairID, err := paramInt(r, "flair_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	userID, err := paramInt(r, "user_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.TopicFlairPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid inputs"))
+		return
+	}
+
+	var topicID int
+	err = h.db.QueryRow(ctx, `SELECT topic_id, kind FROM topics_flairs WHERE flair_id = $1`, flairID).
+		Scan(&topicID, &payload.Kind)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid flair id"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := validateFlair(&payload); err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !h.checkModerator(ctx, w, topicID, userID) {
+		return
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var flair types.TopicFlair
+	err = tx.QueryRow(ctx,
+		`UPDATE topics_flairs SET text = $1, background_color = $2, text_color = $3, position = $4
+		WHERE flair_id = $5
+		RETURNING flair_id, topic_id, kind, text, background_color, text_color, position`,
+		payload.Text, payload.Background_Color, payload.Text_Color, payload.Position, flairID).
+		Scan(&flair.Flair_ID, &flair.Topic_ID, &flair.Kind, &flair.Text, &flair.Background_Color, &flair.Text_Color, &flair.Position)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	details := flair.Kind + ": " + flair.Text
+	if err := moderation.Log(ctx, tx, types.ModerationLogEntry{
+		Topic_ID:     topicID,
+		Moderator_ID: &userID,
+		Action:       moderation.ActionUpdateFlair,
+		Details:      &details,
+	}); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, flair)
+}
+
+// Delete a flair, posts and users using it lose the flair
+func (h *Handler) DeleteFlair(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	flairID, err := paramInt(r, "flair_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	userID, err := paramInt(r, "user_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var topicID int
+	err = h.db.QueryRow(ctx, `SELECT topic_id FROM topics_flairs WHERE flair_id = $1`, flairID).Scan(&topicID)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid flair id"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !h.checkModerator(ctx, w, topicID, userID) {
+		return
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	//the last post flair of a topic that requires one cannot be deleted, no post could be added anymore
+	//the topic is locked so the requirement cannot be turned on meanwhile
+	var isLastRequired bool
+	err = tx.QueryRow(ctx,
+		`SELECT t.require_post_flair AND f.kind = $2
+			AND NOT EXISTS (SELECT 1 FROM topics_flairs o WHERE o.topic_id = t.topic_id AND o.kind = $2 AND o.flair_id <> f.flair_id)
+		FROM topics_flairs f
+		INNER JOIN topics t ON t.topic_id = f.topic_id
+		WHERE f.flair_id = $1
+		FOR UPDATE OF t`,
+		flairID, moderation.FlairKindPost).Scan(&isLastRequired)
+	if moderation.IsNotFound(err) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid flair id"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if isLastRequired {
+		util.WriteError(w, http.StatusConflict, errors.New("stop requiring a post flair before deleting the last one"))
+		return
+	}
+
+	var kind, text string
+	err = tx.QueryRow(ctx, `DELETE FROM topics_flairs WHERE flair_id = $1 RETURNING kind, text`, flairID).
+		Scan(&kind, &text)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	details := kind + ": " + text
+	if err := moderation.Log(ctx, tx, types.ModerationLogEntry{
+		Topic_ID:     topicID,
+		Moderator_ID: &userID,
+		Action:       moderation.ActionDeleteFlair,
+		Details:      &details,
+	}); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, map[string]int{
+		"flair_id": flairID,
+	})
+}
+
+// Update sidebar and banner markdown and whether posts need a flair
+func (h *Handler) UpdateSidebar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	topicID, err := paramInt(r, "topic_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	userID, err := paramInt(r, "user_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.TopicSidebarPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid inputs"))
+		return
+	}
+
+	if !h.checkModerator(ctx, w, topicID, userID) {
+		return
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	//a required post flair only makes sense when the topic has post flairs
+	//the topic is locked first so DeleteFlair cannot remove the last one meanwhile
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM topics WHERE topic_id = $1 FOR UPDATE`, topicID); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if payload.Require_Post_Flair {
+		postFlairs, err := moderation.GetTopicFlairs(ctx, tx, topicID, moderation.FlairKindPost)
+		if err != nil {
+			util.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if len(postFlairs) == 0 {
+			util.WriteError(w, http.StatusBadRequest, errors.New("add a post flair before requiring one"))
+			return
+		}
+	}
+
+	var result types.TopicSidebarPayload
+	err = tx.QueryRow(ctx,
+		`UPDATE topics SET sidebar_markdown = $1, banner_markdown = $2, require_post_flair = $3
+		WHERE topic_id = $4
+		RETURNING sidebar_markdown, banner_markdown, require_post_flair`,
+		payload.Sidebar_Markdown, payload.Banner_Markdown, payload.Require_Post_Flair, topicID).
+		Scan(&result.Sidebar_Markdown, &result.Banner_Markdown, &result.Require_Post_Flair)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := moderation.Log(ctx, tx, types.ModerationLogEntry{
+		Topic_ID:     topicID,
+		Moderator_ID: &userID,
+		Action:       moderation.ActionUpdateSidebar,
+	}); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, result)
+}
+
+// Make a topic read-only, only moderators can post, comment or vote until it is writable again
+func (h *Handler) SetReadOnly(w http.ResponseWriter, r *http.Request) {
+	h.toggleTopic(w, r, "is_read_only", moderation.ActionReadOnlyTopic, moderation.ActionWritableTopic)
+}
+
+// Make new posts of the topic nsfw by default, posts already in it keep their flag
+func (h *Handler) SetNSFW(w http.ResponseWriter, r *http.Request) {
+	h.toggleTopic(w, r, "is_nsfw", moderation.ActionNSFWTopic, moderation.ActionSFWTopic)
+}
+
+// set a boolean topic column and log it
+// column is never user input
+func (h *Handler) toggleTopic(w http.ResponseWriter, r *http.Request, column string, onAction string, offAction string) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	topicID, err := paramInt(r, "topic_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	userID, err := paramInt(r, "user_id")
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.TogglePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid inputs"))
+		return
+	}
+
+	if !h.checkModerator(ctx, w, topicID, userID) {
+		return
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `UPDATE topics SET `+column+` = $1 WHERE topic_id = $2`, payload.Value, topicID)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	action := offAction
+	if payload.Value {
+		action = onAction
+	}
+	if err := moderation.Log(ctx, tx, types.ModerationLogEntry{
+		Topic_ID:     topicID,
+		Moderator_ID: &userID,
+		Action:       action,
+	}); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, map[string]any{
+		"topic_id": topicID,
+		column:     payload.Value,
+	})
+}
@@ -0,0 +1,38 @@
+package topicModerationRouter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/minrui13/backend/moderation"
+	"github.com/minrui13/backend/types"
+)
+
+func TestValidateFlair(t *testing.T) {
+	for _, payload := range []types.TopicFlairPayload{
+		{Kind: "", Text: "Question"},
+		{Kind: "topic", Text: "Question"},
+		{Kind: moderation.FlairKindPost, Text: ""},
+		{Kind: moderation.FlairKindUser, Text: strings.Repeat("a", 65)},
+	} {
+		if err := validateFlair(&payload); err == nil {
+			t.Errorf("validateFlair(%+v) = nil, want an error", payload)
+		}
+	}
+
+	//missing colours get the defaults, chosen ones are kept
+	payload := types.TopicFlairPayload{Kind: moderation.FlairKindPost, Text: strings.Repeat("a", 64)}
+	if err := validateFlair(&payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Background_Color != "#e5e7eb" || payload.Text_Color != "#111827" {
+		t.Errorf("default colours = %s on %s, want #111827 on #e5e7eb", payload.Text_Color, payload.Background_Color)
+	}
+	payload = types.TopicFlairPayload{Kind: moderation.FlairKindUser, Text: "Mod", Background_Color: "#000000", Text_Color: "#ffffff"}
+	if err := validateFlair(&payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Background_Color != "#000000" || payload.Text_Color != "#ffffff" {
+		t.Errorf("chosen colours = %s on %s, want #ffffff on #000000", payload.Text_Color, payload.Background_Color)
+	}
+}
@@ -1,7 +1,9 @@
 package topicsRouter
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"strconv"
@@ -9,9 +11,15 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minrui13/backend/analytics"
 	"github.com/minrui13/backend/cursor"
+	"github.com/minrui13/backend/moderation"
+	"github.com/minrui13/backend/slug"
+	"github.com/minrui13/backend/trending"
 	"github.com/minrui13/backend/types"
+	"github.com/minrui13/backend/urlhistory"
 	"github.com/minrui13/backend/util"
 )
 
@@ -26,10 +34,24 @@ func NewHandler(db *pgxpool.Pool) *Handler {
 func (h *Handler) Router(r *mux.Router) *mux.Router {
 	//Get all topics
 	r.HandleFunc("/GetAllTopics/{user_id}", h.GetAllTopicsByFilter).Methods("POST")
+	//Get topics gaining follows, posts and votes faster than usual
+	r.HandleFunc("/trendingTopics/{user_id}", h.GetTrendingTopics).Methods("POST")
 	//Get topic by id
 	r.HandleFunc("/GetTopicByID/{topic_id}/{user_id}", h.GetTopicById).Methods("POST")
 	//Get topic by url
 	r.HandleFunc("/GetTopicByURL/{user_id}/{topic_url}", h.GetTopicByURL).Methods("POST")
+	//Select own user flair in a topic
+	r.HandleFunc("/selectUserFlair/{topic_id}/{user_id}", h.SelectUserFlair).Methods("PUT")
+	//Transfer ownership to a follower (creator only)
+	r.HandleFunc("/transferOwnership/{topic_id}/{user_id}", h.TransferOwnership).Methods("PUT")
+	//Reassign an abandoned topic (admin only)
+	r.HandleFunc("/reassignOwnership/{topic_id}/{user_id}", h.ReassignOwnership).Methods("PUT")
+	//Archive or unarchive a topic (creator or admin)
+	r.HandleFunc("/archiveTopic/{topic_id}/{user_id}", h.ArchiveTopic).Methods("PUT")
+	//Rename topic url, old url keeps resolving (creator only)
+	r.HandleFunc("/renameTopic/{topic_id}/{user_id}", h.RenameTopic).Methods("PUT")
+	//Daily analytics for a date range (creator only)
+	r.HandleFunc("/analytics/{topic_id}/{user_id}", h.GetTopicAnalytics).Methods("GET")
 	//Get most popular topic
 	//r.HandleFunc("/getPopularTopics/{user_id}", h.FilterTopicsByPopularityAndName).Methods("GET")
 
@@ -92,7 +114,8 @@ func (h *Handler) GetAllTopicsByFilter(w http.ResponseWriter, r *http.Request) {
 		SELECT t.topic_id, t.creator_id,  u.username, u.display_name, i.image_name, t.topic_name, t.topic_url, t.description,t.visibility,  t.created_date, c.category_name, c.icon_name, 
 		COALESCE(tff.followers_count, 0) AS followers_count,
 		COALESCE(p.posts_count, 0) AS posts_count,
-		CASE WHEN tf.user_id IS NULL THEN FALSE ELSE TRUE END AS is_following
+		CASE WHEN tf.user_id IS NULL THEN FALSE ELSE TRUE END AS is_following,
+		t.archived_date IS NOT NULL AS is_archived
 		FROM topics t 
 		INNER JOIN categories c ON t.category_id = c.category_id 
 		INNER JOIN users u ON u.user_id = t.creator_id
@@ -104,6 +127,7 @@ func (h *Handler) GetAllTopicsByFilter(w http.ResponseWriter, r *http.Request) {
 		LEFT JOIN (
 		SELECT topic_id, COUNT(post_id) AS posts_count
 		FROM posts
+		WHERE status = 'published' AND deleted_date IS NULL
 		GROUP BY topic_id
 		) AS p ON t.topic_id = p.topic_id
 		LEFT JOIN topics_followers tf ON t.topic_id = tf.topic_id AND tf.user_id = $1
@@ -212,7 +236,7 @@ func (h *Handler) GetAllTopicsByFilter(w http.ResponseWriter, r *http.Request) {
 
 		if err := rows.Scan(&topic.Topic_ID, &topic.Topic_User_ID, &topic.Username, &topic.Display_Name, &topic.Image_Name, &topic.Topic_Name,
 			&topic.Topic_URL, &topic.Description, &topic.Visibility, &created, &topic.Category_Name, &topic.Category_Icon,
-			&topic.Followers_Count, &topic.Posts_Count, &topic.Is_Following); err != nil {
+			&topic.Followers_Count, &topic.Posts_Count, &topic.Is_Following, &topic.Is_Archived); err != nil {
 			util.WriteError(w, http.StatusInternalServerError, err)
 			return
 		}
@@ -286,7 +310,8 @@ func (h *Handler) GetTopicById(w http.ResponseWriter, r *http.Request) {
 		SELECT t.topic_id, t.creator_id,  u.username, u.display_name, i.image_name, t.topic_name, t.topic_url, t.description,t.visibility,  t.created_date, c.category_name, c.icon_name, 
 		COALESCE(tff.followers_count, 0) AS followers_count,
 		COALESCE(p.posts_count, 0) AS posts_count,
-		CASE WHEN tf.user_id IS NULL THEN FALSE ELSE TRUE END AS is_following
+		CASE WHEN tf.user_id IS NULL THEN FALSE ELSE TRUE END AS is_following,
+		t.archived_date IS NOT NULL AS is_archived
 		FROM topics t 
 		INNER JOIN categories c ON t.category_id = c.category_id 
 		INNER JOIN users u ON u.user_id = t.creator_id
@@ -298,12 +323,13 @@ func (h *Handler) GetTopicById(w http.ResponseWriter, r *http.Request) {
 		LEFT JOIN (
 		SELECT topic_id, COUNT(post_id) AS posts_count
 		FROM posts
+		WHERE status = 'published' AND deleted_date IS NULL
 		GROUP BY topic_id
 		) AS p ON t.topic_id = p.topic_id
 		LEFT JOIN topics_followers tf ON t.topic_id = tf.topic_id AND tf.user_id = $1
 		INNER JOIN profile_image i ON u.image_id = i.image_id
 		WHERE t.topic_id=$2`, userIDInt, topicIDInt).
-		Scan(&topic.Topic_ID, &topic.Topic_User_ID, &topic.Username, &topic.Display_Name, &topic.Image_Name, &topic.Topic_Name, &topic.Topic_URL, &topic.Description, &topic.Visibility, &created, &topic.Category_Name, &topic.Category_Icon, &topic.Followers_Count, &topic.Posts_Count, &topic.Is_Following)
+		Scan(&topic.Topic_ID, &topic.Topic_User_ID, &topic.Username, &topic.Display_Name, &topic.Image_Name, &topic.Topic_Name, &topic.Topic_URL, &topic.Description, &topic.Visibility, &created, &topic.Category_Name, &topic.Category_Icon, &topic.Followers_Count, &topic.Posts_Count, &topic.Is_Following, &topic.Is_Archived)
 
 	topic.Created_Date = created.Format(time.RFC3339)
 
@@ -325,7 +351,7 @@ func (h *Handler) GetTopicById(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) GetTopicByURL(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	topic := new(types.TopicDefaultResult)
+	topic := new(types.TopicDetailResult)
 	var created time.Time
 
 	//get id from params
@@ -346,12 +372,31 @@ func (h *Handler) GetTopicByURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	//old urls from before a rename resolve to the current one
+	topicURL, err = urlhistory.ResolveTopicURL(ctx, h.db, topicURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid topic url"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	topic.Canonical_URL = urlhistory.TopicPath(topicURL)
+
 	//get data from db
 	err = h.db.QueryRow(ctx, `
 	SELECT t.topic_id, t.creator_id,  u.username, u.display_name, i.image_name, t.topic_name, t.topic_url, t.description,t.visibility,  t.created_date, c.category_name, c.icon_name, 
 		COALESCE(tff.followers_count, 0) AS followers_count,
 		COALESCE(p.posts_count, 0) AS posts_count,
-		CASE WHEN tf.user_id IS NULL THEN FALSE ELSE TRUE END AS is_following
+		CASE WHEN tf.user_id IS NULL THEN FALSE ELSE TRUE END AS is_following,
+		t.archived_date IS NOT NULL AS is_archived,
+		t.sidebar_markdown, t.banner_markdown, t.require_post_flair, t.is_read_only, t.is_nsfw,
+		(SELECT json_build_object('flair_id', f.flair_id, 'topic_id', f.topic_id, 'kind', f.kind, 'text', f.text,
+			'background_color', f.background_color, 'text_color', f.text_color, 'position', f.position)
+			FROM topics_users_flairs tuf
+			INNER JOIN topics_flairs f ON f.flair_id = tuf.flair_id
+			WHERE tuf.topic_id = t.topic_id AND tuf.user_id = $1) AS my_user_flair
 		FROM topics t 
 		INNER JOIN categories c ON t.category_id = c.category_id 
 		INNER JOIN users u ON u.user_id = t.creator_id
@@ -363,12 +408,14 @@ func (h *Handler) GetTopicByURL(w http.ResponseWriter, r *http.Request) {
 		LEFT JOIN (
 		SELECT topic_id, COUNT(post_id) AS posts_count
 		FROM posts
+		WHERE status = 'published' AND deleted_date IS NULL
 		GROUP BY topic_id
 		) AS p ON t.topic_id = p.topic_id
 		LEFT JOIN topics_followers tf ON t.topic_id = tf.topic_id AND tf.user_id = $1
 		INNER JOIN profile_image i ON u.image_id = i.image_id
 		WHERE t.topic_url=$2`, userIDInt, topicURL).
-		Scan(&topic.Topic_ID, &topic.Topic_User_ID, &topic.Username, &topic.Display_Name, &topic.Image_Name, &topic.Topic_Name, &topic.Topic_URL, &topic.Description, &topic.Visibility, &created, &topic.Category_Name, &topic.Category_Icon, &topic.Followers_Count, &topic.Posts_Count, &topic.Is_Following)
+		Scan(&topic.Topic_ID, &topic.Topic_User_ID, &topic.Username, &topic.Display_Name, &topic.Image_Name, &topic.Topic_Name, &topic.Topic_URL, &topic.Description, &topic.Visibility, &created, &topic.Category_Name, &topic.Category_Icon, &topic.Followers_Count, &topic.Posts_Count, &topic.Is_Following, &topic.Is_Archived,
+			&topic.Sidebar_Markdown, &topic.Banner_Markdown, &topic.Require_Post_Flair, &topic.Is_Read_Only, &topic.Is_NSFW, &topic.My_User_Flair)
 
 	topic.Created_Date = created.Format(time.RFC3339)
 
@@ -385,9 +432,105 @@ func (h *Handler) GetTopicByURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	//rules and flairs shown around the topic page
+	topic.Rules, err = moderation.GetTopicRules(ctx, h.db, topic.Topic_ID)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	topic.User_Flairs, err = moderation.GetTopicFlairs(ctx, h.db, topic.Topic_ID, moderation.FlairKindUser)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	topic.Post_Flairs, err = moderation.GetTopicFlairs(ctx, h.db, topic.Topic_ID, moderation.FlairKindPost)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
 	util.WriteJSON(w, http.StatusOK, topic)
 }
 
+// Select or clear own user flair in a topic
+func (h *Handler) SelectUserFlair(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	//get topic_id from params
+	topicID := mux.Vars(r)["topic_id"]
+	//convert topicID to integer (check if valid integer)
+	topicIDInt, err := strconv.Atoi(topicID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	//get user_id from params
+	userID := mux.Vars(r)["user_id"]
+	//convert userID to integer (check if valid integer)
+	userIDInt, err := strconv.Atoi(userID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.UserFlairPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid inputs"))
+		return
+	}
+
+	//clear flair
+	if payload.Flair_ID == nil {
+		_, err = h.db.Exec(ctx, `DELETE FROM topics_users_flairs WHERE topic_id = $1 AND user_id = $2`,
+			topicIDInt, userIDInt)
+		if err != nil {
+			util.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		util.WriteJSON(w, http.StatusOK, map[string]any{
+			"topic_id": topicIDInt,
+			"flair_id": nil,
+		})
+		return
+	}
+
+	isFlair, err := moderation.IsTopicFlair(ctx, h.db, topicIDInt, *payload.Flair_ID, moderation.FlairKindUser)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !isFlair {
+		util.WriteError(w, http.StatusBadRequest, moderation.ErrInvalidFlair)
+		return
+	}
+
+	_, err = h.db.Exec(ctx,
+		`INSERT INTO topics_users_flairs (topic_id, user_id, flair_id) VALUES ($1, $2, $3)
+		ON CONFLICT (topic_id, user_id) DO UPDATE SET flair_id = EXCLUDED.flair_id`,
+		topicIDInt, userIDInt, *payload.Flair_ID)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, map[string]any{
+		"topic_id": topicIDInt,
+		"flair_id": *payload.Flair_ID,
+	})
+}
+
 // // Get filtered topics by popularity and topics name
 // func (h *Handler) FilterTopicsByPopularityAndName(w http.ResponseWriter, r *http.Request) {
 // 	ctx := r.Context()
@@ -447,3 +590,576 @@ func (h *Handler) GetTopicByURL(w http.ResponseWriter, r *http.Request) {
 
 // 	util.WriteJSON(w, http.StatusOK, topicsArr)
 // }
+
+// move topic ownership inside a transaction
+// the new owner no longer needs a moderator row since the creator is always a moderator
+// expectedOwnerID is checked against the locked row so a concurrent change of owner is not overwritten,
+// returns moderation.ErrNotOwner when it no longer owns the topic, nil skips the check
+func changeOwner(ctx context.Context, tx pgx.Tx, topicID int, newOwnerID int, expectedOwnerID *int, keepOldOwner bool) (int, error) {
+	var oldOwnerID int
+	err := tx.QueryRow(ctx, `SELECT creator_id FROM topics WHERE topic_id = $1 FOR UPDATE`, topicID).Scan(&oldOwnerID)
+	if err != nil {
+		return 0, err
+	}
+	if expectedOwnerID != nil && oldOwnerID != *expectedOwnerID {
+		return 0, moderation.ErrNotOwner
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE topics SET creator_id = $1 WHERE topic_id = $2`, newOwnerID, topicID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM topics_moderators WHERE topic_id = $1 AND user_id = $2`, topicID, newOwnerID); err != nil {
+		return 0, err
+	}
+	if keepOldOwner {
+		_, err := tx.Exec(ctx,
+			`INSERT INTO topics_moderators (topic_id, user_id, appointed_by) VALUES ($1, $2, $3)
+			ON CONFLICT (topic_id, user_id) DO NOTHING`,
+			topicID, oldOwnerID, newOwnerID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return oldOwnerID, nil
+}
+
+// Transfer ownership to another member of the topic
+func (h *Handler) TransferOwnership(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	//get topic_id from params
+	topicID := mux.Vars(r)["topic_id"]
+	//convert topicID to integer (check if valid integer)
+	topicIDInt, err := strconv.Atoi(topicID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	//get user_id from params
+	userID := mux.Vars(r)["user_id"]
+	//convert userID to integer (check if valid integer)
+	userIDInt, err := strconv.Atoi(userID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.TargetUserPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Target_User_ID == 0 {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid inputs"))
+		return
+	}
+	if payload.Target_User_ID == userIDInt {
+		util.WriteError(w, http.StatusBadRequest, errors.New("you already own this topic"))
+		return
+	}
+
+	isOwner, err := moderation.IsTopicOwner(ctx, h.db, topicIDInt, userIDInt)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !isOwner {
+		util.WriteError(w, http.StatusForbidden, moderation.ErrNotOwner)
+		return
+	}
+
+	//new owner has to follow the topic and not be banned from it
+	var isMember bool
+	err = h.db.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM topics_followers WHERE topic_id = $1 AND user_id = $2)`,
+		topicIDInt, payload.Target_User_ID).Scan(&isMember)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !isMember {
+		util.WriteError(w, http.StatusBadRequest, errors.New("new owner must follow the topic"))
+		return
+	}
+	isBanned, err := moderation.IsBannedFromTopic(ctx, h.db, topicIDInt, payload.Target_User_ID)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if isBanned {
+		util.WriteError(w, http.StatusBadRequest, errors.New("new owner is banned from the topic"))
+		return
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	//previous owner stays on as a moderator
+	//ownership is checked again on the locked topic in case it changed since the check above
+	_, err = changeOwner(ctx, tx, topicIDInt, payload.Target_User_ID, &userIDInt, true)
+	if errors.Is(err, moderation.ErrNotOwner) {
+		util.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := moderation.Log(ctx, tx, types.ModerationLogEntry{
+		Topic_ID:       topicIDInt,
+		Moderator_ID:   &userIDInt,
+		Action:         moderation.ActionTransferOwner,
+		Target_User_ID: &payload.Target_User_ID,
+	}); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, map[string]int{
+		"topic_id":      topicIDInt,
+		"topic_user_id": payload.Target_User_ID,
+	})
+}
+
+// Reassign an abandoned topic to a new owner
+func (h *Handler) ReassignOwnership(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	//get topic_id from params
+	topicID := mux.Vars(r)["topic_id"]
+	//convert topicID to integer (check if valid integer)
+	topicIDInt, err := strconv.Atoi(topicID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	//get user_id from params
+	userID := mux.Vars(r)["user_id"]
+	//convert userID to integer (check if valid integer)
+	userIDInt, err := strconv.Atoi(userID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.TargetUserPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Target_User_ID == 0 {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid inputs"))
+		return
+	}
+
+	isAdmin, err := moderation.IsAdmin(ctx, h.db, userIDInt)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !isAdmin {
+		util.WriteError(w, http.StatusForbidden, moderation.ErrNotAdmin)
+		return
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	//abandoned owner does not keep moderator rights
+	oldOwnerID, err := changeOwner(ctx, tx, topicIDInt, payload.Target_User_ID, nil, false)
+	if errors.Is(err, pgx.ErrNoRows) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid topic id"))
+		return
+	}
+	if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23503" {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid user id"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	details := "previous owner " + strconv.Itoa(oldOwnerID)
+	if err := moderation.Log(ctx, tx, types.ModerationLogEntry{
+		Topic_ID:       topicIDInt,
+		Moderator_ID:   &userIDInt,
+		Action:         moderation.ActionReassignOwner,
+		Target_User_ID: &payload.Target_User_ID,
+		Details:        &details,
+	}); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, map[string]int{
+		"topic_id":      topicIDInt,
+		"topic_user_id": payload.Target_User_ID,
+	})
+}
+
+// Archive or unarchive a topic
+func (h *Handler) ArchiveTopic(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	//get topic_id from params
+	topicID := mux.Vars(r)["topic_id"]
+	//convert topicID to integer (check if valid integer)
+	topicIDInt, err := strconv.Atoi(topicID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	//get user_id from params
+	userID := mux.Vars(r)["user_id"]
+	//convert userID to integer (check if valid integer)
+	userIDInt, err := strconv.Atoi(userID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.TogglePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid inputs"))
+		return
+	}
+
+	//creator or admin
+	isOwner, err := moderation.IsTopicOwner(ctx, h.db, topicIDInt, userIDInt)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !isOwner {
+		isAdmin, err := moderation.IsAdmin(ctx, h.db, userIDInt)
+		if err != nil {
+			util.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !isAdmin {
+			util.WriteError(w, http.StatusForbidden, errors.New("only the topic creator or an admin can archive a topic"))
+			return
+		}
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	//keep the first archived date if archived twice
+	var isArchived bool
+	err = tx.QueryRow(ctx,
+		`UPDATE topics
+		SET archived_date = CASE WHEN $1 THEN COALESCE(archived_date, current_timestamp) ELSE NULL END
+		WHERE topic_id = $2
+		RETURNING archived_date IS NOT NULL`,
+		payload.Value, topicIDInt).Scan(&isArchived)
+	if errors.Is(err, pgx.ErrNoRows) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid topic id"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	action := moderation.ActionUnarchiveTopic
+	if isArchived {
+		action = moderation.ActionArchiveTopic
+	}
+	if err := moderation.Log(ctx, tx, types.ModerationLogEntry{
+		Topic_ID:     topicIDInt,
+		Moderator_ID: &userIDInt,
+		Action:       action,
+	}); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, map[string]any{
+		"topic_id":    topicIDInt,
+		"is_archived": isArchived,
+	})
+}
+
+// Change the topic url, the old url redirects to the new one
+func (h *Handler) RenameTopic(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	//get topic_id from params
+	topicID := mux.Vars(r)["topic_id"]
+	//convert topicID to integer (check if valid integer)
+	topicIDInt, err := strconv.Atoi(topicID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	//get user_id from params
+	userID := mux.Vars(r)["user_id"]
+	//convert userID to integer (check if valid integer)
+	userIDInt, err := strconv.Atoi(userID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.TopicRenamePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Topic_URL == "" {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid inputs"))
+		return
+	}
+	//topic urls are path segments of the pages and share links, the same form as post urls
+	if !slug.IsValid(payload.Topic_URL) {
+		util.WriteError(w, http.StatusBadRequest, errors.New("topic url may only contain lowercase letters, numbers and hyphens"))
+		return
+	}
+
+	isOwner, err := moderation.IsTopicOwner(ctx, h.db, topicIDInt, userIDInt)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !isOwner {
+		util.WriteError(w, http.StatusForbidden, moderation.ErrNotOwner)
+		return
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var oldURL string
+	err = tx.QueryRow(ctx, `SELECT topic_url FROM topics WHERE topic_id = $1 FOR UPDATE`, topicIDInt).Scan(&oldURL)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = urlhistory.RenameTopic(ctx, tx, topicIDInt, payload.Topic_URL)
+	//url used by another topic
+	if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
+		util.WriteError(w, http.StatusConflict, errors.New("topic url already taken"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	details := oldURL + " -> " + payload.Topic_URL
+	if err := moderation.Log(ctx, tx, types.ModerationLogEntry{
+		Topic_ID:     topicIDInt,
+		Moderator_ID: &userIDInt,
+		Action:       moderation.ActionRenameTopic,
+		Details:      &details,
+	}); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, map[string]any{
+		"topic_id":      topicIDInt,
+		"topic_url":     payload.Topic_URL,
+		"canonical_url": urlhistory.TopicPath(payload.Topic_URL),
+	})
+}
+
+// Daily followers, posts, comments, votes and active users with top posts and contributors
+// from and to are YYYY-MM-DD, defaults to the last 30 days
+func (h *Handler) GetTopicAnalytics(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	//get topic_id from params
+	topicID := mux.Vars(r)["topic_id"]
+	//convert topicID to integer (check if valid integer)
+	topicIDInt, err := strconv.Atoi(topicID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	//get user_id from params
+	userID := mux.Vars(r)["user_id"]
+	//convert userID to integer (check if valid integer)
+	userIDInt, err := strconv.Atoi(userID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	//number of top posts and contributors
+	limitQuery := 10
+	if limit := query.Get("limit"); limit != "" {
+		limitQuery, err = strconv.Atoi(limit)
+		if err != nil || limitQuery < 1 || limitQuery > 50 {
+			util.WriteError(w, http.StatusBadRequest, errors.New("limit must be between 1 and 50"))
+			return
+		}
+	}
+
+	isOwner, err := moderation.IsTopicOwner(ctx, h.db, topicIDInt, userIDInt)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !isOwner {
+		util.WriteError(w, http.StatusForbidden, moderation.ErrNotOwner)
+		return
+	}
+
+	today, err := analytics.Today(ctx, h.db)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	to := today
+	if query.Get("to") != "" {
+		to, err = time.Parse("2006-01-02", query.Get("to"))
+		if err != nil {
+			util.WriteError(w, http.StatusBadRequest, errors.New("invalid to date"))
+			return
+		}
+	}
+	from := to.AddDate(0, 0, -29)
+	if query.Get("from") != "" {
+		from, err = time.Parse("2006-01-02", query.Get("from"))
+		if err != nil {
+			util.WriteError(w, http.StatusBadRequest, errors.New("invalid from date"))
+			return
+		}
+	}
+	if to.Before(from) || to.Sub(from) > 366*24*time.Hour {
+		util.WriteError(w, http.StatusBadRequest, errors.New("date range must be between 1 and 367 days"))
+		return
+	}
+
+	result, err := analytics.GetTopicAnalytics(ctx, h.db, topicIDInt, from, to, limitQuery)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, result)
+}
+
+// Get trending topics
+// pass in 0 for user_id if non signup or login users
+func (h *Handler) GetTrendingTopics(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//get user_id from params
+	userID := mux.Vars(r)["user_id"]
+	//convert userID to integer (check if valid integer)
+	userIDInt, err := strconv.Atoi(userID)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limitQuery := trending.DefaultLimit
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		limitQuery, err = strconv.Atoi(limit)
+		if err != nil || limitQuery < 1 || limitQuery > trending.MaxLimit {
+			util.WriteError(w, http.StatusBadRequest, errors.New("limit must be between 1 and 50"))
+			return
+		}
+	}
+
+	topicsArr, err := trending.Topics(ctx, h.db, userIDInt, limitQuery)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, topicsArr)
+}
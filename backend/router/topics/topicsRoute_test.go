@@ -0,0 +1,27 @@
+package topicsRouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// bad urls are refused before the database is used
+func TestRenameTopicRejectsBadURLs(t *testing.T) {
+	r := mux.NewRouter()
+	NewHandler(nil).Router(r)
+
+	for _, topicURL := range []string{"", "Go-Tips", "go tips", "go/tips", "..", "../admin", "go--tips", "-go", "go?x=1"} {
+		body := strings.NewReader(`{"topic_url": "` + topicURL + `"}`)
+		req := httptest.NewRequest(http.MethodPut, "/renameTopic/1/1", body)
+		req.Header.Set("Authorization", "Bearer token")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("renaming to %q = %d, want %d", topicURL, w.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -10,10 +10,12 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/minrui13/backend/auth"
 	"github.com/minrui13/backend/config"
+	"github.com/minrui13/backend/nsfw"
 	"github.com/minrui13/backend/types"
 	"github.com/minrui13/backend/util"
 )
@@ -37,6 +39,14 @@ func (h *Handler) Router(r *mux.Router) *mux.Router {
 	r.HandleFunc("/login", h.Login).Methods("POST")
 	//Update User
 	r.HandleFunc("/updateUser", h.UpdateUser).Methods("PUT")
+	//Get nsfw and spoiler preferences
+	r.HandleFunc("/getPreferences/{id}", h.GetPreferences).Methods("POST")
+	//Update nsfw and spoiler preferences
+	r.HandleFunc("/updatePreferences/{id}", h.UpdatePreferences).Methods("PUT")
+	//Follow a user, their posts show up in the home feed
+	r.HandleFunc("/followUser/{id}/{target_id}", h.FollowUser).Methods("POST")
+	//Unfollow a user
+	r.HandleFunc("/unfollowUser/{id}/{target_id}", h.UnfollowUser).Methods("DELETE")
 	//Get user by user id
 	r.HandleFunc("/{id}", h.GetUserById).Methods("POST")
 	return r
@@ -303,3 +313,182 @@ func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	util.WriteJSON(w, http.StatusOK, result)
 
 }
+
+// Get what the user wants to see of nsfw and spoiler posts
+func (h *Handler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	//get id from params
+	id := mux.Vars(r)["id"]
+	//convert userID to integer (check if valid integer)
+	userID, err := strconv.Atoi(id)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	prefs, err := nsfw.GetPreferences(ctx, h.db, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid user id"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, prefs)
+}
+
+// Update what the user wants to see of nsfw and spoiler posts
+func (h *Handler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	//get id from params
+	id := mux.Vars(r)["id"]
+	//convert userID to integer (check if valid integer)
+	userID, err := strconv.Atoi(id)
+	//check if id is an integer
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.UserPreferences
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		util.WriteError(w, http.StatusBadRequest, errors.New("invalid inputs"))
+		return
+	}
+
+	prefs, err := nsfw.SetPreferences(ctx, h.db, userID, payload)
+	if errors.Is(err, nsfw.ErrInvalidPreference) {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid user id"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, prefs)
+}
+
+// read the follower and the followed user from params
+func followParams(r *http.Request) (int, int, error) {
+	//get id from params
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, 0, err
+	}
+	//get target_id from params
+	targetID, err := strconv.Atoi(mux.Vars(r)["target_id"])
+	if err != nil {
+		return 0, 0, err
+	}
+	return userID, targetID, nil
+}
+
+// Follow another user
+func (h *Handler) FollowUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	userID, targetID, err := followParams(r)
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if userID == targetID {
+		util.WriteError(w, http.StatusBadRequest, errors.New("cannot follow yourself"))
+		return
+	}
+
+	_, err = h.db.Exec(ctx,
+		`INSERT INTO users_followers (user_id, followed_user_id) VALUES ($1, $2)`,
+		userID, targetID)
+
+	if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
+		util.WriteError(w, http.StatusConflict, errors.New("already following this user"))
+		return
+	}
+	if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23503" {
+		util.WriteError(w, http.StatusNotFound, errors.New("invalid user id"))
+		return
+	}
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, map[string]string{
+		"message": "followed successfully",
+	})
+}
+
+// Unfollow a user
+func (h *Handler) UnfollowUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	//only verified users can access the data
+	//check token and token header
+	authToken := r.Header.Get("Authorization")
+	//check if authHeader is empty
+	if authToken == "" {
+		util.WriteError(w, http.StatusUnauthorized, errors.New("Missing authorization header"))
+		return
+	}
+
+	userID, targetID, err := followParams(r)
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	result, err := h.db.Exec(ctx,
+		`DELETE FROM users_followers WHERE user_id = $1 AND followed_user_id = $2`,
+		userID, targetID)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		util.WriteError(w, http.StatusNotFound, errors.New("not following this user"))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, map[string]string{
+		"message": "unfollowed successfully",
+	})
+}
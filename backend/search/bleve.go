@@ -0,0 +1,494 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"html"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/mapping"
+	highlightHTML "github.com/blevesearch/bleve/v2/search/highlight/highlighter/html"
+	"github.com/blevesearch/bleve/v2/search/query"
+	db "github.com/minrui13/backend/database"
+	"github.com/minrui13/backend/nsfw"
+	"github.com/minrui13/backend/types"
+)
+
+const (
+	// posts read from the database per index batch
+	batchSize = 500
+	// words of content shown when the content itself did not match
+	snippetWords = 35
+	// wait for the lock of an index used by another process instead of hanging
+	openTimeout = "5s"
+)
+
+// how much a match in each field counts
+var fieldBoosts = map[string]float64{
+	"title":      3,
+	"content":    1,
+	"comments":   0.5,
+	"topic_name": 0.5,
+}
+
+// embedded index on disk, posts are documents with their comments folded in
+// fuzzy matching makes it forgiving of typos where postgres needs exact words
+type Bleve struct {
+	path string
+	//write lock only while Reindex swaps the index
+	mu    sync.RWMutex
+	index bleve.Index
+	//created empty, the first sync fills it
+	fresh bool
+}
+
+// open the index at path or create it when it does not exist yet
+func OpenBleve(path string) (*Bleve, error) {
+	index, err := bleve.OpenUsing(path, map[string]any{"bolt_timeout": openTimeout})
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		index, err = bleve.New(path, indexMapping())
+		if err != nil {
+			return nil, err
+		}
+		return &Bleve{path: path, index: index, fresh: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Bleve{path: path, index: index}, nil
+}
+
+func indexMapping() mapping.IndexMapping {
+	text := bleve.NewTextFieldMapping()
+	text.Analyzer = en.AnalyzerName
+	text.Store = true
+	text.IncludeTermVectors = true
+
+	//searchable but never shown
+	topicName := bleve.NewTextFieldMapping()
+	topicName.Analyzer = en.AnalyzerName
+	topicName.Store = false
+
+	number := bleve.NewNumericFieldMapping()
+	number.Store = false
+	date := bleve.NewDateTimeFieldMapping()
+	date.Store = false
+	flag := bleve.NewBooleanFieldMapping()
+	flag.Store = false
+
+	post := bleve.NewDocumentMapping()
+	post.Dynamic = false
+	post.AddFieldMappingsAt("title", text)
+	post.AddFieldMappingsAt("content", text)
+	post.AddFieldMappingsAt("comments", text)
+	post.AddFieldMappingsAt("topic_name", topicName)
+	post.AddFieldMappingsAt("topic_id", number)
+	post.AddFieldMappingsAt("tag_id", number)
+	post.AddFieldMappingsAt("author_id", number)
+	post.AddFieldMappingsAt("created_date", date)
+	post.AddFieldMappingsAt("is_nsfw", flag)
+
+	indexMapping := bleve.NewIndexMapping()
+	indexMapping.DefaultMapping = post
+	indexMapping.DefaultAnalyzer = en.AnalyzerName
+	return indexMapping
+}
+
+// matches are ranked by bleve's score, pages continue after the cursor's score and post id
+// the hits are loaded from the database so results always show the current post
+func (b *Bleve) SearchPosts(ctx context.Context, q db.Querier, filter types.SearchFilter) ([]types.PostSearchResult, error) {
+	groups := parseTerms(filter.Query)
+	if len(groups) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	showNSFW, err := nsfw.Shown(ctx, q, filter.Viewer_ID)
+	if err != nil {
+		return nil, err
+	}
+
+	request := bleve.NewSearchRequestOptions(searchQuery(groups, filter, showNSFW), filter.Limit, 0, false)
+	request.SortBy([]string{"-_score", "-_id"})
+	if filter.Cursor != nil {
+		request.SearchAfter = []string{strconv.FormatFloat(filter.Cursor.Rank, 'g', -1, 64), strconv.Itoa(filter.Cursor.Post_ID)}
+	}
+	request.Highlight = bleve.NewHighlightWithStyle(highlightHTML.Name)
+	request.Highlight.Fields = []string{"title", "content", "comments"}
+
+	b.mu.RLock()
+	response, err := b.index.SearchInContext(ctx, request)
+	b.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	postIDs := make([]int, 0, len(response.Hits))
+	for _, hit := range response.Hits {
+		postID, err := strconv.Atoi(hit.ID)
+		if err != nil {
+			return nil, err
+		}
+		postIDs = append(postIDs, postID)
+	}
+	postMap, err := loadResults(ctx, q, postIDs, filter.Viewer_ID)
+	if err != nil {
+		return nil, err
+	}
+
+	//keep the index order, posts hidden since the last sync are left out
+	resultArr := []types.PostSearchResult{}
+	for i, hit := range response.Hits {
+		result, ok := postMap[postIDs[i]]
+		if !ok {
+			continue
+		}
+		result.Rank = hit.Score
+		result.Title_Highlight = html.EscapeString(result.Title)
+		if fragments := hit.Fragments["title"]; len(fragments) > 0 {
+			result.Title_Highlight = fragments[0]
+		}
+		if fragments := hit.Fragments["content"]; len(fragments) > 0 {
+			result.Snippet = strings.Join(fragments, " ... ")
+		}
+		//fields come back even when they did not match, only matching comments are shown
+		if fragments := hit.Fragments["comments"]; len(fragments) > 0 && strings.Contains(fragments[0], "<mark>") {
+			snippet := strings.Join(fragments, " ... ")
+			result.Comment_Snippet = &snippet
+		}
+		resultArr = append(resultArr, result)
+	}
+	return resultArr, nil
+}
+
+// the query of the search box groups with the filters
+// nsfw posts are left out unless showNSFW
+func searchQuery(groups [][]term, filter types.SearchFilter, showNSFW bool) query.Query {
+	groupArr := []query.Query{}
+	for _, group := range groups {
+		boolean := bleve.NewBooleanQuery()
+		for _, t := range group {
+			if t.negate {
+				boolean.AddMustNot(termQuery(t))
+			} else {
+				boolean.AddMust(termQuery(t))
+			}
+		}
+		if !showNSFW {
+			isNSFW := bleve.NewBoolFieldQuery(true)
+			isNSFW.SetField("is_nsfw")
+			boolean.AddMustNot(isNSFW)
+		}
+		groupArr = append(groupArr, boolean)
+	}
+	conjunction := bleve.NewConjunctionQuery(bleve.NewDisjunctionQuery(groupArr...))
+
+	//filters
+	for field, id := range map[string]*int{"topic_id": filter.Topic_ID, "tag_id": filter.Tag_ID, "author_id": filter.Author_ID} {
+		if id != nil {
+			value := float64(*id)
+			inclusive := true
+			numeric := bleve.NewNumericRangeInclusiveQuery(&value, &value, &inclusive, &inclusive)
+			numeric.SetField(field)
+			conjunction.AddQuery(numeric)
+		}
+	}
+	if filter.From != nil || filter.To != nil {
+		var start, end time.Time
+		if filter.From != nil {
+			start = *filter.From
+		}
+		if filter.To != nil {
+			end = filter.To.AddDate(0, 0, 1)
+		}
+		startInclusive, endInclusive := true, false
+		dateRange := bleve.NewDateRangeInclusiveQuery(start, end, &startInclusive, &endInclusive)
+		dateRange.SetField("created_date")
+		conjunction.AddQuery(dateRange)
+	}
+	return conjunction
+}
+
+// a word or phrase in any of the searched fields
+// single words allow a typo or two depending on their length
+func termQuery(t term) query.Query {
+	fieldArr := []query.Query{}
+	for field, boost := range fieldBoosts {
+		switch {
+		case len(t.words) > 1:
+			phrase := bleve.NewMatchPhraseQuery(strings.Join(t.words, " "))
+			phrase.SetField(field)
+			phrase.SetBoost(boost)
+			fieldArr = append(fieldArr, phrase)
+		case t.prefix:
+			prefix := bleve.NewPrefixQuery(strings.ToLower(t.words[0]))
+			prefix.SetField(field)
+			prefix.SetBoost(boost)
+			fieldArr = append(fieldArr, prefix)
+		default:
+			match := bleve.NewMatchQuery(t.words[0])
+			match.SetField(field)
+			match.SetBoost(boost)
+			match.SetFuzziness(fuzziness(t.words[0]))
+			fieldArr = append(fieldArr, match)
+		}
+	}
+	return bleve.NewDisjunctionQuery(fieldArr...)
+}
+
+// edits allowed for a word, short words must match exactly
+// a swapped pair of letters is two edits
+func fuzziness(word string) int {
+	switch n := len([]rune(word)); {
+	case n < 3:
+		return 0
+	case n < 5:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// display fields of visible posts by id, posts of topics made private since the last sync are left out
+// so are nsfw posts the viewer does not see, the index may not know the flag yet
+func loadResults(ctx context.Context, q db.Querier, postIDs []int, viewerID *int) (map[int]types.PostSearchResult, error) {
+	rows, err := q.Query(ctx,
+		`SELECT p.post_id, p.post_url, u.user_id, u.username, u.display_name, i.image_name,
+			t.topic_id, t.topic_name, t.topic_url, tags.tag_name, p.title, p.content, p.created_date,
+			p.upvote_count - p.downvote_count AS sum_of_votes,
+			p.comment_count AS num_of_comments
+		FROM posts p
+		LEFT JOIN tags ON tags.tag_id = p.tag_id
+		INNER JOIN users u ON u.user_id = p.author_id
+		INNER JOIN profile_image i ON u.image_id = i.image_id
+		INNER JOIN topics t ON t.topic_id = p.topic_id
+		WHERE p.post_id = ANY($1) AND t.visibility = 'public' AND p.status = 'published' AND p.deleted_date IS NULL`+nsfw.VisibleWhere(2),
+		postIDs, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	postMap := map[int]types.PostSearchResult{}
+	for rows.Next() {
+		var result types.PostSearchResult
+		var content string
+		var created time.Time
+		if err := rows.Scan(&result.Post_ID, &result.Post_URL, &result.User_ID, &result.Username, &result.DisplayName, &result.User_Image,
+			&result.Topic_ID, &result.Topic_Name, &result.Topic_URL, &result.Tag_Name, &result.Title, &content, &created,
+			&result.Sum_Votes, &result.Comment_Count); err != nil {
+			return nil, err
+		}
+		result.Created_Date = created.Format(time.RFC3339)
+		//start of the content until a fragment replaces it
+		words := strings.Fields(content)
+		if len(words) > snippetWords {
+			words = words[:snippetWords]
+		}
+		result.Snippet = html.EscapeString(strings.Join(words, " "))
+		postMap[result.Post_ID] = result
+	}
+	return postMap, rows.Err()
+}
+
+// documents for the visible posts among postIDs, the rest are removed from the index
+// only posts of public topics are indexed
+func indexPosts(ctx context.Context, q db.Querier, index bleve.Index, postIDs []int) error {
+	rows, err := q.Query(ctx,
+		`SELECT p.post_id, p.title, p.content, t.topic_name, p.topic_id, p.tag_id, p.author_id, p.created_date, p.is_nsfw,
+			COALESCE((SELECT string_agg(pc.content, E'\n' ORDER BY pc.comment_id) FROM posts_comments pc WHERE pc.post_id = p.post_id), '')
+		FROM posts p
+		INNER JOIN topics t ON t.topic_id = p.topic_id
+		WHERE p.post_id = ANY($1) AND t.visibility = 'public' AND p.status = 'published' AND p.deleted_date IS NULL`,
+		postIDs)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	batch := index.NewBatch()
+	visible := map[int]bool{}
+	for rows.Next() {
+		var postID, topicID, authorID int
+		var tagID *int
+		var title, content, topicName, comments string
+		var created time.Time
+		var isNSFW bool
+		if err := rows.Scan(&postID, &title, &content, &topicName, &topicID, &tagID, &authorID, &created, &isNSFW, &comments); err != nil {
+			return err
+		}
+		document := map[string]any{
+			"title":        title,
+			"content":      content,
+			"comments":     comments,
+			"topic_name":   topicName,
+			"topic_id":     float64(topicID),
+			"author_id":    float64(authorID),
+			"created_date": created,
+			"is_nsfw":      isNSFW,
+		}
+		if tagID != nil {
+			document["tag_id"] = float64(*tagID)
+		}
+		if err := batch.Index(strconv.Itoa(postID), document); err != nil {
+			return err
+		}
+		visible[postID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, postID := range postIDs {
+		if !visible[postID] {
+			batch.Delete(strconv.Itoa(postID))
+		}
+	}
+	return index.Batch(batch)
+}
+
+// index the queued posts and the posts of queued topics
+func (b *Bleve) Sync(ctx context.Context, q db.Querier) error {
+	if b.fresh {
+		return b.Reindex(ctx, q)
+	}
+
+	var lastQueueID *int64
+	if err := q.QueryRow(ctx, `SELECT MAX(queue_id) FROM search_index_queue`).Scan(&lastQueueID); err != nil {
+		return err
+	}
+	if lastQueueID == nil {
+		return nil
+	}
+
+	//posts of a renamed topic carry the old topic name, posts of a topic made private must leave the index
+	rows, err := q.Query(ctx,
+		`SELECT entity_id FROM search_index_queue WHERE entity = 'post' AND queue_id <= $1
+		UNION
+		SELECT p.post_id FROM search_index_queue s
+		INNER JOIN posts p ON p.topic_id = s.entity_id
+		WHERE s.entity = 'topic' AND s.queue_id <= $1`,
+		*lastQueueID)
+	if err != nil {
+		return err
+	}
+	postIDs := []int{}
+	for rows.Next() {
+		var postID int
+		if err := rows.Scan(&postID); err != nil {
+			rows.Close()
+			return err
+		}
+		postIDs = append(postIDs, postID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for start := 0; start < len(postIDs); start += batchSize {
+		end := min(start+batchSize, len(postIDs))
+		if err := indexPosts(ctx, q, b.index, postIDs[start:end]); err != nil {
+			return err
+		}
+	}
+
+	//changes queued while indexing stay for the next sync
+	_, err = q.Exec(ctx, `DELETE FROM search_index_queue WHERE queue_id <= $1`, *lastQueueID)
+	return err
+}
+
+// build a new index next to the current one and swap it in
+// searches keep using the current index until the new one is ready
+func (b *Bleve) Reindex(ctx context.Context, q db.Querier) error {
+	//everything queued so far is covered by the rebuild
+	var lastQueueID *int64
+	if err := q.QueryRow(ctx, `SELECT MAX(queue_id) FROM search_index_queue`).Scan(&lastQueueID); err != nil {
+		return err
+	}
+
+	buildPath := b.path + ".rebuild"
+	if err := os.RemoveAll(buildPath); err != nil {
+		return err
+	}
+	index, err := bleve.New(buildPath, indexMapping())
+	if err != nil {
+		return err
+	}
+
+	lastPostID := 0
+	for {
+		rows, err := q.Query(ctx,
+			`SELECT p.post_id FROM posts p
+			INNER JOIN topics t ON t.topic_id = p.topic_id
+			WHERE p.post_id > $1 AND t.visibility = 'public' AND p.status = 'published' AND p.deleted_date IS NULL
+			ORDER BY p.post_id
+			LIMIT $2`,
+			lastPostID, batchSize)
+		if err != nil {
+			index.Close()
+			return err
+		}
+		postIDs := []int{}
+		for rows.Next() {
+			var postID int
+			if err := rows.Scan(&postID); err != nil {
+				rows.Close()
+				index.Close()
+				return err
+			}
+			postIDs = append(postIDs, postID)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			index.Close()
+			return err
+		}
+		if len(postIDs) == 0 {
+			break
+		}
+		if err := indexPosts(ctx, q, index, postIDs); err != nil {
+			index.Close()
+			return err
+		}
+		lastPostID = postIDs[len(postIDs)-1]
+	}
+	if err := index.Close(); err != nil {
+		return err
+	}
+
+	//swap the rebuilt index in place of the current one
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.index.Close(); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(b.path); err != nil {
+		return err
+	}
+	if err := os.Rename(buildPath, b.path); err != nil {
+		return err
+	}
+	b.index, err = bleve.Open(b.path)
+	if err != nil {
+		return err
+	}
+	b.fresh = false
+
+	if lastQueueID != nil {
+		_, err = q.Exec(ctx, `DELETE FROM search_index_queue WHERE queue_id <= $1`, *lastQueueID)
+	}
+	return err
+}
+
+func (b *Bleve) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.index.Close()
+}
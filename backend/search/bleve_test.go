@@ -0,0 +1,118 @@
+package search
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/minrui13/backend/types"
+)
+
+// in memory index with the documents indexPosts would write
+func testIndex(t *testing.T) bleve.Index {
+	t.Helper()
+	index, err := bleve.NewMemOnly(indexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { index.Close() })
+
+	documents := map[string]map[string]any{
+		"1": {"title": "Go channels explained", "content": "Buffered and unbuffered channels", "topic_id": float64(1), "author_id": float64(10),
+			"created_date": time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC), "is_nsfw": false},
+		"2": {"title": "Rust ownership", "content": "Borrowing instead of channels", "topic_id": float64(2), "author_id": float64(11),
+			"created_date": time.Date(2026, 1, 20, 12, 0, 0, 0, time.UTC), "is_nsfw": false},
+		"3": {"title": "Spicy channels", "content": "Not safe for work", "topic_id": float64(1), "author_id": float64(12),
+			"created_date": time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC), "is_nsfw": true},
+		"4": {"title": "Java streams", "content": "Nothing about the others", "comments": "what about go channels", "topic_id": float64(3), "author_id": float64(10),
+			"created_date": time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC), "is_nsfw": false},
+	}
+	for id, document := range documents {
+		if err := index.Index(id, document); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return index
+}
+
+// ids of the matching posts, best match first
+func search(t *testing.T, index bleve.Index, input string, filter types.SearchFilter, showNSFW bool) []string {
+	t.Helper()
+	request := bleve.NewSearchRequestOptions(searchQuery(parseTerms(input), filter, showNSFW), 10, 0, false)
+	request.SortBy([]string{"-_score", "-_id"})
+	response, err := index.Search(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, hit := range response.Hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+func TestSearchQuery(t *testing.T) {
+	index := testIndex(t)
+	topicID := 1
+	authorID := 10
+	from := time.Date(2026, 1, 8, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		input    string
+		filter   types.SearchFilter
+		showNSFW bool
+		want     []string
+	}{
+		{name: "nsfw posts are left out by default", input: "channels", want: []string{"1", "2", "4"}},
+		{name: "nsfw posts are shown when the viewer allows them", input: "channels", showNSFW: true, want: []string{"1", "3", "2", "4"}},
+		{name: "typos are tolerated", input: "chanels", want: []string{"1", "2", "4"}},
+		{name: "short words must match exactly", input: "og", want: []string{}},
+		{name: "excluded words", input: "channels -rust", want: []string{"1", "4"}},
+		{name: "either group", input: "ownership OR streams", want: []string{"2", "4"}},
+		{name: "prefix", input: "own*", want: []string{"2"}},
+		{name: "topic filter", input: "channels", filter: types.SearchFilter{Topic_ID: &topicID}, showNSFW: true, want: []string{"1", "3"}},
+		{name: "author filter", input: "channels", filter: types.SearchFilter{Author_ID: &authorID}, want: []string{"1", "4"}},
+		{name: "to date is inclusive", input: "channels", filter: types.SearchFilter{From: &from, To: &to}, showNSFW: true, want: []string{"1", "3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//only which posts match is tested here, not their order
+			got := search(t, index, tt.input, tt.filter, tt.showNSFW)
+			slices.Sort(got)
+			slices.Sort(tt.want)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("search %q = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchQueryRanksTitlesFirst(t *testing.T) {
+	got := search(t, testIndex(t), "channels", types.SearchFilter{}, true)
+	if len(got) == 0 || (got[0] != "1" && got[0] != "3") {
+		t.Errorf("search channels = %v, want a title match first", got)
+	}
+}
+
+func TestFuzziness(t *testing.T) {
+	tests := []struct {
+		word string
+		want int
+	}{
+		{"go", 0},
+		{"sql", 1},
+		{"rust", 1},
+		{"async", 2},
+		{"naïve", 2},
+	}
+
+	for _, tt := range tests {
+		if got := fuzziness(tt.word); got != tt.want {
+			t.Errorf("fuzziness(%q) = %d, want %d", tt.word, got, tt.want)
+		}
+	}
+}
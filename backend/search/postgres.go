@@ -0,0 +1,144 @@
+package search
+
+import (
+	"context"
+	"html"
+	"strings"
+	"time"
+
+	db "github.com/minrui13/backend/database"
+	"github.com/minrui13/backend/nsfw"
+	"github.com/minrui13/backend/types"
+)
+
+// search with the tsvector columns, nothing to keep up to date
+type Postgres struct{}
+
+// ts_headline wraps matches in these, they are swapped for <mark> after the text is html escaped
+// private use characters so they cannot clash with anything a user writes
+const (
+	startMark = "\ue000"
+	stopMark  = "\ue001"
+)
+
+var (
+	titleHeadline   = "StartSel=" + startMark + ", StopSel=" + stopMark + ", HighlightAll=true"
+	contentHeadline = "StartSel=" + startMark + ", StopSel=" + stopMark + `, MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=" ... "`
+)
+
+// titles weigh more than content and content more than comments (see the 010 migration)
+// a post found through a comment ranks by its own match plus its best comment's
+func (Postgres) SearchPosts(ctx context.Context, q db.Querier, filter types.SearchFilter) ([]types.PostSearchResult, error) {
+	tsquery := ParseQuery(filter.Query)
+	if tsquery == "" {
+		return nil, ErrEmptyQuery
+	}
+
+	var cursorRank *float64
+	var cursorPostID *int
+	if filter.Cursor != nil {
+		cursorRank = &filter.Cursor.Rank
+		cursorPostID = &filter.Cursor.Post_ID
+	}
+
+	//rank is computed once in page and compared as float8 so the cursor round trips exactly
+	//headlines are built in the outer query so only the returned page pays for them
+	rows, err := q.Query(ctx,
+		`WITH query AS (
+			SELECT to_tsquery('english', $1) AS q
+		),
+		post_hits AS (
+			SELECT p.post_id, ts_rank_cd(p.search_vector, query.q, 1) AS rank
+			FROM posts p, query
+			WHERE p.search_vector @@ query.q
+		),
+		comment_hits AS (
+			SELECT DISTINCT ON (pc.post_id) pc.post_id, pc.comment_id, pc.content, ts_rank_cd(pc.search_vector, query.q, 1) AS rank
+			FROM posts_comments pc, query
+			WHERE pc.search_vector @@ query.q
+			ORDER BY pc.post_id, rank DESC, pc.comment_id
+		),
+		page AS (
+			SELECT p.post_id, ch.comment_id, ch.content AS comment_content,
+				(COALESCE(ph.rank, 0) + COALESCE(ch.rank, 0))::float8 AS rank
+			FROM post_hits ph
+			FULL JOIN comment_hits ch ON ch.post_id = ph.post_id
+			INNER JOIN posts p ON p.post_id = COALESCE(ph.post_id, ch.post_id)
+			INNER JOIN topics t ON t.topic_id = p.topic_id
+			WHERE t.visibility = 'public' AND p.status = 'published' AND p.deleted_date IS NULL
+			AND ($2::int IS NULL OR p.topic_id = $2)
+			AND ($3::int IS NULL OR p.tag_id = $3)
+			AND ($4::int IS NULL OR p.author_id = $4)
+			AND ($5::date IS NULL OR p.created_date >= $5)
+			AND ($6::date IS NULL OR p.created_date < $6 + 1)`+nsfw.VisibleWhere(12)+`
+			AND ($7::float8 IS NULL OR ((COALESCE(ph.rank, 0) + COALESCE(ch.rank, 0))::float8, p.post_id) < ($7, $8::int))
+			ORDER BY rank DESC, p.post_id DESC
+			LIMIT $9
+		)
+		SELECT p.post_id, p.post_url, u.user_id, u.username, u.display_name, i.image_name,
+			t.topic_id, t.topic_name, t.topic_url, tags.tag_name, p.title, p.created_date,
+			p.upvote_count - p.downvote_count AS sum_of_votes,
+			p.comment_count AS num_of_comments,
+			page.rank,
+			ts_headline('english', p.title, query.q, $10),
+			ts_headline('english', p.content, query.q, $11),
+			page.comment_id,
+			CASE WHEN page.comment_id IS NULL THEN NULL ELSE ts_headline('english', page.comment_content, query.q, $11) END
+		FROM page
+		CROSS JOIN query
+		INNER JOIN posts p ON p.post_id = page.post_id
+		LEFT JOIN tags ON tags.tag_id = p.tag_id
+		INNER JOIN users u ON u.user_id = p.author_id
+		INNER JOIN profile_image i ON u.image_id = i.image_id
+		INNER JOIN topics t ON t.topic_id = p.topic_id
+		ORDER BY page.rank DESC, p.post_id DESC`,
+		tsquery, filter.Topic_ID, filter.Tag_ID, filter.Author_ID, filter.From, filter.To,
+		cursorRank, cursorPostID, filter.Limit, titleHeadline, contentHeadline, filter.Viewer_ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resultArr := []types.PostSearchResult{}
+	for rows.Next() {
+		var result types.PostSearchResult
+		var created time.Time
+		if err := rows.Scan(&result.Post_ID, &result.Post_URL, &result.User_ID, &result.Username, &result.DisplayName, &result.User_Image,
+			&result.Topic_ID, &result.Topic_Name, &result.Topic_URL, &result.Tag_Name, &result.Title, &created,
+			&result.Sum_Votes, &result.Comment_Count, &result.Rank,
+			&result.Title_Highlight, &result.Snippet, &result.Comment_ID, &result.Comment_Snippet); err != nil {
+			return nil, err
+		}
+		result.Created_Date = created.Format(time.RFC3339)
+		result.Title_Highlight = highlight(result.Title_Highlight)
+		result.Snippet = highlight(result.Snippet)
+		if result.Comment_Snippet != nil {
+			snippet := highlight(*result.Comment_Snippet)
+			result.Comment_Snippet = &snippet
+		}
+		resultArr = append(resultArr, result)
+	}
+	return resultArr, rows.Err()
+}
+
+// the columns are generated so queued changes only need clearing
+func (Postgres) Sync(ctx context.Context, q db.Querier) error {
+	_, err := q.Exec(ctx, `DELETE FROM search_index_queue`)
+	return err
+}
+
+// nothing to rebuild, the columns are generated
+func (Postgres) Reindex(ctx context.Context, q db.Querier) error {
+	return nil
+}
+
+func (Postgres) Close() error {
+	return nil
+}
+
+// escape a headline for html and turn the match markers into <mark> tags
+func highlight(headline string) string {
+	headline = html.EscapeString(headline)
+	headline = strings.ReplaceAll(headline, startMark, "<mark>")
+	return strings.ReplaceAll(headline, stopMark, "</mark>")
+}
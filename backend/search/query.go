@@ -0,0 +1,120 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// one word or quoted phrase of the search box
+type term struct {
+	words []string
+	//last word matches as a prefix
+	prefix bool
+	negate bool
+}
+
+// split search box input into groups separated by OR
+// every term of a group must match, any group may match
+//
+//	go channels       -> go, channels
+//	"error handling"  -> phrase error handling
+//	chan*             -> prefix chan
+//	-java             -> excluded java
+//	go OR rust        -> two groups
+//
+// anything that is not a letter or digit is dropped
+// groups of only exclusions are dropped too, they would match almost every post
+func parseTerms(input string) [][]term {
+	var groups [][]term
+	var group []term
+	hasPositive := false
+	endGroup := func() {
+		if hasPositive {
+			groups = append(groups, group)
+		}
+		group = nil
+		hasPositive = false
+	}
+
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		negate := false
+		if runes[i] == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			negate = true
+			i++
+		}
+
+		//a phrase runs to the closing quote, a word to the next space or quote
+		var raw string
+		quoted := runes[i] == '"'
+		if quoted {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			raw = string(runes[i+1 : end])
+			i = end + 1
+		} else {
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '"' {
+				end++
+			}
+			raw = string(runes[i:end])
+			i = end
+		}
+
+		if !quoted && !negate && raw == "OR" {
+			endGroup()
+			continue
+		}
+
+		words := strings.FieldsFunc(raw, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(words) == 0 {
+			continue
+		}
+		group = append(group, term{words: words, prefix: strings.HasSuffix(raw, "*"), negate: negate})
+		if !negate {
+			hasPositive = true
+		}
+	}
+	endGroup()
+
+	return groups
+}
+
+// turn search box input into a to_tsquery expression, see parseTerms
+//
+//	go channels            -> go & channels
+//	"error handling" -java -> (error <-> handling) & !java
+//	chan* OR rust          -> chan:* | rust
+//
+// empty when there is no word to look for
+func ParseQuery(input string) string {
+	groupArr := []string{}
+	for _, group := range parseTerms(input) {
+		termArr := []string{}
+		for _, t := range group {
+			words := append([]string{}, t.words...)
+			if t.prefix {
+				words[len(words)-1] += ":*"
+			}
+			expression := words[0]
+			if len(words) > 1 {
+				expression = "(" + strings.Join(words, " <-> ") + ")"
+			}
+			if t.negate {
+				expression = "!" + expression
+			}
+			termArr = append(termArr, expression)
+		}
+		groupArr = append(groupArr, strings.Join(termArr, " & "))
+	}
+	return strings.Join(groupArr, " | ")
+}
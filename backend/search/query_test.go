@@ -0,0 +1,31 @@
+package search
+
+import "testing"
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"go channels", "go & channels"},
+		{`"error handling" -java`, "(error <-> handling) & !java"},
+		{"chan* OR rust", "chan:* | rust"},
+		{`"select stat*"`, "(select <-> stat:*)"},
+		{"go OR -java", "go"},
+		{"-java", ""},
+		{"OR", ""},
+		{"", ""},
+		{"   ", ""},
+		{"c++ & go's", "c & (go <-> s)"},
+		{"'; DROP TABLE posts; --", "DROP & TABLE & posts"},
+		{"or lowercase", "or & lowercase"},
+		{`"unclosed phrase`, "(unclosed <-> phrase)"},
+		{"café naïve", "café & naïve"},
+	}
+
+	for _, tt := range tests {
+		if got := ParseQuery(tt.input); got != tt.want {
+			t.Errorf("ParseQuery(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Full text search over posts and their comments
+// the backend is picked with SEARCH_BACKEND, postgres text search or an embedded bleve index
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	db "github.com/minrui13/backend/database"
+	"github.com/minrui13/backend/jobs"
+	"github.com/minrui13/backend/types"
+)
+
+// backends for SEARCH_BACKEND
+const (
+	BackendPostgres = "postgres"
+	BackendBleve    = "bleve"
+)
+
+var ErrEmptyQuery = errors.New("search must contain at least one word")
+
+type Engine interface {
+	// published posts matching the filter, best match first
+	// pass limit + 1 to know if there is another page
+	SearchPosts(ctx context.Context, q db.Querier, filter types.SearchFilter) ([]types.PostSearchResult, error)
+	// apply the post, comment and topic changes queued by the triggers of the 011 migration
+	Sync(ctx context.Context, q db.Querier) error
+	// rebuild everything from the database
+	Reindex(ctx context.Context, q db.Querier) error
+	Close() error
+}
+
+// open the engine for the configured backend
+// indexPath is only used by bleve
+func New(backend string, indexPath string) (Engine, error) {
+	switch backend {
+	case "", BackendPostgres:
+		return Postgres{}, nil
+	case BackendBleve:
+		return OpenBleve(indexPath)
+	default:
+		return nil, fmt.Errorf("unknown search backend %q", backend)
+	}
+}
+
+// keep the engine up to date with the queued changes
+func SyncJob(engine Engine) jobs.Job {
+	return jobs.Job{
+		Name:     "search index sync",
+		Interval: 30 * time.Second,
+		Run: func(ctx context.Context, pool *pgxpool.Pool) error {
+			return engine.Sync(ctx, pool)
+		},
+	}
+}
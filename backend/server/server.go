@@ -1,34 +1,51 @@
 package server
 
 import (
+	"context"
 	"log"
 	"net/http"
 
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minrui13/backend/analytics"
 	"github.com/minrui13/backend/auth"
+	"github.com/minrui13/backend/config"
+	"github.com/minrui13/backend/counters"
+	"github.com/minrui13/backend/feed"
+	"github.com/minrui13/backend/jobs"
+	"github.com/minrui13/backend/links"
+	"github.com/minrui13/backend/markdown"
 	cors "github.com/minrui13/backend/middleware"
+	"github.com/minrui13/backend/publishing"
+	"github.com/minrui13/backend/retention"
 	commentsVotesRoute "github.com/minrui13/backend/router/comment_votes"
 	commentsRouter "github.com/minrui13/backend/router/comments"
 	imagesRoute "github.com/minrui13/backend/router/images"
 	postBookmarkRoute "github.com/minrui13/backend/router/post_bookmarks"
 	postVotesRoute "github.com/minrui13/backend/router/post_votes"
 	postsRoute "github.com/minrui13/backend/router/posts"
+	shareRoute "github.com/minrui13/backend/router/share"
 	tagsRoute "github.com/minrui13/backend/router/tags"
+	topicModerationRoute "github.com/minrui13/backend/router/topic_moderation"
 	topicsRoute "github.com/minrui13/backend/router/topics"
 	usersRoute "github.com/minrui13/backend/router/users"
+	"github.com/minrui13/backend/search"
+	"github.com/minrui13/backend/trending"
+	"github.com/minrui13/backend/views"
 )
 
 type APIServer struct {
-	addr string
-	db   *pgxpool.Pool
+	addr   string
+	db     *pgxpool.Pool
+	search search.Engine
 }
 
 // managing server
-func NewServer(addr string, db *pgxpool.Pool) *APIServer {
+func NewServer(addr string, db *pgxpool.Pool, searchEngine search.Engine) *APIServer {
 	return &APIServer{
-		addr: addr,
-		db:   db,
+		addr:   addr,
+		db:     db,
+		search: searchEngine,
 	}
 }
 
@@ -39,17 +56,31 @@ func (s *APIServer) Run() error {
 	newRouter.Methods(http.MethodOptions).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
+	//views are buffered here and written by the flush job
+	viewRecorder := views.NewRecorder()
+	//home feed weights from .env over the defaults
+	feedWeights, err := feed.ParseWeights(config.Envs.FeedWeights)
+	if err != nil {
+		return err
+	}
+	homeFeed := feed.New(feed.Sources, feedWeights, int(config.Envs.FeedMaxPerTopic))
 	subrouter := newRouter.PathPrefix("/api").Subrouter()
 	subrouter.HandleFunc("/verifyToken", auth.VerifyToken).Methods("POST")
 	usersRoute.NewHandler(s.db).Router(subrouter.PathPrefix("/users").Subrouter())
 	imagesRoute.NewHandler(s.db).Router(subrouter.PathPrefix("/images").Subrouter())
 	topicsRoute.NewHandler(s.db).Router(subrouter.PathPrefix("/topics").Subrouter())
-	postsRoute.NewHandler(s.db).Router(subrouter.PathPrefix("/posts").Subrouter())
+	postsRoute.NewHandler(s.db, s.search, viewRecorder, homeFeed, links.NewClient()).Router(subrouter.PathPrefix("/posts").Subrouter())
 	postVotesRoute.NewHandler(s.db).Router(subrouter.PathPrefix("/postVotes").Subrouter())
 	postBookmarkRoute.NewHandler(s.db).Router(subrouter.PathPrefix("/postBookmarks").Subrouter())
 	commentsRouter.NewHandler(s.db).Router(subrouter.PathPrefix("/comments").Subrouter())
 	commentsVotesRoute.NewHandler(s.db).Router(subrouter.PathPrefix("/commentVotes").Subrouter())
 	tagsRoute.NewHandler(s.db).Router(subrouter.PathPrefix("/tags").Subrouter())
+	topicModerationRoute.NewHandler(s.db).Router(subrouter.PathPrefix("/topicModeration").Subrouter())
+	//share links redirect to the frontend so they are not under /api
+	shareRoute.NewHandler(s.db).Router(newRouter.PathPrefix("/share").Subrouter())
+
+	//background jobs stop with the process
+	jobs.Start(context.Background(), s.db, analytics.RollupJob, publishing.PublishJob, retention.PurgeJob, markdown.RenderJob, search.SyncJob(s.search), counters.ReconcileJob, views.FlushJob(viewRecorder), trending.RefreshJob, feed.CleanupJob)
 
 	log.Println("Listening on", s.addr)
 
@@ -0,0 +1,116 @@
+// URL safe slugs generated from titles
+package slug
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+
+	db "github.com/minrui13/backend/database"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// longest slug before the random suffix
+	MaxLength = 80
+	// slug used when nothing is left of the title, e.g. titles in non latin scripts
+	fallback     = "post"
+	suffixLength = 6
+	suffixChars  = "abcdefghijklmnopqrstuvwxyz0123456789"
+	// attempts to find a free suffix before giving up
+	maxAttempts = 5
+	// attempts to store a new post when other posts keep taking its url first
+	InsertAttempts = 3
+)
+
+var ErrTaken = errors.New("other posts kept taking the url of the post, please try again")
+
+var validSlug = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// letters that do not decompose into a base letter and accents
+var replacer = strings.NewReplacer(
+	"ß", "ss", "æ", "ae", "Æ", "ae", "œ", "oe", "Œ", "oe", "ø", "o", "Ø", "o",
+	"đ", "d", "Đ", "d", "ł", "l", "Ł", "l", "þ", "th", "Þ", "th", "ð", "d", "Ð", "d",
+	"&", " and ", "@", " at ",
+)
+
+// lowercase ascii words joined by hyphens, accents are dropped (é -> e)
+func Make(title string) string {
+	//split letters from their accents and drop the accents
+	stripAccents := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	ascii, _, err := transform.String(stripAccents, replacer.Replace(title))
+	if err != nil {
+		ascii = title
+	}
+
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(ascii) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			hyphen = false
+		} else if !hyphen && b.Len() > 0 {
+			b.WriteByte('-')
+			hyphen = true
+		}
+	}
+	s := strings.TrimSuffix(b.String(), "-")
+
+	//cut at the last whole word that fits
+	if len(s) > MaxLength {
+		s = s[:MaxLength]
+		if i := strings.LastIndexByte(s, '-'); i > MaxLength/2 {
+			s = s[:i]
+		}
+		s = strings.TrimSuffix(s, "-")
+	}
+
+	if s == "" {
+		return fallback
+	}
+	return s
+}
+
+// check a slug sent by the client
+func IsValid(s string) bool {
+	return len(s) <= MaxLength+suffixLength+1 && validSlug.MatchString(s)
+}
+
+// short random suffix to make a taken slug unique
+func Suffix() string {
+	b := make([]byte, suffixLength)
+	rand.Read(b)
+	for i := range b {
+		b[i] = suffixChars[int(b[i])%len(suffixChars)]
+	}
+	return string(b)
+}
+
+// slug for a new post that is not used by any post, current or old url
+// post urls are looked up without the topic so they are unique across topics too
+// the unique index can still reject it if another post takes it first
+// callers retry on 23505 up to InsertAttempts times, then answer with ErrTaken
+func ForPost(ctx context.Context, q db.Querier, title string) (string, error) {
+	base := Make(title)
+	candidate := base
+	for range maxAttempts {
+		var taken bool
+		err := q.QueryRow(ctx,
+			`SELECT EXISTS (SELECT 1 FROM posts WHERE post_url = $1)
+			OR EXISTS (SELECT 1 FROM posts_url_history WHERE old_url = $1)`,
+			candidate).Scan(&taken)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+		candidate = base + "-" + Suffix()
+	}
+	return candidate, nil
+}
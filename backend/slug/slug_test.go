@@ -0,0 +1,89 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Hello World", "hello-world"},
+		{"  Go 1.25 is out!  ", "go-1-25-is-out"},
+		{"Café crème brûlée", "cafe-creme-brulee"},
+		{"Straße & Smørrebrød", "strasse-and-smorrebrod"},
+		{"email me @ home", "email-me-at-home"},
+		{"---dashes---everywhere---", "dashes-everywhere"},
+		{"日本語のタイトル", "post"},
+		{"", "post"},
+	}
+
+	for _, tt := range tests {
+		if got := Make(tt.title); got != tt.want {
+			t.Errorf("Make(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestMakeCutsLongTitlesAtAWord(t *testing.T) {
+	title := strings.Repeat("word ", 30)
+	got := Make(title)
+	if len(got) > MaxLength {
+		t.Fatalf("Make(long title) is %d characters, want at most %d", len(got), MaxLength)
+	}
+	if !IsValid(got) {
+		t.Fatalf("Make(long title) = %q is not a valid slug", got)
+	}
+	for _, word := range strings.Split(got, "-") {
+		if word != "word" {
+			t.Fatalf("Make(long title) = %q cuts a word", got)
+		}
+	}
+
+	//a single word longer than the limit is cut where it has to be
+	long := strings.Repeat("a", MaxLength+10)
+	if got := Make(long); got != long[:MaxLength] {
+		t.Errorf("Make(%d letters) = %q, want the first %d", len(long), got, MaxLength)
+	}
+}
+
+func TestIsValid(t *testing.T) {
+	tests := []struct {
+		slug string
+		want bool
+	}{
+		{"hello-world", true},
+		{"hello-world-a1b2c3", true},
+		{"abc", true},
+		{"Hello-World", false},
+		{"hello--world", false},
+		{"-hello", false},
+		{"hello-", false},
+		{"hello world", false},
+		{"", false},
+		{strings.Repeat("a", MaxLength+suffixLength+1), true},
+		{strings.Repeat("a", MaxLength+suffixLength+2), false},
+	}
+
+	for _, tt := range tests {
+		if got := IsValid(tt.slug); got != tt.want {
+			t.Errorf("IsValid(%q) = %v, want %v", tt.slug, got, tt.want)
+		}
+	}
+}
+
+func TestSuffix(t *testing.T) {
+	seen := map[string]bool{}
+	for range 20 {
+		s := Suffix()
+		if len(s) != suffixLength || strings.Trim(s, suffixChars) != "" {
+			t.Fatalf("Suffix() = %q, want %d of %q", s, suffixLength, suffixChars)
+		}
+		seen[s] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("Suffix() returned the same suffix 20 times")
+	}
+}
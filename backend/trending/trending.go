@@ -0,0 +1,213 @@
+// Trending topics and tags, by how much faster they gain follows, posts and votes than usual
+// scores are cached in topics_trending and tags_trending and recomputed by the refresh job
+package trending
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	db "github.com/minrui13/backend/database"
+	"github.com/minrui13/backend/jobs"
+	"github.com/minrui13/backend/types"
+)
+
+const (
+	DefaultLimit = 10
+	MaxLimit     = 50
+)
+
+// the window slides with every refresh, the baseline is the days right before it
+const (
+	window       = "1 day"
+	baselineDays = 7
+)
+
+// weight of each kind of activity, a follow says more than a vote
+const (
+	followWeight = 3
+	postWeight   = 2
+	voteWeight   = 1
+)
+
+// rise of an activity over its usual rate in the window
+// the difference to the expected count is divided by its poisson deviation
+// so one new follow in a quiet topic does not outrank a busy topic doubling
+func velocity(recent string, baseline string) string {
+	return fmt.Sprintf(`((%[1]s - %[2]s::float8 / %[3]d) / SQRT(%[2]s::float8 / %[3]d + 1))`, recent, baseline, baselineDays)
+}
+
+// activity of public topics from the start of the baseline, split into the window and the baseline
+// follows and votes from before the 005 migration have no date and are left out by the comparisons
+var (
+	since    = fmt.Sprintf(`LOCALTIMESTAMP - INTERVAL '%s' - INTERVAL '%d days'`, window, baselineDays)
+	inWindow = fmt.Sprintf(`a.created_date > LOCALTIMESTAMP - INTERVAL '%s'`, window)
+)
+
+var RefreshJob = jobs.Job{
+	Name:     "trending refresh",
+	Interval: 10 * time.Minute,
+	Run: func(ctx context.Context, pool *pgxpool.Pool) error {
+		tx, err := pool.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		if err := Refresh(ctx, tx); err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	},
+}
+
+// recompute the trending topics and tags, only those rising above their baseline are kept
+// run it in a transaction so readers never see the tables empty
+func Refresh(ctx context.Context, q db.Querier) error {
+	if _, err := q.Exec(ctx, `DELETE FROM topics_trending`); err != nil {
+		return err
+	}
+	_, err := q.Exec(ctx,
+		`WITH activity AS (
+			SELECT tf.topic_id, 'follow' AS kind, tf.created_date FROM topics_followers tf
+			WHERE tf.created_date > `+since+`
+			UNION ALL
+			SELECT p.topic_id, 'post' AS kind, p.created_date FROM posts p
+			WHERE p.status = 'published' AND p.deleted_date IS NULL AND p.created_date > `+since+`
+			UNION ALL
+			SELECT p.topic_id, 'vote' AS kind, pv.created_date FROM posts_votes pv
+			INNER JOIN posts p ON p.post_id = pv.post_id
+			WHERE p.status = 'published' AND p.deleted_date IS NULL AND pv.created_date > `+since+`
+		),
+		counts AS (
+			SELECT a.topic_id,
+				COUNT(*) FILTER (WHERE a.kind = 'follow' AND `+inWindow+`) AS follows,
+				COUNT(*) FILTER (WHERE a.kind = 'post' AND `+inWindow+`) AS posts,
+				COUNT(*) FILTER (WHERE a.kind = 'vote' AND `+inWindow+`) AS votes,
+				COUNT(*) FILTER (WHERE a.kind = 'follow' AND NOT `+inWindow+`) AS baseline_follows,
+				COUNT(*) FILTER (WHERE a.kind = 'post' AND NOT `+inWindow+`) AS baseline_posts,
+				COUNT(*) FILTER (WHERE a.kind = 'vote' AND NOT `+inWindow+`) AS baseline_votes
+			FROM activity a
+			INNER JOIN topics t ON t.topic_id = a.topic_id
+			WHERE t.visibility = 'public' AND t.archived_date IS NULL
+			GROUP BY a.topic_id
+		),
+		scored AS (
+			SELECT c.*, (`+fmt.Sprintf("%d * %s + %d * %s + %d * %s",
+			followWeight, velocity("c.follows", "c.baseline_follows"),
+			postWeight, velocity("c.posts", "c.baseline_posts"),
+			voteWeight, velocity("c.votes", "c.baseline_votes"))+`)::float8 AS score
+			FROM counts c
+		)
+		INSERT INTO topics_trending (topic_id, follows, posts, votes, baseline_follows, baseline_posts, baseline_votes, score)
+		SELECT topic_id, follows, posts, votes, baseline_follows, baseline_posts, baseline_votes, score
+		FROM scored
+		WHERE score > 0 AND follows + posts + votes > 0`)
+	if err != nil {
+		return err
+	}
+
+	//tags are not followed, they trend by the posts tagged with them and the votes on those
+	if _, err := q.Exec(ctx, `DELETE FROM tags_trending`); err != nil {
+		return err
+	}
+	_, err = q.Exec(ctx,
+		`WITH activity AS (
+			SELECT p.tag_id, 'post' AS kind, p.created_date FROM posts p
+			INNER JOIN topics t ON t.topic_id = p.topic_id
+			WHERE t.visibility = 'public' AND p.tag_id IS NOT NULL
+			AND p.status = 'published' AND p.deleted_date IS NULL AND p.created_date > `+since+`
+			UNION ALL
+			SELECT p.tag_id, 'vote' AS kind, pv.created_date FROM posts_votes pv
+			INNER JOIN posts p ON p.post_id = pv.post_id
+			INNER JOIN topics t ON t.topic_id = p.topic_id
+			WHERE t.visibility = 'public' AND p.tag_id IS NOT NULL
+			AND p.status = 'published' AND p.deleted_date IS NULL AND pv.created_date > `+since+`
+		),
+		counts AS (
+			SELECT a.tag_id,
+				COUNT(*) FILTER (WHERE a.kind = 'post' AND `+inWindow+`) AS posts,
+				COUNT(*) FILTER (WHERE a.kind = 'vote' AND `+inWindow+`) AS votes,
+				COUNT(*) FILTER (WHERE a.kind = 'post' AND NOT `+inWindow+`) AS baseline_posts,
+				COUNT(*) FILTER (WHERE a.kind = 'vote' AND NOT `+inWindow+`) AS baseline_votes
+			FROM activity a
+			GROUP BY a.tag_id
+		),
+		scored AS (
+			SELECT c.*, (`+fmt.Sprintf("%d * %s + %d * %s",
+			postWeight, velocity("c.posts", "c.baseline_posts"),
+			voteWeight, velocity("c.votes", "c.baseline_votes"))+`)::float8 AS score
+			FROM counts c
+		)
+		INSERT INTO tags_trending (tag_id, posts, votes, baseline_posts, baseline_votes, score)
+		SELECT tag_id, posts, votes, baseline_posts, baseline_votes, score
+		FROM scored
+		WHERE score > 0 AND posts + votes > 0`)
+	return err
+}
+
+// the top trending topics, nsfw topics follow the viewer's preferences as in the feeds
+func Topics(ctx context.Context, q db.Querier, userID int, limit int) ([]types.TrendingTopic, error) {
+	rows, err := q.Query(ctx,
+		`SELECT t.topic_id, t.topic_name, t.topic_url, t.description, c.category_name, c.icon_name,
+			(SELECT COUNT(*) FROM topics_followers f WHERE f.topic_id = t.topic_id) AS followers_count,
+			EXISTS (SELECT 1 FROM topics_followers f WHERE f.topic_id = t.topic_id AND f.user_id = $1) AS is_following,
+			t.is_nsfw,
+			tt.follows, tt.posts, tt.votes, tt.score, tt.updated_date
+		FROM topics_trending tt
+		INNER JOIN topics t ON t.topic_id = tt.topic_id
+		INNER JOIN categories c ON c.category_id = t.category_id
+		WHERE t.visibility = 'public' AND t.archived_date IS NULL
+		AND (NOT t.is_nsfw OR EXISTS (SELECT 1 FROM users nu WHERE nu.user_id = $1 AND nu.nsfw_preference <> 'hide'))
+		ORDER BY tt.score DESC, tt.topic_id DESC
+		LIMIT $2`,
+		userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	topicsArr := []types.TrendingTopic{}
+	for rows.Next() {
+		var topic types.TrendingTopic
+		var updated time.Time
+		if err := rows.Scan(&topic.Topic_ID, &topic.Topic_Name, &topic.Topic_URL, &topic.Description, &topic.Category_Name, &topic.Category_Icon,
+			&topic.Followers_Count, &topic.Is_Following, &topic.Is_NSFW,
+			&topic.New_Followers, &topic.Posts, &topic.Votes, &topic.Score, &updated); err != nil {
+			return nil, err
+		}
+		topic.Updated_Date = updated.Format(time.RFC3339)
+		topicsArr = append(topicsArr, topic)
+	}
+	return topicsArr, rows.Err()
+}
+
+// the top trending tags
+func Tags(ctx context.Context, q db.Querier, limit int) ([]types.TrendingTag, error) {
+	rows, err := q.Query(ctx,
+		`SELECT tags.tag_id, tags.tag_name, tags.description, tags.icon_name,
+			tt.posts, tt.votes, tt.score, tt.updated_date
+		FROM tags_trending tt
+		INNER JOIN tags ON tags.tag_id = tt.tag_id
+		ORDER BY tt.score DESC, tt.tag_id DESC
+		LIMIT $1`,
+		limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tagsArr := []types.TrendingTag{}
+	for rows.Next() {
+		var tag types.TrendingTag
+		var updated time.Time
+		if err := rows.Scan(&tag.Tag_ID, &tag.Tag_Name, &tag.Description, &tag.Tag_Icon,
+			&tag.Posts, &tag.Votes, &tag.Score, &updated); err != nil {
+			return nil, err
+		}
+		tag.Updated_Date = updated.Format(time.RFC3339)
+		tagsArr = append(tagsArr, tag)
+	}
+	return tagsArr, rows.Err()
+}
@@ -0,0 +1,46 @@
+package trending
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/minrui13/backend/database/dbtest"
+)
+
+func TestVelocity(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+
+	score := func(recent int, baseline int) float64 {
+		t.Helper()
+		var got float64
+		if err := pool.QueryRow(ctx, `SELECT `+velocity("$1::int", "$2::int"), recent, baseline).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	for _, c := range []struct {
+		recent, baseline int
+		want             float64
+	}{
+		//nothing happening, and a day at the usual rate, do not rise
+		{0, 0, 0},
+		{10, 70, 0},
+		//a day below the usual rate falls
+		{0, 70, -10 / math.Sqrt(11)},
+		{1, 0, 1},
+		{7, 7, 6 / math.Sqrt(2)},
+		{200, 700, 100 / math.Sqrt(101)},
+	} {
+		if got := score(c.recent, c.baseline); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("velocity of %d against a baseline of %d = %v, want %v", c.recent, c.baseline, got, c.want)
+		}
+	}
+
+	//one follow in a quiet topic does not outrank a busy topic doubling
+	if quiet, busy := score(1, 0), score(200, 700); quiet >= busy {
+		t.Errorf("quiet topic scores %v, busy topic doubling %v, want the busy topic higher", quiet, busy)
+	}
+}
@@ -0,0 +1,37 @@
+package types
+
+type TopicAnalyticsDay struct {
+	Day           string `json:"day"`
+	New_Followers int    `json:"new_followers"`
+	Posts         int    `json:"posts"`
+	Comments      int    `json:"comments"`
+	Votes         int    `json:"votes"`
+	Active_Users  int    `json:"active_users"`
+}
+
+type TopicAnalyticsPost struct {
+	Post_ID       int    `json:"post_id"`
+	Post_URL      string `json:"post_url"`
+	Title         string `json:"title"`
+	Score         int    `json:"score"`
+	Comment_Count int    `json:"comment_count"`
+	Created_Date  string `json:"created_date"`
+}
+
+type TopicAnalyticsContributor struct {
+	User_ID       int    `json:"user_id"`
+	Username      string `json:"username"`
+	Display_Name  string `json:"display_name"`
+	Image_Name    string `json:"image_name"`
+	Post_Count    int    `json:"post_count"`
+	Comment_Count int    `json:"comment_count"`
+}
+
+type TopicAnalyticsResult struct {
+	Topic_ID         int                         `json:"topic_id"`
+	From             string                      `json:"from"`
+	To               string                      `json:"to"`
+	Days             []TopicAnalyticsDay         `json:"days"`
+	Top_Posts        []TopicAnalyticsPost        `json:"top_posts"`
+	Top_Contributors []TopicAnalyticsContributor `json:"top_contributors"`
+}
@@ -10,6 +10,7 @@ type CommentDefaultType struct {
 	Post_User_ID      int    `json:"post_user_id"`
 	Parent_Comment_ID *int   `json:"parent_comment_id"`
 	Content           string `json:"content"`
+	Content_HTML      string `json:"content_html"`
 	Created_Date      string `json:"created_date"`
 	Vote_ID           *int   `json:"vote_id"`
 	Upvote_Count      int    `json:"upvote_count"`
@@ -17,6 +18,8 @@ type CommentDefaultType struct {
 	Sum_Votes         int    `json:"sum_votes"`
 	Vote_Status       int    `json:"vote_status"`
 	Reply_Count       int    `json:"reply_count"`
+	//set when the comment or one above it is locked
+	Is_Locked bool `json:"is_locked"`
 }
 
 type CommentContent struct {
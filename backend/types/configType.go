@@ -5,4 +5,11 @@ type Config struct {
 	DATABASE_URL           string
 	JWTExpirationInSeconds int64
 	JWTSecret              string
+	FRONTEND_URL           string
+	PostEditGraceSeconds   int64
+	PostRetentionDays      int64
+	SearchBackend          string
+	SearchIndexPath        string
+	FeedWeights            string
+	FeedMaxPerTopic        int64
 }
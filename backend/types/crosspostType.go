@@ -0,0 +1,23 @@
+package types
+
+type CrosspostPayload struct {
+	//empty keeps the original's title
+	Title         string `json:"title"`
+	Tag_ID        *int   `json:"tag_id"`
+	Post_Flair_ID *int   `json:"post_flair_id"`
+}
+
+// original post embedded in a crosspost
+type CrosspostOf struct {
+	Post_ID      int    `json:"post_id"`
+	Post_URL     string `json:"post_url"`
+	Title        string `json:"title"`
+	Content      string `json:"content"`
+	Content_HTML string `json:"content_html"`
+	Created_Date string `json:"created_date"`
+	User_ID      int    `json:"user_id"`
+	Username     string `json:"username"`
+	Topic_ID     int    `json:"topic_id"`
+	Topic_Name   string `json:"topic_name"`
+	Topic_URL    string `json:"topic_url"`
+}
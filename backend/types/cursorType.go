@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 type DateUpvotesIDCursor struct {
 	Upvotes_Count int    `json:"upvotes_count"`
 	Created_Date  string `json:"created_date"`
@@ -32,3 +34,52 @@ type TopicDefaultCursor struct {
 	Posts_Count      int    `json:"posts_count"`
 }
 
+
+type DateIDCursor struct {
+	Created_Date string `json:"created_date"`
+	ID           int    `json:"id"`
+}
+
+type RankCursor struct {
+	Rank    float64 `json:"rank"`
+	Post_ID int     `json:"post_id"`
+}
+
+type HotCursor struct {
+	Hot_Score float64 `json:"hot_score"`
+	Post_ID   int     `json:"post_id"`
+}
+
+type TopCursor struct {
+	Sum_Votes_Count int `json:"sum_of_votes"`
+	Comment_Count   int `json:"comment_count"`
+	Post_ID         int `json:"post_id"`
+	//start of the time window on the first page, nil for all time
+	Since *time.Time `json:"since"`
+}
+
+type ControversialCursor struct {
+	Controversy float64 `json:"controversy"`
+	Post_ID     int     `json:"post_id"`
+}
+
+type RisingCursor struct {
+	Rising_Score float64 `json:"rising_score"`
+	Post_ID      int     `json:"post_id"`
+	//scores are taken at the time of the first page so they do not drift between pages
+	As_Of time.Time `json:"as_of"`
+}
+
+type ViewsCursor struct {
+	View_Count int `json:"view_count"`
+	Post_ID    int `json:"post_id"`
+}
+
+type FeedCursor struct {
+	//the ranking of the first page is kept in feed_snapshots so posts do not move between pages
+	Snapshot_ID int64 `json:"snapshot_id"`
+	//position of the last post taken from the snapshot
+	Position int `json:"position"`
+	//candidates above the cursor held back by the per topic limit, shown on a later page
+	Deferred []int `json:"deferred"`
+}
@@ -0,0 +1,16 @@
+package types
+
+// preview of a link post's page, read when the post was added
+// the page fields are null when the page could not be read or did not have them
+type LinkPreview struct {
+	URL    string `json:"url"`
+	Domain string `json:"domain"`
+	//where the url led after redirects
+	Final_URL   *string `json:"final_url"`
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	Image_URL   *string `json:"image_url"`
+	Site_Name   *string `json:"site_name"`
+	//null when the page could not be fetched
+	Fetched_Date *string `json:"fetched_date"`
+}
@@ -0,0 +1,98 @@
+package types
+
+type TopicModerator struct {
+	Topic_ID     int    `json:"topic_id"`
+	User_ID      int    `json:"user_id"`
+	Username     string `json:"username"`
+	Display_Name string `json:"display_name"`
+	Image_Name   string `json:"image_name"`
+	Appointed_By *int   `json:"appointed_by"`
+	Is_Creator   bool   `json:"is_creator"`
+	Created_Date string `json:"created_date"`
+}
+
+type TargetUserPayload struct {
+	Target_User_ID int `json:"target_user_id"`
+}
+
+type TopicBanPayload struct {
+	Target_User_ID int     `json:"target_user_id"`
+	Reason         *string `json:"reason"`
+	//nil for a permanent ban
+	Duration_Hours *int `json:"duration_hours"`
+}
+
+type TopicBan struct {
+	Topic_Ban_ID int     `json:"topic_ban_id"`
+	Topic_ID     int     `json:"topic_id"`
+	User_ID      int     `json:"user_id"`
+	Username     string  `json:"username"`
+	Banned_By    *int    `json:"banned_by"`
+	Reason       *string `json:"reason"`
+	Created_Date string  `json:"created_date"`
+	Expires_Date *string `json:"expires_date"`
+}
+
+type ModerationLogEntry struct {
+	Log_ID             int     `json:"log_id"`
+	Topic_ID           int     `json:"topic_id"`
+	Moderator_ID       *int    `json:"moderator_id"`
+	Moderator_Username *string `json:"moderator_username"`
+	Action             string  `json:"action"`
+	Target_User_ID     *int    `json:"target_user_id"`
+	Target_Post_ID     *int    `json:"target_post_id"`
+	Target_Comment_ID  *int    `json:"target_comment_id"`
+	Details            *string `json:"details"`
+	Created_Date       string  `json:"created_date"`
+}
+
+type TogglePayload struct {
+	Value bool `json:"value"`
+}
+
+type TopicRule struct {
+	Rule_ID  int    `json:"rule_id"`
+	Topic_ID int    `json:"topic_id"`
+	Position int    `json:"position"`
+	Title    string `json:"title"`
+	Body     string `json:"body"`
+}
+
+type TopicRulePayload struct {
+	Position int    `json:"position"`
+	Title    string `json:"title"`
+	Body     string `json:"body"`
+}
+
+type RuleOrderPayload struct {
+	Rule_IDs []int `json:"rule_ids"`
+}
+
+type TopicFlair struct {
+	Flair_ID         int    `json:"flair_id"`
+	Topic_ID         int    `json:"topic_id"`
+	Kind             string `json:"kind"`
+	Text             string `json:"text"`
+	Background_Color string `json:"background_color"`
+	Text_Color       string `json:"text_color"`
+	Position         int    `json:"position"`
+}
+
+type TopicFlairPayload struct {
+	Kind             string `json:"kind"`
+	Text             string `json:"text"`
+	Background_Color string `json:"background_color"`
+	Text_Color       string `json:"text_color"`
+	Position         int    `json:"position"`
+}
+
+type UserFlairPayload struct {
+	//nil to clear the flair
+	Flair_ID *int `json:"flair_id"`
+}
+
+type TopicSidebarPayload struct {
+	Sidebar_Markdown   *string `json:"sidebar_markdown"`
+	Banner_Markdown    *string `json:"banner_markdown"`
+	Require_Post_Flair bool    `json:"require_post_flair"`
+}
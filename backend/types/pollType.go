@@ -0,0 +1,31 @@
+package types
+
+import "time"
+
+type PollPayload struct {
+	Options        []string   `json:"options"`
+	Allow_Multiple bool       `json:"allow_multiple"`
+	Closes_Date    *time.Time `json:"closes_date"`
+}
+
+type PollVotePayload struct {
+	Option_IDs []int `json:"option_ids"`
+}
+
+// poll of a post as seen by the viewer
+// counts are null until the viewer has voted or the poll has closed
+type Poll struct {
+	Allow_Multiple   bool         `json:"allow_multiple"`
+	Closes_Date      *string      `json:"closes_date"`
+	Is_Closed        bool         `json:"is_closed"`
+	Has_Voted        bool         `json:"has_voted"`
+	Voted_Option_IDs []int        `json:"voted_option_ids"`
+	Voter_Count      *int         `json:"voter_count"`
+	Options          []PollOption `json:"options"`
+}
+
+type PollOption struct {
+	Option_ID  int    `json:"option_id"`
+	Text       string `json:"text"`
+	Vote_Count *int   `json:"vote_count"`
+}
@@ -3,105 +3,263 @@ package types
 import "time"
 
 type PostDefaultResult struct {
-	Post_ID         int     `json:"post_id"`
-	Post_URL        string  `json:"post_url"`
-	User_ID         int     `json:"user_id"`
-	Username        string  `json:"username"`
-	DisplayName     string  `json:"display_name"`
-	User_Image      string  `json:"user_image"`
-	Topic_ID        int     `json:"topic_id"`
-	Topic_User_ID   int     `json:"topic_user_id"`
-	Topic_Name      string  `json:"topic_name"`
-	Topic_URL       string  `json:"topic_url"`
-	Category_Icon   string  `json:"category_icon"`
-	Tag_Name        *string `json:"tag_name"`
-	Tag_Icon        *string `json:"tag_icon"`
-	Tag_Description *string `json:"tag_description"`
-	Title           string  `json:"title"`
-	Content         string  `json:"content"`
-	Created_Date    string  `json:"created_date"`
-	Vote_ID         *int    `json:"vote_id"`
-	Upvote_Count    int     `json:"upvote_count"`
-	Downvote_Count  int     `json:"downvote_count"`
-	Sum_Votes       int     `json:"sum_votes"`
-	Vote_Status     int     `json:"vote_status"`
-	Comment_Count   int     `json:"comment_count"`
-	Bookmark_ID     *int    `json:"bookmark_id"`
-	Is_Bookmarked   bool    `json:"is_bookmarked"`
+	Post_ID            int         `json:"post_id"`
+	Post_URL           string      `json:"post_url"`
+	User_ID            int         `json:"user_id"`
+	Username           string      `json:"username"`
+	DisplayName        string      `json:"display_name"`
+	User_Image         string      `json:"user_image"`
+	Topic_ID           int         `json:"topic_id"`
+	Topic_User_ID      int         `json:"topic_user_id"`
+	Topic_Name         string      `json:"topic_name"`
+	Topic_URL          string      `json:"topic_url"`
+	Category_Icon      string      `json:"category_icon"`
+	Tag_Name           *string     `json:"tag_name"`
+	Tag_Icon           *string     `json:"tag_icon"`
+	Tag_Description    *string     `json:"tag_description"`
+	Title              string      `json:"title"`
+	Content            string      `json:"content"`
+	Created_Date       string      `json:"created_date"`
+	Post_Flair         *TopicFlair `json:"post_flair"`
+	User_Flair         *TopicFlair `json:"user_flair"`
+	Post_Type          string      `json:"post_type"`
+	Is_Pinned          bool        `json:"is_pinned"`
+	Is_Announcement    bool        `json:"is_announcement"`
+	Is_Locked          bool        `json:"is_locked"`
+	Is_Topic_Read_Only bool        `json:"is_topic_read_only"`
+	Is_NSFW            bool        `json:"is_nsfw"`
+	Is_Spoiler         bool        `json:"is_spoiler"`
+	//set when the viewer's preferences ask for the post to be blurred
+	Blur_NSFW    bool `json:"blur_nsfw"`
+	Blur_Spoiler bool `json:"blur_spoiler"`
+	//nil unless post_type is poll
+	Poll *Poll `json:"poll"`
+	//nil unless post_type is link
+	Link *LinkPreview `json:"link"`
+	//nil unless the post is a crosspost
+	Crosspost_Of_ID *int `json:"crosspost_of_id"`
+	//nil when the original is no longer published
+	Crosspost_Of    *CrosspostOf `json:"crosspost_of"`
+	Crosspost_Count int          `json:"crosspost_count"`
+	Edited_Date     *string      `json:"edited_date"`
+	Edit_Count      int          `json:"edit_count"`
+	Deleted_By      *string      `json:"deleted_by"`
+	Content_HTML    string       `json:"content_html"`
+	Vote_ID         *int         `json:"vote_id"`
+	Upvote_Count    int          `json:"upvote_count"`
+	Downvote_Count  int          `json:"downvote_count"`
+	Sum_Votes       int          `json:"sum_votes"`
+	Vote_Status     int          `json:"vote_status"`
+	Comment_Count   int          `json:"comment_count"`
+	View_Count      int          `json:"view_count"`
+	Viewer_Count    int          `json:"viewer_count"`
+	Bookmark_ID     *int         `json:"bookmark_id"`
+	Is_Bookmarked   bool         `json:"is_bookmarked"`
+}
+
+type PostDetailResult struct {
+	PostDefaultResult
+	//differs from the requested url when an old url was used
+	Canonical_URL string `json:"canonical_url"`
 }
 
 type PostSumVotesResult struct {
-	Post_ID         int     `json:"post_id"`
-	Post_URL        string  `json:"post_url"`
-	User_ID         int     `json:"user_id"`
-	Username        string  `json:"username"`
-	DisplayName     string  `json:"display_name"`
-	User_Image      string  `json:"user_image"`
-	Topic_ID        int     `json:"topic_id"`
-	Topic_User_ID   int     `json:"topic_user_id"`
-	Topic_Name      string  `json:"topic_name"`
-	Topic_URL      string  `json:"topic_url"`
-	Category_Icon   string  `json:"category_icon"`
-	Tag_Name        *string `json:"tag_name"`
-	Tag_Icon        *string `json:"tag_icon"`
-	Tag_Description *string `json:"tag_description"`
-	Title           string  `json:"title"`
-	Content         string  `json:"content"`
-	Created_Date    string  `json:"created_date"`
-	Vote_ID         *int    `json:"vote_id"`
-	Upvote_Count    int     `json:"upvote_count"`
-	Downvote_Count  int     `json:"downvote_count"`
-	Sum_Votes       int     `json:"sum_votes"`
-	Vote_Status     int     `json:"vote_status"`
-	Comment_Count   int     `json:"comment_count"`
-	Bookmark_ID     *int    `json:"bookmark_id"`
-	Is_Bookmarked   bool    `json:"is_bookmarked"`
+	Post_ID            int         `json:"post_id"`
+	Post_URL           string      `json:"post_url"`
+	User_ID            int         `json:"user_id"`
+	Username           string      `json:"username"`
+	DisplayName        string      `json:"display_name"`
+	User_Image         string      `json:"user_image"`
+	Topic_ID           int         `json:"topic_id"`
+	Topic_User_ID      int         `json:"topic_user_id"`
+	Topic_Name         string      `json:"topic_name"`
+	Topic_URL          string      `json:"topic_url"`
+	Category_Icon      string      `json:"category_icon"`
+	Tag_Name           *string     `json:"tag_name"`
+	Tag_Icon           *string     `json:"tag_icon"`
+	Tag_Description    *string     `json:"tag_description"`
+	Title              string      `json:"title"`
+	Content            string      `json:"content"`
+	Created_Date       string      `json:"created_date"`
+	Post_Flair         *TopicFlair `json:"post_flair"`
+	User_Flair         *TopicFlair `json:"user_flair"`
+	Post_Type          string      `json:"post_type"`
+	Is_Pinned          bool        `json:"is_pinned"`
+	Is_Announcement    bool        `json:"is_announcement"`
+	Is_Locked          bool        `json:"is_locked"`
+	Is_Topic_Read_Only bool        `json:"is_topic_read_only"`
+	Is_NSFW            bool        `json:"is_nsfw"`
+	Is_Spoiler         bool        `json:"is_spoiler"`
+	//set when the viewer's preferences ask for the post to be blurred
+	Blur_NSFW    bool `json:"blur_nsfw"`
+	Blur_Spoiler bool `json:"blur_spoiler"`
+	//nil unless post_type is poll
+	Poll *Poll `json:"poll"`
+	//nil unless post_type is link
+	Link *LinkPreview `json:"link"`
+	//nil unless the post is a crosspost
+	Crosspost_Of_ID *int `json:"crosspost_of_id"`
+	//nil when the original is no longer published
+	Crosspost_Of    *CrosspostOf `json:"crosspost_of"`
+	Crosspost_Count int          `json:"crosspost_count"`
+	Edited_Date     *string      `json:"edited_date"`
+	Edit_Count      int          `json:"edit_count"`
+	Deleted_By      *string      `json:"deleted_by"`
+	Content_HTML    string       `json:"content_html"`
+	Vote_ID         *int         `json:"vote_id"`
+	Upvote_Count    int          `json:"upvote_count"`
+	Downvote_Count  int          `json:"downvote_count"`
+	Sum_Votes       int          `json:"sum_votes"`
+	Vote_Status     int          `json:"vote_status"`
+	Comment_Count   int          `json:"comment_count"`
+	View_Count      int          `json:"view_count"`
+	Viewer_Count    int          `json:"viewer_count"`
+	Bookmark_ID     *int         `json:"bookmark_id"`
+	Is_Bookmarked   bool         `json:"is_bookmarked"`
 }
 
 type PostSumVotesIsFollowingResult struct {
-	Post_ID         int     `json:"post_id"`
-	Post_URL        string  `json:"post_url"`
-	User_ID         int     `json:"user_id"`
-	Username        string  `json:"username"`
-	DisplayName     string  `json:"display_name"`
-	User_Image      string  `json:"user_image"`
-	Topic_ID        int     `json:"topic_id"`
-	Topic_User_ID   int     `json:"topic_user_id"`
-	Topic_Name      string  `json:"topic_name"`
-	Topic_URL      string  `json:"topic_url"`
-	Category_Icon   string  `json:"category_icon"`
-	Tag_Name        *string `json:"tag_name"`
-	Tag_Icon        *string `json:"tag_icon"`
-	Tag_Description *string `json:"tag_description"`
-	Title           string  `json:"title"`
-	Content         string  `json:"content"`
-	Created_Date    string  `json:"created_date"`
-	Vote_ID         *int    `json:"vote_id"`
-	Upvote_Count    int     `json:"upvote_count"`
-	Downvote_Count  int     `json:"downvote_count"`
-	Sum_Votes       int     `json:"sum_votes"`
-	Vote_Status     int     `json:"vote_status"`
-	Comment_Count   int     `json:"comment_count"`
-	Bookmark_ID     *int    `json:"bookmark_id"`
-	Is_Bookmarked   bool    `json:"is_bookmarked"`
-	Is_Following    bool    `json:"is_following"`
+	Post_ID            int         `json:"post_id"`
+	Post_URL           string      `json:"post_url"`
+	User_ID            int         `json:"user_id"`
+	Username           string      `json:"username"`
+	DisplayName        string      `json:"display_name"`
+	User_Image         string      `json:"user_image"`
+	Topic_ID           int         `json:"topic_id"`
+	Topic_User_ID      int         `json:"topic_user_id"`
+	Topic_Name         string      `json:"topic_name"`
+	Topic_URL          string      `json:"topic_url"`
+	Category_Icon      string      `json:"category_icon"`
+	Tag_Name           *string     `json:"tag_name"`
+	Tag_Icon           *string     `json:"tag_icon"`
+	Tag_Description    *string     `json:"tag_description"`
+	Title              string      `json:"title"`
+	Content            string      `json:"content"`
+	Created_Date       string      `json:"created_date"`
+	Post_Flair         *TopicFlair `json:"post_flair"`
+	User_Flair         *TopicFlair `json:"user_flair"`
+	Post_Type          string      `json:"post_type"`
+	Is_Pinned          bool        `json:"is_pinned"`
+	Is_Announcement    bool        `json:"is_announcement"`
+	Is_Locked          bool        `json:"is_locked"`
+	Is_Topic_Read_Only bool        `json:"is_topic_read_only"`
+	Is_NSFW            bool        `json:"is_nsfw"`
+	Is_Spoiler         bool        `json:"is_spoiler"`
+	//set when the viewer's preferences ask for the post to be blurred
+	Blur_NSFW    bool `json:"blur_nsfw"`
+	Blur_Spoiler bool `json:"blur_spoiler"`
+	//nil unless post_type is poll
+	Poll *Poll `json:"poll"`
+	//nil unless post_type is link
+	Link *LinkPreview `json:"link"`
+	//nil unless the post is a crosspost
+	Crosspost_Of_ID *int `json:"crosspost_of_id"`
+	//nil when the original is no longer published
+	Crosspost_Of    *CrosspostOf `json:"crosspost_of"`
+	Crosspost_Count int          `json:"crosspost_count"`
+	Edited_Date     *string      `json:"edited_date"`
+	Edit_Count      int          `json:"edit_count"`
+	Deleted_By      *string      `json:"deleted_by"`
+	Content_HTML    string       `json:"content_html"`
+	Vote_ID         *int         `json:"vote_id"`
+	Upvote_Count    int          `json:"upvote_count"`
+	Downvote_Count  int          `json:"downvote_count"`
+	Sum_Votes       int          `json:"sum_votes"`
+	Vote_Status     int          `json:"vote_status"`
+	Comment_Count   int          `json:"comment_count"`
+	View_Count      int          `json:"view_count"`
+	Viewer_Count    int          `json:"viewer_count"`
+	Bookmark_ID     *int         `json:"bookmark_id"`
+	Is_Bookmarked   bool         `json:"is_bookmarked"`
+	Is_Following    bool         `json:"is_following"`
 }
 
+// created date range of the feeds, both days inclusive, nil leaves that end open
 type PostByFollowPayload struct {
-	From_Date time.Time `json:"from_date"`
-	To_Date   time.Time `json:"to_date"`
+	From_Date *time.Time `json:"from_date"`
+	To_Date   *time.Time `json:"to_date"`
 }
 
 type PostUpdatePayload struct {
-	Tag_ID  *int   `json:"tag_id"`
-	Post_ID int    `json:"post_id"`
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	Tag_ID        *int `json:"tag_id"`
+	Post_Flair_ID *int `json:"post_flair_id"`
+	//nil keeps the current url
+	Post_URL *string `json:"post_url"`
+	Post_ID  int     `json:"post_id"`
+	Title    string  `json:"title"`
+	Content  string  `json:"content"`
+	//nil keeps the current flag
+	Is_NSFW    *bool `json:"is_nsfw"`
+	Is_Spoiler *bool `json:"is_spoiler"`
 }
 
 type PostAddPayload struct {
-	Tag_ID   *int   `json:"tag_id"`
-	Title    string `json:"title"`
-	Content  string `json:"content"`
-	Post_URL string `json:"post_url"`
+	Tag_ID        *int   `json:"tag_id"`
+	Post_Flair_ID *int   `json:"post_flair_id"`
+	Title         string `json:"title"`
+	//may be empty for polls
+	Content string `json:"content"`
+	//makes the post a poll
+	Poll *PollPayload `json:"poll"`
+	//makes the post a link, the server reads the page for its preview
+	Link_URL *string `json:"link_url"`
+	//nil takes the topic's default
+	Is_NSFW    *bool `json:"is_nsfw"`
+	Is_Spoiler bool  `json:"is_spoiler"`
+	//draft, scheduled or published, empty publishes straight away
+	Status         string     `json:"status"`
+	Scheduled_Date *time.Time `json:"scheduled_date"`
+}
+
+type PostPublishPayload struct {
+	Status         string     `json:"status"`
+	Scheduled_Date *time.Time `json:"scheduled_date"`
+}
+
+type PostDraftResult struct {
+	Post_ID        int     `json:"post_id"`
+	Post_URL       string  `json:"post_url"`
+	Topic_ID       int     `json:"topic_id"`
+	Topic_Name     string  `json:"topic_name"`
+	Topic_URL      string  `json:"topic_url"`
+	Tag_ID         *int    `json:"tag_id"`
+	Post_Flair_ID  *int    `json:"post_flair_id"`
+	Title          string  `json:"title"`
+	Content        string  `json:"content"`
+	Status         string  `json:"status"`
+	Scheduled_Date *string `json:"scheduled_date"`
+	Created_Date   string  `json:"created_date"`
+}
+
+type PostUpdateResult struct {
+	Post_ID       int     `json:"post_id"`
+	Post_URL      string  `json:"post_url"`
+	Tag_ID        *int    `json:"tag_id"`
+	Post_Flair_ID *int    `json:"post_flair_id"`
+	Title         string  `json:"title"`
+	Content       string  `json:"content"`
+	Edited_Date   *string `json:"edited_date"`
+	Edit_Count    int     `json:"edit_count"`
+	Is_NSFW       bool    `json:"is_nsfw"`
+	Is_Spoiler    bool    `json:"is_spoiler"`
+}
+
+type DiffLine struct {
+	//equal, insert or delete
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+type PostRevision struct {
+	Revision_ID     int    `json:"revision_id"`
+	Post_ID         int    `json:"post_id"`
+	Revision_Number int    `json:"revision_number"`
+	Tag_ID          *int   `json:"tag_id"`
+	Title           string `json:"title"`
+	Content         string `json:"content"`
+	Content_HTML    string `json:"content_html"`
+	Created_Date    string `json:"created_date"`
+	//changes from the previous revision, empty for the original
+	Title_Diff   []DiffLine `json:"title_diff"`
+	Content_Diff []DiffLine `json:"content_diff"`
 }
@@ -0,0 +1,65 @@
+package types
+
+import "time"
+
+type SearchFilter struct {
+	//raw search box input, see search.ParseQuery
+	Query     string
+	Topic_ID  *int
+	Tag_ID    *int
+	Author_ID *int
+	//created date range, both days inclusive
+	From   *time.Time
+	To     *time.Time
+	Limit  int
+	Cursor *RankCursor
+	//nsfw posts follow the viewer's preferences, nil for anonymous viewers
+	Viewer_ID *int
+}
+
+type PostSearchResult struct {
+	Post_ID       int     `json:"post_id"`
+	Post_URL      string  `json:"post_url"`
+	User_ID       int     `json:"user_id"`
+	Username      string  `json:"username"`
+	DisplayName   string  `json:"display_name"`
+	User_Image    string  `json:"user_image"`
+	Topic_ID      int     `json:"topic_id"`
+	Topic_Name    string  `json:"topic_name"`
+	Topic_URL     string  `json:"topic_url"`
+	Tag_Name      *string `json:"tag_name"`
+	Title         string  `json:"title"`
+	Created_Date  string  `json:"created_date"`
+	Sum_Votes     int     `json:"sum_votes"`
+	Comment_Count int     `json:"comment_count"`
+	Rank          float64 `json:"rank"`
+	//html escaped with the matched words wrapped in <mark>
+	Title_Highlight string `json:"title_highlight"`
+	Snippet         string `json:"snippet"`
+	//best matching comment when the comments matched too
+	Comment_ID      *int    `json:"comment_id"`
+	Comment_Snippet *string `json:"comment_snippet"`
+}
+
+// post recommended under another post
+type RelatedPost struct {
+	Post_ID       int     `json:"post_id"`
+	Post_URL      string  `json:"post_url"`
+	User_ID       int     `json:"user_id"`
+	Username      string  `json:"username"`
+	DisplayName   string  `json:"display_name"`
+	Topic_ID      int     `json:"topic_id"`
+	Topic_Name    string  `json:"topic_name"`
+	Topic_URL     string  `json:"topic_url"`
+	Tag_Name      *string `json:"tag_name"`
+	Title         string  `json:"title"`
+	Created_Date  string  `json:"created_date"`
+	Sum_Votes     int     `json:"sum_votes"`
+	Comment_Count int     `json:"comment_count"`
+	Is_Same_Topic bool    `json:"is_same_topic"`
+	Is_NSFW       bool    `json:"is_nsfw"`
+	Is_Spoiler    bool    `json:"is_spoiler"`
+	Blur_NSFW     bool    `json:"blur_nsfw"`
+	Blur_Spoiler  bool    `json:"blur_spoiler"`
+	Score         float64 `json:"score"`
+}
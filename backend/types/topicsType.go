@@ -16,6 +16,7 @@ type TopicDefaultResult struct {
 	Followers_Count int    `json:"followers_count"`
 	Posts_Count     int    `json:"posts_count"`
 	Is_Following    bool   `json:"is_following"`
+	Is_Archived     bool   `json:"is_archived"`
 }
 
 type TopicByPopularitySearchResult struct {
@@ -35,3 +36,24 @@ type TopicByPopularitySearchResult struct {
 	Posts_Count     int    `json:"posts_count"`
 	Is_Following    bool   `json:"is_following"`
 }
+
+// topic page with everything shown around the posts
+type TopicDetailResult struct {
+	TopicDefaultResult
+	Sidebar_Markdown   *string `json:"sidebar_markdown"`
+	Banner_Markdown    *string `json:"banner_markdown"`
+	Require_Post_Flair bool    `json:"require_post_flair"`
+	Is_Read_Only       bool    `json:"is_read_only"`
+	//default for new posts in the topic
+	Is_NSFW       bool         `json:"is_nsfw"`
+	Rules         []TopicRule  `json:"rules"`
+	User_Flairs   []TopicFlair `json:"user_flairs"`
+	Post_Flairs   []TopicFlair `json:"post_flairs"`
+	My_User_Flair *TopicFlair  `json:"my_user_flair"`
+	//differs from the requested url when an old url was used
+	Canonical_URL string `json:"canonical_url"`
+}
+
+type TopicRenamePayload struct {
+	Topic_URL string `json:"topic_url"`
+}
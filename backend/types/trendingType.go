@@ -0,0 +1,31 @@
+package types
+
+type TrendingTopic struct {
+	Topic_ID        int    `json:"topic_id"`
+	Topic_Name      string `json:"topic_name"`
+	Topic_URL       string `json:"topic_url"`
+	Description     string `json:"description"`
+	Category_Name   string `json:"category_name"`
+	Category_Icon   string `json:"category_icon"`
+	Followers_Count int    `json:"followers_count"`
+	Is_Following    bool   `json:"is_following"`
+	Is_NSFW         bool   `json:"is_nsfw"`
+	//activity of the trending window
+	New_Followers int     `json:"new_followers"`
+	Posts         int     `json:"posts"`
+	Votes         int     `json:"votes"`
+	Score         float64 `json:"score"`
+	Updated_Date  string  `json:"updated_date"`
+}
+
+type TrendingTag struct {
+	Tag_ID      int    `json:"tag_id"`
+	Tag_Name    string `json:"tag_name"`
+	Description string `json:"tag_description"`
+	Tag_Icon    string `json:"tag_icon"`
+	//activity of the trending window
+	Posts        int     `json:"posts"`
+	Votes        int     `json:"votes"`
+	Score        float64 `json:"score"`
+	Updated_Date string  `json:"updated_date"`
+}
@@ -71,3 +71,9 @@ type LoginResult struct {
 type CheckUserExists struct {
 	Exists bool `json:"exists"`
 }
+
+// what the user wants to see of nsfw and spoiler posts
+type UserPreferences struct {
+	NSFW_Preference string `json:"nsfw_preference"`
+	Blur_Spoilers   bool   `json:"blur_spoilers"`
+}
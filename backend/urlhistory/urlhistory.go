@@ -0,0 +1,92 @@
+// Old topic and post urls kept after a rename so shared links still resolve
+package urlhistory
+
+import (
+	"context"
+
+	db "github.com/minrui13/backend/database"
+)
+
+// frontend pages for topics and posts
+func TopicPath(topicURL string) string {
+	return "/buzzbee/hive/" + topicURL
+}
+
+func PostPath(postURL string) string {
+	return "/buzz/bee/buzz/" + postURL
+}
+
+// get the current url of a topic from its current or any previous url
+// a live topic url wins over an old url that has since been reused
+// returns pgx.ErrNoRows if the url was never used
+func ResolveTopicURL(ctx context.Context, q db.Querier, url string) (string, error) {
+	var current string
+	err := q.QueryRow(ctx,
+		`SELECT topic_url FROM (
+			SELECT topic_url, 0 AS priority FROM topics WHERE topic_url = $1
+			UNION ALL
+			SELECT t.topic_url, 1 AS priority FROM topics_url_history h
+			INNER JOIN topics t ON t.topic_id = h.topic_id
+			WHERE h.old_url = $1
+		) AS urls
+		ORDER BY priority
+		LIMIT 1`, url).Scan(&current)
+	return current, err
+}
+
+// get the current url of a post from its current or any previous url
+// returns pgx.ErrNoRows if the url was never used
+func ResolvePostURL(ctx context.Context, q db.Querier, url string) (string, error) {
+	var current string
+	err := q.QueryRow(ctx,
+		`SELECT post_url FROM (
+			SELECT post_url, 0 AS priority FROM posts WHERE post_url = $1
+			UNION ALL
+			SELECT p.post_url, 1 AS priority FROM posts_url_history h
+			INNER JOIN posts p ON p.post_id = h.post_id
+			WHERE h.old_url = $1
+		) AS urls
+		ORDER BY priority
+		LIMIT 1`, url).Scan(&current)
+	return current, err
+}
+
+// change a topic url and remember the old one
+// should run in the same transaction as any other change to the topic
+func RenameTopic(ctx context.Context, q db.Querier, topicID int, newURL string) error {
+	_, err := q.Exec(ctx,
+		`INSERT INTO topics_url_history (old_url, topic_id)
+		SELECT topic_url, topic_id FROM topics WHERE topic_id = $1 AND topic_url <> $2
+		ON CONFLICT (old_url) DO UPDATE SET topic_id = EXCLUDED.topic_id, created_date = CURRENT_TIMESTAMP`,
+		topicID, newURL)
+	if err != nil {
+		return err
+	}
+
+	//the new url is live again so it is no longer an old url
+	if _, err := q.Exec(ctx, `DELETE FROM topics_url_history WHERE old_url = $1`, newURL); err != nil {
+		return err
+	}
+
+	_, err = q.Exec(ctx, `UPDATE topics SET topic_url = $1 WHERE topic_id = $2`, newURL, topicID)
+	return err
+}
+
+// change a post url and remember the old one
+func RenamePost(ctx context.Context, q db.Querier, postID int, newURL string) error {
+	_, err := q.Exec(ctx,
+		`INSERT INTO posts_url_history (old_url, post_id)
+		SELECT post_url, post_id FROM posts WHERE post_id = $1 AND post_url <> $2
+		ON CONFLICT (old_url) DO UPDATE SET post_id = EXCLUDED.post_id, created_date = CURRENT_TIMESTAMP`,
+		postID, newURL)
+	if err != nil {
+		return err
+	}
+
+	if _, err := q.Exec(ctx, `DELETE FROM posts_url_history WHERE old_url = $1`, newURL); err != nil {
+		return err
+	}
+
+	_, err = q.Exec(ctx, `UPDATE posts SET post_url = $1 WHERE post_id = $2`, newURL, postID)
+	return err
+}
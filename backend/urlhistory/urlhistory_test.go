@@ -0,0 +1,69 @@
+package urlhistory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/minrui13/backend/database/dbtest"
+)
+
+func TestResolveTopicURL(t *testing.T) {
+	tx := dbtest.Tx(t)
+	ctx := context.Background()
+
+	var topicA, topicB int
+	var urlA, urlB string
+	err := tx.QueryRow(ctx,
+		`SELECT a.topic_id, a.topic_url, b.topic_id, b.topic_url
+		FROM topics a INNER JOIN topics b ON b.topic_id > a.topic_id
+		ORDER BY a.topic_id, b.topic_id LIMIT 1`).Scan(&topicA, &urlA, &topicB, &urlB)
+	if errors.Is(err, pgx.ErrNoRows) {
+		t.Skip("the test database needs two topics")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolves := func(url string, want string) {
+		t.Helper()
+		got, err := ResolveTopicURL(ctx, tx, url)
+		if err != nil || got != want {
+			t.Errorf("ResolveTopicURL(%q) = %q, %v, want %q", url, got, err, want)
+		}
+	}
+	rename := func(topicID int, url string) {
+		t.Helper()
+		if err := RenameTopic(ctx, tx, topicID, url); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//every old url leads to the latest one
+	rename(topicA, "urlhistory-test-first")
+	rename(topicA, "urlhistory-test-second")
+	resolves(urlA, "urlhistory-test-second")
+	resolves("urlhistory-test-first", "urlhistory-test-second")
+	resolves("urlhistory-test-second", "urlhistory-test-second")
+
+	//an old url taken by another topic leads to that topic
+	rename(topicB, urlA)
+	resolves(urlA, urlA)
+	resolves(urlB, urlA)
+
+	//renaming back makes the url live again
+	rename(topicA, "urlhistory-test-first")
+	resolves("urlhistory-test-first", "urlhistory-test-first")
+	resolves("urlhistory-test-second", "urlhistory-test-first")
+
+	//a live url wins over an old url of another topic
+	if _, err := tx.Exec(ctx, `INSERT INTO topics_url_history (old_url, topic_id) VALUES ($1, $2)`, urlA, topicA); err != nil {
+		t.Fatal(err)
+	}
+	resolves(urlA, urlA)
+
+	if _, err := ResolveTopicURL(ctx, tx, "urlhistory-test-never-used"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("ResolveTopicURL of an unused url returned %v, want pgx.ErrNoRows", err)
+	}
+}
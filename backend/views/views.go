@@ -0,0 +1,135 @@
+// Post view counting
+// views are buffered in memory and written in batches by the flush job,
+// the posts_viewers table of the 013 migration keeps a viewer from being counted twice within the window
+package views
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	db "github.com/minrui13/backend/database"
+	"github.com/minrui13/backend/jobs"
+)
+
+// a viewer is counted again once this much time has passed since their last counted view
+const Window = 30 * time.Minute
+
+// views kept between flushes, more are dropped until the next flush
+const maxPending = 100000
+
+type view struct {
+	postID int
+	viewer string
+}
+
+type Recorder struct {
+	mu sync.Mutex
+	//time of the first view since the last flush
+	pending map[view]time.Time
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{pending: map[view]time.Time{}}
+}
+
+// identify who is viewing, the user when logged in
+// otherwise a hash of the ip address and user agent so no address is stored
+func Viewer(r *http.Request, userID int) string {
+	if userID > 0 {
+		return fmt.Sprintf("user:%d", userID)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	sum := sha256.Sum256([]byte(host + "\n" + r.UserAgent()))
+	return "anon:" + hex.EncodeToString(sum[:16])
+}
+
+// buffer a view of a post
+func (rec *Recorder) Record(postID int, viewer string) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	key := view{postID: postID, viewer: viewer}
+	if _, ok := rec.pending[key]; ok {
+		return
+	}
+	if len(rec.pending) >= maxPending {
+		return
+	}
+	rec.pending[key] = time.Now()
+}
+
+// write the buffered views, views of viewers counted within the window are skipped
+func (rec *Recorder) Flush(ctx context.Context, q db.Querier) error {
+	rec.mu.Lock()
+	pending := rec.pending
+	rec.pending = map[view]time.Time{}
+	rec.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	postIDs := make([]int, 0, len(pending))
+	viewers := make([]string, 0, len(pending))
+	viewedDates := make([]time.Time, 0, len(pending))
+	for v, viewed := range pending {
+		postIDs = append(postIDs, v.postID)
+		viewers = append(viewers, v.viewer)
+		viewedDates = append(viewedDates, viewed)
+	}
+
+	//only viewers that are new or past the window come back from the insert
+	//posts deleted since the view are skipped
+	_, err := q.Exec(ctx,
+		`WITH counted AS (
+			INSERT INTO posts_viewers AS pvw (post_id, viewer, last_counted_date)
+			SELECT v.post_id, v.viewer, v.viewed_date
+			FROM unnest($1::int[], $2::text[], $3::timestamp[]) AS v(post_id, viewer, viewed_date)
+			INNER JOIN posts p ON p.post_id = v.post_id
+			ON CONFLICT (post_id, viewer) DO UPDATE SET last_counted_date = EXCLUDED.last_counted_date
+			WHERE pvw.last_counted_date <= EXCLUDED.last_counted_date - $4::interval
+			RETURNING pvw.post_id, (xmax = 0) AS is_new_viewer
+		)
+		UPDATE posts p SET
+			view_count = p.view_count + c.views,
+			viewer_count = p.viewer_count + c.viewers
+		FROM (
+			SELECT post_id, COUNT(*) AS views, COUNT(*) FILTER (WHERE is_new_viewer) AS viewers
+			FROM counted
+			GROUP BY post_id
+		) c
+		WHERE p.post_id = c.post_id`,
+		postIDs, viewers, viewedDates, Window)
+	if err != nil {
+		//keep the views for the next flush
+		rec.mu.Lock()
+		for v, viewed := range pending {
+			if _, ok := rec.pending[v]; !ok && len(rec.pending) < maxPending {
+				rec.pending[v] = viewed
+			}
+		}
+		rec.mu.Unlock()
+	}
+	return err
+}
+
+// write the buffered views every 15 seconds
+func FlushJob(rec *Recorder) jobs.Job {
+	return jobs.Job{
+		Name:     "post view flush",
+		Interval: 15 * time.Second,
+		Run: func(ctx context.Context, pool *pgxpool.Pool) error {
+			return rec.Flush(ctx, pool)
+		},
+	}
+}
@@ -0,0 +1,54 @@
+package views
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestViewer(t *testing.T) {
+	viewer := func(remoteAddr string, userAgent string) string {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("User-Agent", userAgent)
+		return Viewer(r, 0)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	if got := Viewer(r, 42); got != "user:42" {
+		t.Errorf("Viewer of user 42 = %q, want user:42", got)
+	}
+
+	anon := viewer("203.0.113.7:5000", "browser")
+	if !strings.HasPrefix(anon, "anon:") || strings.Contains(anon, "203.0.113.7") || len(anon) != len("anon:")+32 {
+		t.Errorf("anonymous viewer = %q, want a hash without the address", anon)
+	}
+	if got := viewer("203.0.113.7:6000", "browser"); got != anon {
+		t.Errorf("viewer on another port = %q, want the same viewer %q", got, anon)
+	}
+	if got := viewer("203.0.113.8:5000", "browser"); got == anon {
+		t.Errorf("viewer at another address is the same viewer %q", got)
+	}
+	if got := viewer("203.0.113.7:5000", "other browser"); got == anon {
+		t.Errorf("viewer with another user agent is the same viewer %q", got)
+	}
+	if got := viewer("203.0.113.7", "browser"); !strings.HasPrefix(got, "anon:") {
+		t.Errorf("viewer without a port = %q, want an anonymous viewer", got)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	rec := NewRecorder()
+	rec.Record(1, "user:1")
+	first := rec.pending[view{postID: 1, viewer: "user:1"}]
+	rec.Record(1, "user:1")
+	rec.Record(1, "user:2")
+	rec.Record(2, "user:1")
+
+	if len(rec.pending) != 3 {
+		t.Errorf("recorded %d views, want 3", len(rec.pending))
+	}
+	if got := rec.pending[view{postID: 1, viewer: "user:1"}]; !got.Equal(first) {
+		t.Errorf("a repeated view moved the first view time from %v to %v", first, got)
+	}
+}
@@ -101,7 +101,7 @@ export const getPostByID = (
   return new Promise(async (resolve, reject) => {
     try {
       const result = await mainAxios.post(
-        `/posts/getPostByID/${payload.user_id}/${payload.post_id}`,
+        `/posts/getPostByID/${payload.post_id}/${payload.user_id}`,
         {
           params: payload,
         },